@@ -0,0 +1,30 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"tinkoff-invest-contest/internal/auth"
+)
+
+// authhash prints credential hashes to be put into the users file
+func main() {
+	password := flag.String("password", "", "basic auth password to hash with bcrypt")
+	token := flag.String("token", "", "API token to hash with SHA-256")
+	flag.Parse()
+
+	if *password == "" && *token == "" {
+		flag.Usage()
+		return
+	}
+	if *password != "" {
+		hash, err := auth.HashPassword(*password)
+		if err != nil {
+			log.Fatalln(err)
+		}
+		fmt.Println("PasswordHash:", hash)
+	}
+	if *token != "" {
+		fmt.Println("TokenHash:", auth.HashToken(*token))
+	}
+}
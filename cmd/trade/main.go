@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"github.com/gin-gonic/gin"
 	"github.com/joho/godotenv"
 	"io"
@@ -11,31 +12,24 @@ import (
 	"tinkoff-invest-contest/internal/api"
 	"tinkoff-invest-contest/internal/api/botlog"
 	"tinkoff-invest-contest/internal/app"
-	"tinkoff-invest-contest/internal/appstate"
+	"tinkoff-invest-contest/internal/auth"
 	"tinkoff-invest-contest/internal/dashboard"
+	"tinkoff-invest-contest/internal/health"
+	"tinkoff-invest-contest/internal/metrics"
 	"tinkoff-invest-contest/internal/uihandlers"
 )
 
-func handleExit() {
-	ch := make(chan os.Signal, 1)
-	signal.Notify(ch, os.Interrupt, os.Kill, syscall.SIGTERM, syscall.SIGINT, syscall.SIGKILL)
-	// Wait for termination signal
-	<-ch
-	signal.Stop(ch)
+// handleExit waits for ctx to be done (on termination signal) and shuts the app down in a bounded time.
+// Another signal kills the app immediately
+func handleExit(ctx context.Context, stop context.CancelFunc) {
+	<-ctx.Done()
+	stop()
 	log.Println("Exiting...")
-	// Run remove sequences for bots
-	app.Bots.Lock.RLock()
-	for _, bot := range app.Bots.Table {
-		bot.Remove()
-	}
-	app.Bots.Lock.RUnlock()
-	// Trigger exit actions
-	appstate.ShouldExit = true
-	appstate.ExitActionsWG.Done()
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), app.GetShutdownTimeout())
+	defer cancel()
+	app.Shutdown(shutdownCtx)
 	// Remove Grafana dashboards
 	dashboard.RemoveBotDashboards()
-	// Wait for all to complete before exiting
-	appstate.PostExitActionsWG.Wait()
 }
 
 func runServer() {
@@ -49,30 +43,66 @@ func runServer() {
 		"./web/templates/bot_description.html",
 		"./web/templates/combat_accounts.html",
 		"./web/templates/sandbox_accounts.html",
+		"./web/templates/bot_groups.html",
 	)
 
-	router.POST("/api/bots/Create", api.CreateBot)
-	router.POST("/api/bots/Start", api.StartBot)
-	router.POST("/api/bots/TogglePause", api.TogglePauseBot)
-	router.POST("/api/bots/Remove", api.RemoveBot)
+	router.GET("/healthz", health.Healthz)
+	router.GET("/readyz", health.Readyz)
+
+	viewer := router.Group("/", auth.Require(auth.RoleViewer))
+	operator := router.Group("/", auth.Require(auth.RoleOperator), auth.Audit())
+
+	operator.POST("/api/bots/Create", api.CreateBot)
+	operator.POST("/api/bots/Start", api.StartBot)
+	operator.POST("/api/bots/TogglePause", api.TogglePauseBot)
+	operator.POST("/api/bots/Remove", api.RemoveBot)
+	operator.POST("/api/bots/UpdateParams", api.UpdateBotParams)
+
+	viewer.GET("/api/bots/GetState", api.GetBotState)
+
+	operator.POST("/api/groups/Create", api.CreateBotGroup)
+	operator.POST("/api/groups/Start", api.StartBotGroup)
+	operator.POST("/api/groups/Pause", api.PauseBotGroup)
+	operator.POST("/api/groups/Resume", api.ResumeBotGroup)
+	operator.POST("/api/groups/Remove", api.RemoveBotGroup)
+
+	viewer.GET("/api/groups/GetList", api.GetBotGroups)
+	viewer.GET("/api/groups/GetPnL", api.GetBotGroupPnL)
+
+	viewer.GET("/api/execution/GetQuality", api.GetExecutionQuality)
+
+	viewer.GET("/api/instruments/Search", api.SearchInstruments)
+	operator.POST("/api/instruments/Refresh", api.RefreshInstruments)
 
-	router.GET("/api/strategies/GetNames", api.GetStrategiesNames)
-	router.GET("/api/strategies/GetDefaults", api.GetStrategyDefaults)
+	viewer.GET("/api/screener/Screen", api.ScreenInstruments)
+	operator.POST("/api/screener/CreateBots", api.CreateBotsFromScreen)
 
-	router.POST("/api/accounts/Create", api.CreateSandboxAccount)
-	router.POST("/api/accounts/Remove", api.RemoveSandboxAccount)
-	router.GET("/api/accounts/GetCombatAccounts", api.GetCombatAccounts)
-	router.GET("/api/accounts/GetSandboxAccounts", api.GetSandboxAccounts)
+	viewer.GET("/api/strategies/GetNames", api.GetStrategiesNames)
+	viewer.GET("/api/strategies/GetDefaults", api.GetStrategyDefaults)
+	viewer.GET("/api/strategies/GetSchema", api.GetStrategySchema)
 
-	router.GET("/ws/botlog", botlog.Echo)
+	viewer.GET("/api/recordings/GetList", api.GetRecordings)
+	operator.POST("/api/recordings/Replay", api.ReplayRecordings)
 
-	router.GET("/botcontrols", uihandlers.BotControls)
-	router.GET("/createbot", uihandlers.CreateBotForm)
-	router.GET("/createsandboxaccount", uihandlers.CreateSandboxAccountForm)
-	router.GET("/botlog", uihandlers.BotLogConsole)
-	router.GET("/botdesc", uihandlers.BotDescription)
-	router.GET("/combataccounts", uihandlers.CombatAccounts)
-	router.GET("/sandboxaccounts", uihandlers.SandboxAccounts)
+	operator.POST("/api/accounts/Create", api.CreateSandboxAccount)
+	operator.POST("/api/accounts/Remove", api.RemoveSandboxAccount)
+	viewer.GET("/api/accounts/GetCombatAccounts", api.GetCombatAccounts)
+	viewer.GET("/api/accounts/GetSandboxAccounts", api.GetSandboxAccounts)
+	viewer.GET("/api/accounts/GetPaperAccounts", api.GetPaperAccounts)
+	viewer.GET("/api/accounts/GetReplayAccounts", api.GetReplayAccounts)
+
+	viewer.GET("/ws/botlog", botlog.Echo)
+
+	viewer.GET("/metrics", metrics.Handler())
+
+	viewer.GET("/botcontrols", uihandlers.BotControls)
+	viewer.GET("/createbot", uihandlers.CreateBotForm)
+	viewer.GET("/createsandboxaccount", uihandlers.CreateSandboxAccountForm)
+	viewer.GET("/botlog", uihandlers.BotLogConsole)
+	viewer.GET("/botdesc", uihandlers.BotDescription)
+	viewer.GET("/combataccounts", uihandlers.CombatAccounts)
+	viewer.GET("/sandboxaccounts", uihandlers.SandboxAccounts)
+	viewer.GET("/botgroups", uihandlers.BotGroups)
 
 	log.Fatalln(router.Run())
 }
@@ -80,10 +110,26 @@ func runServer() {
 func main() {
 	_ = godotenv.Load(".env")
 
+	err := auth.LoadUsers(auth.GetUsersFilePath())
+	if err != nil {
+		log.Fatalf("error: cannot load users (%v), please provide a users file via 'USERS_FILE' environment variable", err)
+	}
+	err = auth.InitAudit(auth.GetAuditFilePath())
+	if err != nil {
+		log.Fatalf("error: cannot open audit file (%v), please provide its path via 'AUDIT_FILE' environment variable", err)
+	}
+
 	mw := io.MultiWriter(os.Stdout, botlog.Writer)
 	log.SetOutput(mw)
 
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	err = app.Init(ctx)
+	if err != nil {
+		log.Fatalf("error: cannot initialize trade environments (%v)", err)
+	}
+
 	go runServer()
 
-	handleExit()
+	handleExit(ctx, stop)
 }
@@ -10,6 +10,10 @@ func CreateSandboxAccount(c *gin.Context) {
 	args := struct {
 		RUB float64 `form:"rub"`
 		USD float64 `form:"usd"`
+		// Paper trading account instead of sandbox one
+		Paper bool `form:"paper"`
+		// Paper trading account for replayed market data
+		Replay bool `form:"replay"`
 	}{}
 	err := c.Bind(&args)
 	if err != nil {
@@ -19,10 +23,17 @@ func CreateSandboxAccount(c *gin.Context) {
 		))
 		return
 	}
-	accountId := app.SandboxEnv.CreateSandboxAccount(map[string]float64{
+	accountId, err := getTradeEnv(true, args.Paper, args.Replay).CreateSandboxAccount(map[string]float64{
 		"rub": args.RUB,
 		"usd": args.USD,
 	})
+	if err != nil {
+		_, _ = c.Writer.WriteString(marshalResponse(
+			http.StatusInternalServerError,
+			"Couldn't create sandbox account ("+err.Error()+")",
+		))
+		return
+	}
 	_, _ = c.Writer.WriteString(marshalResponse(
 		http.StatusOK,
 		"",
@@ -34,7 +45,7 @@ func CreateSandboxAccount(c *gin.Context) {
 
 func RemoveSandboxAccount(c *gin.Context) {
 	id := c.Query("id")
-	app.SandboxEnv.RemoveSandboxAccount(id)
+	getTradeEnv(true, c.Query("paper") == "true", c.Query("replay") == "true").RemoveSandboxAccount(id)
 }
 
 func GetCombatAccounts(c *gin.Context) {
@@ -45,6 +56,22 @@ func GetCombatAccounts(c *gin.Context) {
 	))
 }
 
+func GetPaperAccounts(c *gin.Context) {
+	_, _ = c.Writer.WriteString(marshalResponse(
+		http.StatusOK,
+		"",
+		app.PaperEnv.GetAccountsPayload(),
+	))
+}
+
+func GetReplayAccounts(c *gin.Context) {
+	_, _ = c.Writer.WriteString(marshalResponse(
+		http.StatusOK,
+		"",
+		app.ReplayEnv.GetAccountsPayload(),
+	))
+}
+
 func GetSandboxAccounts(c *gin.Context) {
 	_, _ = c.Writer.WriteString(marshalResponse(
 		http.StatusOK,
@@ -0,0 +1,105 @@
+package api
+
+import (
+	"encoding/json"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"tinkoff-invest-contest/internal/app"
+	"tinkoff-invest-contest/internal/bot"
+	"tinkoff-invest-contest/internal/botspec"
+	"tinkoff-invest-contest/internal/validation"
+)
+
+// updateBotParamsArgs are the same as createBotArgs, missing arguments keep their current values
+type updateBotParamsArgs struct {
+	Window int `form:"window"`
+	// JSON object of the strategy parameters to change
+	StrategyConfig string `form:"strategyConfig"`
+
+	ordersConfigArgs
+}
+
+func UpdateBotParams(c *gin.Context) {
+	id := c.Query("id")
+	app.Bots.Lock.RLock()
+	b, ok := app.Bots.Table[id]
+	app.Bots.Lock.RUnlock()
+	if !ok {
+		_, _ = c.Writer.WriteString(marshalResponse(
+			http.StatusNotFound,
+			"Bot #"+id+" does not exist",
+		))
+		return
+	}
+
+	current := b.GetParams()
+	args := updateBotParamsArgs{
+		Window:           current.Window,
+		ordersConfigArgs: toOrdersConfigArgs(current.OrdersConfig),
+	}
+	err := c.Bind(&args)
+	if err != nil {
+		_, _ = c.Writer.WriteString(marshalResponse(
+			http.StatusBadRequest,
+			"One or more arguments are invalid ("+err.Error()+")",
+		))
+		return
+	}
+
+	var errs validation.Errors
+	ordersConfig := args.ordersConfig(&errs)
+	strategyConfig, err := mergeJSONObjects(current.StrategyConfig, args.StrategyConfig)
+	if err != nil {
+		errs.Add("strategyConfig", "%v", err)
+		strategyConfig = current.StrategyConfig
+	}
+	params := botspec.Params{
+		Window:         args.Window,
+		OrdersConfig:   ordersConfig,
+		StrategyName:   current.StrategyName,
+		StrategyConfig: strategyConfig,
+	}
+	strategy, paramsErrors := botspec.ValidateParams(b.IsSandbox(), params)
+	errs = append(errs, paramsErrors...)
+	if len(errs) > 0 {
+		_, _ = c.Writer.WriteString(marshalResponse(
+			http.StatusBadRequest,
+			"One or more arguments are invalid ("+errs.Error()+")",
+			errs,
+		))
+		return
+	}
+
+	b.UpdateParams(bot.Params{Params: params, Strategy: strategy})
+	_, _ = c.Writer.WriteString(marshalResponse(
+		http.StatusOK,
+		"",
+	))
+}
+
+// mergeJSONObjects returns base with the keys of update added or replaced, an empty update keeps base as is
+func mergeJSONObjects(base string, update string) (string, error) {
+	if update == "" {
+		return base, nil
+	}
+	var merged, updated map[string]json.RawMessage
+	err := json.Unmarshal([]byte(base), &merged)
+	if err != nil {
+		return "", err
+	}
+	err = json.Unmarshal([]byte(update), &updated)
+	if err != nil {
+		return "", err
+	}
+	if merged == nil {
+		merged = make(map[string]json.RawMessage, len(updated))
+	}
+	for key, value := range updated {
+		merged[key] = value
+	}
+	bytes, err := json.Marshal(merged)
+	if err != nil {
+		return "", err
+	}
+	return string(bytes), nil
+}
@@ -5,10 +5,10 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/gorilla/websocket"
 	"log"
-	"net/http"
 	"os"
 	"regexp"
 	"sync"
+	"tinkoff-invest-contest/internal/auth"
 	"tinkoff-invest-contest/internal/utils"
 )
 
@@ -16,9 +16,7 @@ var prefixRegex = regexp.MustCompile(`\[bot#[0-9]+\]`)
 var botIdRegex = regexp.MustCompile(`[0-9]+`)
 
 var upgrader = websocket.Upgrader{
-	CheckOrigin: func(r *http.Request) bool {
-		return true
-	},
+	CheckOrigin: auth.CheckOrigin,
 }
 
 var clients = struct {
@@ -42,7 +40,11 @@ func init() {
 }
 
 func Echo(c *gin.Context) {
-	conn, _ := upgrader.Upgrade(c.Writer, c.Request, nil)
+	conn, err := upgrader.Upgrade(c.Writer, c.Request, nil)
+	if err != nil {
+		log.Println("error: can't upgrade bot log connection:", err)
+		return
+	}
 	defer conn.Close()
 
 	botId := c.Query("id")
@@ -5,37 +5,84 @@ import (
 	"github.com/gin-gonic/gin"
 	"net/http"
 	"sync"
+	"time"
 	"tinkoff-invest-contest/internal/app"
+	"tinkoff-invest-contest/internal/bars"
 	"tinkoff-invest-contest/internal/bot"
+	"tinkoff-invest-contest/internal/botspec"
 	"tinkoff-invest-contest/internal/client/investapi"
+	"tinkoff-invest-contest/internal/execution"
+	"tinkoff-invest-contest/internal/sizing"
 	"tinkoff-invest-contest/internal/strategies"
 	"tinkoff-invest-contest/internal/tradeenv"
 	"tinkoff-invest-contest/internal/utils"
+	"tinkoff-invest-contest/internal/validation"
 )
 
 var mu sync.Mutex
 var botId int
 
+type createBotArgs struct {
+	Sandbox        bool                 `form:"sandbox"`
+	Paper          bool                 `form:"paper"`
+	Replay         bool                 `form:"replay"` // Paper trading on replayed market data only
+	Figi           string               `form:"figi"`
+	InstrumentType utils.InstrumentType `form:"instrumentType"`
+	AllowMargin    bool                 `form:"allowMargin"`
+
+	ReconcilePolicy string `form:"reconcilePolicy"`
+	ShutdownPolicy  string `form:"shutdownPolicy"`
+
+	StrategyName   string `form:"strategyName"`
+	StrategyConfig string `form:"strategyConfig"`
+
+	CandleInterval investapi.CandleInterval `form:"candleInterval"`
+	// Custom interval like "10min" or "4hour", replaces candleInterval
+	Interval       string `form:"interval"`
+	BarType        string `form:"barType"`
+	Window         int    `form:"window"`
+	OrderBookDepth int32  `form:"orderBookDepth"`
+
+	ordersConfigArgs
+}
+
+type ordersConfigArgs struct {
+	OrderType         investapi.OrderType `form:"orderType"`
+	StopLossOrderType investapi.OrderType `form:"stopLossOrderType"`
+	TakeProfitRatio   float64             `form:"takeProfitRatio"`
+	StopLossRatio     float64             `form:"stopLossRatio"`
+	StopLossExecRatio float64             `form:"stopLossExecRatio"`
+
+	TrailingStopRatio           float64 `form:"trailingStopRatio"`
+	TrailingStopATRMultiple     float64 `form:"trailingStopATRMultiple"`
+	TrailingStopATRPeriod       int     `form:"trailingStopATRPeriod"`
+	TrailingStopActivationRatio float64 `form:"trailingStopActivationRatio"`
+
+	TakeProfitLevels          string `form:"takeProfitLevels"`
+	BreakEvenAfterFirstTarget bool   `form:"breakEvenAfterFirstTarget"`
+	UseExchangeStopOrders     bool   `form:"useExchangeStopOrders"`
+
+	ExecutionAlgorithm string        `form:"executionAlgorithm"`
+	TWAPSlices         int           `form:"twapSlices"`
+	TWAPDuration       time.Duration `form:"twapDuration"`
+	IcebergDepthShare  float64       `form:"icebergDepthShare"`
+	RepriceInterval    time.Duration `form:"repriceInterval"`
+	MaxSlippage        float64       `form:"maxSlippage"`
+
+	SizingModel             string  `form:"sizingModel"`
+	SizingFixedLots         int64   `form:"sizingFixedLots"`
+	SizingFixedMoney        float64 `form:"sizingFixedMoney"`
+	SizingEquityPercent     float64 `form:"sizingEquityPercent"`
+	SizingVolatilityTarget  float64 `form:"sizingVolatilityTarget"`
+	SizingATRPeriod         int     `form:"sizingATRPeriod"`
+	SizingKellyWinRate      float64 `form:"sizingKellyWinRate"`
+	SizingKellyWinLossRatio float64 `form:"sizingKellyWinLossRatio"`
+	SizingKellyCap          float64 `form:"sizingKellyCap"`
+	SizingRiskPerTrade      float64 `form:"sizingRiskPerTrade"`
+}
+
 func CreateBot(c *gin.Context) {
-	args := struct {
-		Sandbox        bool                 `form:"sandbox"`
-		Figi           string               `form:"figi"`
-		InstrumentType utils.InstrumentType `form:"instrumentType"`
-		AllowMargin    bool                 `form:"allowMargin"`
-
-		StrategyName   string `form:"strategyName"`
-		StrategyConfig string `form:"strategyConfig"`
-
-		CandleInterval investapi.CandleInterval `form:"candleInterval"`
-		Window         int                      `form:"window"`
-		OrderBookDepth int32                    `form:"orderBookDepth"`
-
-		OrderType         investapi.OrderType `form:"orderType"`
-		StopLossOrderType investapi.OrderType `form:"stopLossOrderType"`
-		TakeProfitRatio   float64             `form:"takeProfitRatio"`
-		StopLossRatio     float64             `form:"stopLossRatio"`
-		StopLossExecRatio float64             `form:"stopLossExecRatio"`
-	}{}
+	args := createBotArgs{}
 
 	err := c.Bind(&args)
 	if err != nil {
@@ -46,96 +93,322 @@ func CreateBot(c *gin.Context) {
 		return
 	}
 
-	var tradeEnv *tradeenv.TradeEnv
-	if args.Sandbox {
-		tradeEnv = app.SandboxEnv
+	created, status, message, fieldErrors := createBot(args)
+	if status != http.StatusOK {
+		if len(fieldErrors) > 0 {
+			_, _ = c.Writer.WriteString(marshalResponse(status, message, fieldErrors))
+			return
+		}
+		_, _ = c.Writer.WriteString(marshalResponse(status, message))
+		return
+	}
+	_, _ = c.Writer.WriteString(marshalResponse(
+		http.StatusOK,
+		"",
+		created,
+	))
+}
+
+// getTradeEnv returns the environment bots trade in, replay takes precedence over paper trading,
+// which takes precedence over sandbox
+func getTradeEnv(sandbox bool, paper bool, replay bool) *tradeenv.TradeEnv {
+	switch {
+	case replay:
+		return app.ReplayEnv
+	case paper:
+		return app.PaperEnv
+	case sandbox:
+		return app.SandboxEnv
+	}
+	return app.CombatEnv
+}
+
+type createdBot struct {
+	Id   string `json:"id"`
+	Name string `json:"name"`
+
+	bot *bot.Bot
+}
+
+// createBot creates a bot by args, returning the HTTP status and the error message if it fails.
+// Invalid arguments are reported all at once as field errors
+func createBot(args createBotArgs) (createdBot, int, string, validation.Errors) {
+	var errs validation.Errors
+
+	reconcilePolicy, err := bot.StringToReconcilePolicy(args.ReconcilePolicy)
+	if err != nil {
+		errs.Add("reconcilePolicy", "%v", err)
+	}
+	shutdownPolicy, err := bot.StringToShutdownPolicy(args.ShutdownPolicy)
+	if err != nil {
+		errs.Add("shutdownPolicy", "%v", err)
+	}
+	var interval bars.Interval
+	if args.Interval != "" {
+		interval, err = bars.ParseInterval(args.Interval)
+		if err != nil {
+			errs.Add("interval", "%v", err)
+		}
 	} else {
-		tradeEnv = app.CombatEnv
+		interval, err = bars.FromCandleInterval(args.CandleInterval)
+		if err != nil {
+			errs.Add("candleInterval", "%v", err)
+		}
 	}
-	instrument, err := tradeEnv.Client.InstrumentByFigi(args.Figi, args.InstrumentType)
+	barType, err := bars.StringToBarType(args.BarType)
 	if err != nil {
-		_, _ = c.Writer.WriteString(marshalResponse(
-			http.StatusNotFound,
-			"Couldn't find instrument by FIGI '"+args.Figi+"' ("+err.Error()+")",
-		))
-		return
+		errs.Add("barType", "%v", err)
 	}
+	ordersConfig := args.ordersConfig(&errs)
+
+	tradeEnv := getTradeEnv(args.Sandbox, args.Paper, args.Replay)
+	// The catalog knows the type of its instruments, instrumentType is only used for the ones it doesn't know
+	instrument, err := app.Catalog.Instrument(tradeEnv.Client, args.Figi, args.InstrumentType)
+	if err != nil {
+		errs.Add("figi", "couldn't find instrument by FIGI '%v' (%v)", args.Figi, err)
+	}
+
+	strategy, specErrors := botspec.Validate(botspec.Spec{
+		Sandbox:        tradeEnv.IsSandbox(),
+		Paper:          tradeEnv.IsPaper(),
+		Instrument:     instrument,
+		AllowMargin:    args.AllowMargin,
+		CandleInterval: interval,
+		OrderBookDepth: args.OrderBookDepth,
+		Params: botspec.Params{
+			Window:         args.Window,
+			OrdersConfig:   ordersConfig,
+			StrategyName:   args.StrategyName,
+			StrategyConfig: args.StrategyConfig,
+		},
+	})
+	errs = append(errs, specErrors...)
+	if len(errs) > 0 {
+		return createdBot{}, http.StatusBadRequest, "One or more arguments are invalid (" + errs.Error() + ")", errs
+	}
+
 	name := instrument.GetTicker()
-	if args.Sandbox {
+	if args.Replay {
+		name = "[replay] " + name
+	} else if tradeEnv.IsPaper() {
+		name = "[paper] " + name
+	} else if args.Sandbox {
 		name = "[sandbox] " + name
 	}
 	mu.Lock()
+	defer mu.Unlock()
 	name += " #" + fmt.Sprint(botId)
 
-	if newStrategyFromJSON, ok := strategies.JSONConstructors[args.StrategyName]; ok {
-		strategy, err := newStrategyFromJSON(args.StrategyConfig)
-		if err != nil {
-			_, _ = c.Writer.WriteString(marshalResponse(
-				http.StatusBadRequest,
-				"Invalid strategy config for '"+args.StrategyName+"' ("+err.Error()+")",
-			))
-			return
-		}
-		app.Bots.Table[fmt.Sprint(botId)] = bot.New(
-			botId,
-			name,
-			instrument,
-			args.AllowMargin,
-			tradeEnv.Fee,
-			tradeEnv,
-			args.OrderType,
-			args.StopLossOrderType,
-			args.TakeProfitRatio,
-			args.StopLossRatio,
-			args.StopLossExecRatio,
-			args.CandleInterval,
-			args.Window,
-			args.OrderBookDepth,
-			strategy,
-		)
-	} else {
+	b := bot.New(
+		botId,
+		name,
+		instrument,
+		args.AllowMargin,
+		tradeEnv.Fee,
+		reconcilePolicy,
+		shutdownPolicy,
+		tradeEnv,
+		interval,
+		barType,
+		args.OrderBookDepth,
+		bot.Params{
+			Params: botspec.Params{
+				Window:         args.Window,
+				OrdersConfig:   ordersConfig,
+				StrategyName:   args.StrategyName,
+				StrategyConfig: args.StrategyConfig,
+			},
+			Strategy: strategy,
+		},
+	)
+	app.Bots.Lock.Lock()
+	app.Bots.Table[fmt.Sprint(botId)] = b
+	app.Bots.Lock.Unlock()
+
+	created := createdBot{fmt.Sprint(botId), name, b}
+	botId++
+	return created, http.StatusOK, "", nil
+}
+
+// ordersConfig builds the orders config from args, adding an error for every argument that can't be parsed
+func (args ordersConfigArgs) ordersConfig(errs *validation.Errors) strategies.OrdersConfig {
+	takeProfitLevels, err := strategies.ParseTakeProfitLevels(args.TakeProfitLevels)
+	if err != nil {
+		errs.Add("takeProfitLevels", "%v", err)
+	}
+
+	executionConfig := execution.Config{
+		TWAPSlices:        args.TWAPSlices,
+		TWAPDuration:      args.TWAPDuration,
+		IcebergDepthShare: args.IcebergDepthShare,
+		RepriceInterval:   args.RepriceInterval,
+		MaxSlippage:       args.MaxSlippage,
+	}
+	executionConfig.Algorithm, err = execution.StringToAlgorithm(args.ExecutionAlgorithm)
+	if err != nil {
+		errs.Add("executionAlgorithm", "%v", err)
+	}
+
+	sizingConfig := sizing.Config{
+		FixedLots:         args.SizingFixedLots,
+		FixedMoney:        args.SizingFixedMoney,
+		EquityPercent:     args.SizingEquityPercent,
+		VolatilityTarget:  args.SizingVolatilityTarget,
+		ATRPeriod:         args.SizingATRPeriod,
+		KellyWinRate:      args.SizingKellyWinRate,
+		KellyWinLossRatio: args.SizingKellyWinLossRatio,
+		KellyCap:          args.SizingKellyCap,
+		RiskPerTrade:      args.SizingRiskPerTrade,
+	}
+	sizingConfig.Model, err = sizing.StringToModel(args.SizingModel)
+	if err != nil {
+		errs.Add("sizingModel", "%v", err)
+	}
+
+	return strategies.OrdersConfig{
+		OrderType:                   args.OrderType,
+		StopLossOrderType:           args.StopLossOrderType,
+		TakeProfitRatio:             args.TakeProfitRatio,
+		StopLossRatio:               args.StopLossRatio,
+		StopLossExecRatio:           args.StopLossExecRatio,
+		TrailingStopRatio:           args.TrailingStopRatio,
+		TrailingStopATRMultiple:     args.TrailingStopATRMultiple,
+		TrailingStopATRPeriod:       args.TrailingStopATRPeriod,
+		TrailingStopActivationRatio: args.TrailingStopActivationRatio,
+		TakeProfitLevels:            takeProfitLevels,
+		BreakEvenAfterFirstTarget:   args.BreakEvenAfterFirstTarget,
+		UseExchangeStopOrders:       args.UseExchangeStopOrders,
+		Execution:                   executionConfig,
+		Sizing:                      sizingConfig,
+	}
+}
+
+// toOrdersConfigArgs is the reverse of ordersConfigArgs.ordersConfig
+func toOrdersConfigArgs(config strategies.OrdersConfig) ordersConfigArgs {
+	return ordersConfigArgs{
+		OrderType:                   config.OrderType,
+		StopLossOrderType:           config.StopLossOrderType,
+		TakeProfitRatio:             config.TakeProfitRatio,
+		StopLossRatio:               config.StopLossRatio,
+		StopLossExecRatio:           config.StopLossExecRatio,
+		TrailingStopRatio:           config.TrailingStopRatio,
+		TrailingStopATRMultiple:     config.TrailingStopATRMultiple,
+		TrailingStopATRPeriod:       config.TrailingStopATRPeriod,
+		TrailingStopActivationRatio: config.TrailingStopActivationRatio,
+		TakeProfitLevels:            strategies.FormatTakeProfitLevels(config.TakeProfitLevels),
+		BreakEvenAfterFirstTarget:   config.BreakEvenAfterFirstTarget,
+		UseExchangeStopOrders:       config.UseExchangeStopOrders,
+		ExecutionAlgorithm:          execution.AlgorithmToString(config.Execution.Algorithm),
+		TWAPSlices:                  config.Execution.TWAPSlices,
+		TWAPDuration:                config.Execution.TWAPDuration,
+		IcebergDepthShare:           config.Execution.IcebergDepthShare,
+		RepriceInterval:             config.Execution.RepriceInterval,
+		MaxSlippage:                 config.Execution.MaxSlippage,
+		SizingModel:                 sizing.ModelToString(config.Sizing.Model),
+		SizingFixedLots:             config.Sizing.FixedLots,
+		SizingFixedMoney:            config.Sizing.FixedMoney,
+		SizingEquityPercent:         config.Sizing.EquityPercent,
+		SizingVolatilityTarget:      config.Sizing.VolatilityTarget,
+		SizingATRPeriod:             config.Sizing.ATRPeriod,
+		SizingKellyWinRate:          config.Sizing.KellyWinRate,
+		SizingKellyWinLossRatio:     config.Sizing.KellyWinLossRatio,
+		SizingKellyCap:              config.Sizing.KellyCap,
+		SizingRiskPerTrade:          config.Sizing.RiskPerTrade,
+	}
+}
+
+// lookupBot returns the bot by the id argument, responding with 404 if there's no such bot
+func lookupBot(c *gin.Context) (*bot.Bot, bool) {
+	id := c.Query("id")
+	app.Bots.Lock.RLock()
+	b, ok := app.Bots.Table[id]
+	app.Bots.Lock.RUnlock()
+	if !ok {
 		_, _ = c.Writer.WriteString(marshalResponse(
-			http.StatusBadRequest,
-			"No known strategy specified",
+			http.StatusNotFound,
+			"Bot #"+id+" does not exist",
 		))
+	}
+	return b, ok
+}
+
+func StartBot(c *gin.Context) {
+	b, ok := lookupBot(c)
+	if !ok {
 		return
 	}
+	go b.Serve(app.Context())
+	_, _ = c.Writer.WriteString("ok")
+}
 
+func GetBotState(c *gin.Context) {
+	b, ok := lookupBot(c)
+	if !ok {
+		return
+	}
 	_, _ = c.Writer.WriteString(marshalResponse(
 		http.StatusOK,
 		"",
-		struct {
-			Id   string `json:"id"`
-			Name string `json:"name"`
-		}{fmt.Sprint(botId), name},
+		b.GetStatus(),
 	))
-
-	botId++
-	mu.Unlock()
 }
 
-func StartBot(c *gin.Context) {
-	id := c.Query("id")
-	go app.Bots.Table[id].Serve()
+func TogglePauseBot(c *gin.Context) {
+	b, ok := lookupBot(c)
+	if !ok {
+		return
+	}
+	b.TogglePause()
+
 	_, _ = c.Writer.WriteString("ok")
 }
 
-func TogglePauseBot(c *gin.Context) {
+func RemoveBot(c *gin.Context) {
 	id := c.Query("id")
-	app.Bots.Table[id].TogglePause()
+	removeBot(id)
 
 	_, _ = c.Writer.WriteString("ok")
 }
 
-func RemoveBot(c *gin.Context) {
-	id := c.Query("id")
-	if app.Bots.Table[id].IsPaused() {
-		app.Bots.Table[id].TogglePause()
-	}
-	app.Bots.Table[id].Remove()
+func removeBot(id string) {
 	app.Bots.Lock.Lock()
+	b, ok := app.Bots.Table[id]
 	delete(app.Bots.Table, id)
 	app.Bots.Lock.Unlock()
+	if !ok {
+		return
+	}
+	if b.IsPaused() {
+		b.TogglePause()
+	}
+	b.Remove()
+}
 
-	_, _ = c.Writer.WriteString("ok")
+// createBots creates a bot for every FIGI with the same settings, optionally starting them
+func createBots(args createBotArgs, figis []string, instrumentTypes []utils.InstrumentType, start bool) []createdBotResult {
+	results := make([]createdBotResult, 0, len(figis))
+	for i, figi := range figis {
+		botArgs := args
+		botArgs.Figi = figi
+		if instrumentTypes != nil {
+			botArgs.InstrumentType = instrumentTypes[i]
+		}
+		created, status, message, _ := createBot(botArgs)
+		if status != http.StatusOK {
+			results = append(results, createdBotResult{Figi: figi, Error: message})
+			continue
+		}
+		if start {
+			go created.bot.Serve(app.Context())
+		}
+		results = append(results, createdBotResult{Figi: figi, Id: created.Id, Name: created.Name})
+	}
+	return results
+}
+
+type createdBotResult struct {
+	Figi  string `json:"figi"`
+	Id    string `json:"id,omitempty"`
+	Name  string `json:"name,omitempty"`
+	Error string `json:"error,omitempty"`
 }
@@ -0,0 +1,17 @@
+package api
+
+import (
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"tinkoff-invest-contest/internal/execution"
+)
+
+// GetExecutionQuality returns slippage, signal-to-fill time and fill ratio stats per bot, instrument and order type,
+// along with the most recent fills
+func GetExecutionQuality(c *gin.Context) {
+	_, _ = c.Writer.WriteString(marshalResponse(
+		http.StatusOK,
+		"",
+		execution.Quality.Summary(),
+	))
+}
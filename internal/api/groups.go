@@ -0,0 +1,193 @@
+package api
+
+import (
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"sort"
+	"strings"
+	"tinkoff-invest-contest/internal/app"
+	"tinkoff-invest-contest/internal/bot"
+	"tinkoff-invest-contest/internal/position"
+)
+
+type createdGroup struct {
+	// Nil if no bots were created
+	Group *app.Group         `json:"group"`
+	Bots  []createdBotResult `json:"bots"`
+}
+
+// addGroup registers a group of the bots that were created successfully, if there are any
+func addGroup(name string, results []createdBotResult) *app.Group {
+	var botIds []string
+	for _, result := range results {
+		if result.Error == "" {
+			botIds = append(botIds, result.Id)
+		}
+	}
+	if len(botIds) == 0 {
+		return nil
+	}
+	return app.Groups.Add(name, botIds)
+}
+
+// CreateBotGroup creates a bot with the same settings for every FIGI of the comma-separated figis
+func CreateBotGroup(c *gin.Context) {
+	args := struct {
+		createBotArgs
+		Figis     string `form:"figis"`
+		GroupName string `form:"groupName"`
+		Start     bool   `form:"start"`
+	}{}
+	err := c.Bind(&args)
+	if err != nil {
+		_, _ = c.Writer.WriteString(marshalResponse(
+			http.StatusBadRequest,
+			"One or more arguments are invalid ("+err.Error()+")",
+		))
+		return
+	}
+	var figis []string
+	for _, figi := range strings.Split(args.Figis, ",") {
+		if figi = strings.TrimSpace(figi); figi != "" {
+			figis = append(figis, figi)
+		}
+	}
+	if len(figis) == 0 {
+		_, _ = c.Writer.WriteString(marshalResponse(
+			http.StatusBadRequest,
+			"No FIGIs specified",
+		))
+		return
+	}
+
+	botResults := createBots(args.createBotArgs, figis, nil, args.Start)
+	group := addGroup(args.GroupName, botResults)
+	if group == nil {
+		_, _ = c.Writer.WriteString(marshalResponse(
+			http.StatusBadRequest,
+			"No bots were created ("+botResults[0].Error+")",
+			createdGroup{nil, botResults},
+		))
+		return
+	}
+	_, _ = c.Writer.WriteString(marshalResponse(
+		http.StatusOK,
+		"",
+		createdGroup{group, botResults},
+	))
+}
+
+func GetBotGroups(c *gin.Context) {
+	app.Groups.Lock.RLock()
+	groups := make([]*app.Group, 0, len(app.Groups.Table))
+	for _, group := range app.Groups.Table {
+		groups = append(groups, group)
+	}
+	app.Groups.Lock.RUnlock()
+	sort.Slice(groups, func(i, j int) bool {
+		return groups[i].Name < groups[j].Name
+	})
+	_, _ = c.Writer.WriteString(marshalResponse(
+		http.StatusOK,
+		"",
+		groups,
+	))
+}
+
+// forEachGroupBot calls f for every bot of the group with the given id that still exists
+func forEachGroupBot(c *gin.Context, f func(b *bot.Bot)) (*app.Group, bool) {
+	id := c.Query("id")
+	group, ok := app.Groups.Get(id)
+	if !ok {
+		_, _ = c.Writer.WriteString(marshalResponse(
+			http.StatusNotFound,
+			"Group #"+id+" does not exist",
+		))
+		return nil, false
+	}
+	for _, botId := range group.BotIds {
+		app.Bots.Lock.RLock()
+		b, exists := app.Bots.Table[botId]
+		app.Bots.Lock.RUnlock()
+		if exists {
+			f(b)
+		}
+	}
+	return group, true
+}
+
+func StartBotGroup(c *gin.Context) {
+	_, ok := forEachGroupBot(c, func(b *bot.Bot) {
+		if !b.IsStarted() {
+			go b.Serve(app.Context())
+		}
+	})
+	if ok {
+		_, _ = c.Writer.WriteString("ok")
+	}
+}
+
+func PauseBotGroup(c *gin.Context) {
+	_, ok := forEachGroupBot(c, func(b *bot.Bot) {
+		if b.IsStarted() && !b.IsPaused() {
+			b.TogglePause()
+		}
+	})
+	if ok {
+		_, _ = c.Writer.WriteString("ok")
+	}
+}
+
+func ResumeBotGroup(c *gin.Context) {
+	_, ok := forEachGroupBot(c, func(b *bot.Bot) {
+		if b.IsPaused() {
+			b.TogglePause()
+		}
+	})
+	if ok {
+		_, _ = c.Writer.WriteString("ok")
+	}
+}
+
+// RemoveBotGroup removes the group along with its bots
+func RemoveBotGroup(c *gin.Context) {
+	group, ok := app.Groups.Get(c.Query("id"))
+	if !ok {
+		_, _ = c.Writer.WriteString(marshalResponse(
+			http.StatusNotFound,
+			"Group #"+c.Query("id")+" does not exist",
+		))
+		return
+	}
+	for _, botId := range group.BotIds {
+		removeBot(botId)
+	}
+	app.Groups.Remove(group.Id)
+	_, _ = c.Writer.WriteString("ok")
+}
+
+// GetBotGroupPnL returns PnL of every bot of the group and the totals per currency
+func GetBotGroupPnL(c *gin.Context) {
+	var bots []bot.PnL
+	totals := make(map[string]*position.Totals)
+	group, ok := forEachGroupBot(c, func(b *bot.Bot) {
+		pnl := b.GetPnL()
+		bots = append(bots, pnl)
+		if _, exists := totals[pnl.Currency]; !exists {
+			totals[pnl.Currency] = &position.Totals{}
+		}
+		totals[pnl.Currency].Add(pnl.Snapshot)
+	})
+	if !ok {
+		return
+	}
+	_, _ = c.Writer.WriteString(marshalResponse(
+		http.StatusOK,
+		"",
+		struct {
+			Group  *app.Group                  `json:"group"`
+			Bots   []bot.PnL                   `json:"bots"`
+			Totals map[string]*position.Totals `json:"totals"`
+		}{group, bots, totals},
+	))
+}
@@ -0,0 +1,58 @@
+package api
+
+import (
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"tinkoff-invest-contest/internal/app"
+)
+
+const (
+	defaultSearchLimit = 20
+	maxSearchLimit     = 100
+)
+
+// SearchInstruments finds instruments by FIGI, ticker, ISIN or name for autocompletion
+func SearchInstruments(c *gin.Context) {
+	args := struct {
+		Query string `form:"q"`
+		Limit int    `form:"limit"`
+	}{}
+	err := c.Bind(&args)
+	if err != nil {
+		_, _ = c.Writer.WriteString(marshalResponse(
+			http.StatusBadRequest,
+			"One or more arguments are invalid ("+err.Error()+")",
+		))
+		return
+	}
+	if args.Limit <= 0 {
+		args.Limit = defaultSearchLimit
+	}
+	if args.Limit > maxSearchLimit {
+		args.Limit = maxSearchLimit
+	}
+	_, _ = c.Writer.WriteString(marshalResponse(
+		http.StatusOK,
+		"",
+		app.Catalog.Search(args.Query, args.Limit),
+	))
+}
+
+// RefreshInstruments reloads the catalog from the API
+func RefreshInstruments(c *gin.Context) {
+	err := app.Catalog.Refresh(app.CombatEnv.Client)
+	if err != nil {
+		_, _ = c.Writer.WriteString(marshalResponse(
+			http.StatusInternalServerError,
+			"Couldn't refresh instruments ("+err.Error()+")",
+		))
+		return
+	}
+	_, _ = c.Writer.WriteString(marshalResponse(
+		http.StatusOK,
+		"",
+		struct {
+			Instruments int `json:"instruments"`
+		}{app.Catalog.Len()},
+	))
+}
@@ -0,0 +1,107 @@
+package api
+
+import (
+	"github.com/gin-gonic/gin"
+	"log"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"tinkoff-invest-contest/internal/app"
+	"tinkoff-invest-contest/internal/recording"
+)
+
+// recordingPaths returns paths of the environment's recordings by file name, all of them if names are empty
+func recordingPaths(sandbox bool, names []string) ([]string, int, string) {
+	dir := app.RecordingsDir(sandbox)
+	if dir == "" {
+		return nil, http.StatusNotFound, "Market data isn't recorded, set MARKET_DATA_RECORDINGS to record it"
+	}
+	paths, err := recording.List(dir)
+	if err != nil {
+		return nil, http.StatusInternalServerError, "Couldn't list recordings (" + err.Error() + ")"
+	}
+	if len(names) == 0 {
+		return paths, http.StatusOK, ""
+	}
+	byName := make(map[string]string, len(paths))
+	for _, path := range paths {
+		byName[filepath.Base(path)] = path
+	}
+	selected := make([]string, 0, len(names))
+	for _, name := range names {
+		path, ok := byName[name]
+		if !ok {
+			return nil, http.StatusNotFound, "No recording '" + name + "'"
+		}
+		selected = append(selected, path)
+	}
+	return selected, http.StatusOK, ""
+}
+
+func GetRecordings(c *gin.Context) {
+	paths, status, message := recordingPaths(c.Query("sandbox") == "true", nil)
+	if status != http.StatusOK {
+		_, _ = c.Writer.WriteString(marshalResponse(status, message))
+		return
+	}
+	names := make([]string, 0, len(paths))
+	for _, path := range paths {
+		names = append(names, filepath.Base(path))
+	}
+	_, _ = c.Writer.WriteString(marshalResponse(
+		http.StatusOK,
+		"",
+		names,
+	))
+}
+
+// ReplayRecordings feeds recordings of the environment to the bots of the replay environment in the background.
+// Bots of the other environments never get replayed data, so that they don't trade on it
+func ReplayRecordings(c *gin.Context) {
+	args := struct {
+		Sandbox bool `form:"sandbox"`
+		// Comma-separated file names, all recordings if empty
+		Files string `form:"files"`
+		// How many times faster than the original, 0 for as fast as possible
+		Speed float64 `form:"speed,default=1"`
+	}{}
+	err := c.Bind(&args)
+	if err != nil {
+		_, _ = c.Writer.WriteString(marshalResponse(
+			http.StatusBadRequest,
+			"One or more arguments are invalid ("+err.Error()+")",
+		))
+		return
+	}
+	if args.Speed < 0 {
+		_, _ = c.Writer.WriteString(marshalResponse(http.StatusBadRequest, "Speed can't be negative"))
+		return
+	}
+	var names []string
+	for _, name := range strings.Split(args.Files, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			names = append(names, name)
+		}
+	}
+	paths, status, message := recordingPaths(args.Sandbox, names)
+	if status != http.StatusOK {
+		_, _ = c.Writer.WriteString(marshalResponse(status, message))
+		return
+	}
+
+	go func() {
+		n, err := app.ReplayEnv.ReplayMarketData(app.Context(), recording.Open(paths...), args.Speed)
+		if err != nil {
+			log.Printf("market data replay stopped after %v events: %v", n, err)
+			return
+		}
+		log.Printf("market data replay completed, %v events", n)
+	}()
+	_, _ = c.Writer.WriteString(marshalResponse(
+		http.StatusOK,
+		"",
+		struct {
+			Files int `json:"files"`
+		}{len(paths)},
+	))
+}
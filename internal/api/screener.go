@@ -0,0 +1,167 @@
+package api
+
+import (
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"log"
+	"net/http"
+	"tinkoff-invest-contest/internal/app"
+	"tinkoff-invest-contest/internal/screener"
+	"tinkoff-invest-contest/internal/utils"
+)
+
+// maxBotsFromScreen limits the number of bots created by one request
+const maxBotsFromScreen = 20
+
+type screenArgs struct {
+	Type          string  `form:"type"`
+	Exchange      string  `form:"exchange"`
+	Currency      string  `form:"currency"`
+	Sector        string  `form:"sector"`
+	MinLot        int32   `form:"minLot"`
+	MaxLot        int32   `form:"maxLot"`
+	ShortEnabled  bool    `form:"shortEnabled"`
+	Tradable      bool    `form:"tradable"`
+	MinTurnover   float64 `form:"minTurnover"`
+	MinATRPercent float64 `form:"minAtrPercent"`
+	MaxATRPercent float64 `form:"maxAtrPercent"`
+	MaxSpreadBps  float64 `form:"maxSpreadBps"`
+	Days          int     `form:"days"`
+
+	SortBy     string `form:"sortBy"`
+	Descending bool   `form:"descending"`
+}
+
+// screen returns the results along with a notice of instruments that weren't screened, if any
+func (args screenArgs) screen(limit int) ([]screener.Result, string, error) {
+	sortKey, err := screener.StringToSortKey(args.SortBy)
+	if err != nil {
+		return nil, "", err
+	}
+	results, notScreened, err := app.Screener.Screen(screener.Filter{
+		Type:          args.Type,
+		Exchange:      args.Exchange,
+		Currency:      args.Currency,
+		Sector:        args.Sector,
+		MinLot:        args.MinLot,
+		MaxLot:        args.MaxLot,
+		ShortEnabled:  args.ShortEnabled,
+		Tradable:      args.Tradable,
+		MinTurnover:   args.MinTurnover,
+		MinATRPercent: args.MinATRPercent,
+		MaxATRPercent: args.MaxATRPercent,
+		MaxSpreadBps:  args.MaxSpreadBps,
+		Days:          args.Days,
+	}, sortKey, args.Descending, limit)
+	if err != nil || notScreened == 0 {
+		return results, "", err
+	}
+	return results, fmt.Sprintf("%v more instruments match the filter but weren't screened, only the first %v by ticker are, "+
+		"narrow the filter down to screen the rest", notScreened, screener.MaxCandidates), nil
+}
+
+// ScreenInstruments filters and sorts instruments of the catalog, format=csv exports the results as a CSV file
+func ScreenInstruments(c *gin.Context) {
+	args := struct {
+		screenArgs
+		Limit  int    `form:"limit"`
+		Format string `form:"format"`
+	}{}
+	err := c.Bind(&args)
+	if err != nil {
+		_, _ = c.Writer.WriteString(marshalResponse(
+			http.StatusBadRequest,
+			"One or more arguments are invalid ("+err.Error()+")",
+		))
+		return
+	}
+	results, notice, err := args.screen(args.Limit)
+	if err != nil {
+		_, _ = c.Writer.WriteString(marshalResponse(
+			http.StatusBadRequest,
+			"Couldn't screen instruments ("+err.Error()+")",
+		))
+		return
+	}
+	if args.Format == "csv" {
+		c.Header("Content-Type", "text/csv")
+		c.Header("Content-Disposition", "attachment; filename=screener.csv")
+		if notice != "" {
+			c.Header("Warning", `199 - "`+notice+`"`)
+		}
+		// The status has been sent with the first bytes, so the error can only be logged
+		err = screener.WriteCSV(c.Writer, results)
+		if err != nil {
+			log.Println("screener: can't write CSV:", err)
+		}
+		return
+	}
+	_, _ = c.Writer.WriteString(marshalResponse(
+		http.StatusOK,
+		notice,
+		results,
+	))
+}
+
+// CreateBotsFromScreen creates a group of bots with the same settings for the top results of the screener,
+// optionally starting them
+func CreateBotsFromScreen(c *gin.Context) {
+	args := struct {
+		createBotArgs
+		screenArgs
+		Top   int  `form:"top"`
+		Start bool `form:"start"`
+	}{}
+	err := c.Bind(&args)
+	if err != nil {
+		_, _ = c.Writer.WriteString(marshalResponse(
+			http.StatusBadRequest,
+			"One or more arguments are invalid ("+err.Error()+")",
+		))
+		return
+	}
+	if args.Top < 1 || args.Top > maxBotsFromScreen {
+		_, _ = c.Writer.WriteString(marshalResponse(
+			http.StatusBadRequest,
+			fmt.Sprintf("Number of bots must be from 1 to %v", maxBotsFromScreen),
+		))
+		return
+	}
+	results, notice, err := args.screen(args.Top)
+	if err != nil {
+		_, _ = c.Writer.WriteString(marshalResponse(
+			http.StatusBadRequest,
+			"Couldn't screen instruments ("+err.Error()+")",
+		))
+		return
+	}
+	if len(results) == 0 {
+		_, _ = c.Writer.WriteString(marshalResponse(
+			http.StatusBadRequest,
+			"No instruments match the filter",
+		))
+		return
+	}
+
+	figis := make([]string, 0, len(results))
+	instrumentTypes := make([]utils.InstrumentType, 0, len(results))
+	for _, result := range results {
+		figis = append(figis, result.Figi)
+		instrumentTypes = append(instrumentTypes, result.Type)
+	}
+	botResults := createBots(args.createBotArgs, figis, instrumentTypes, args.Start)
+	group := addGroup(fmt.Sprintf("screener top %v", args.Top), botResults)
+	if group == nil {
+		_, _ = c.Writer.WriteString(marshalResponse(
+			http.StatusBadRequest,
+			"No bots were created ("+botResults[0].Error+")",
+			createdGroup{nil, botResults},
+		))
+		return
+	}
+	_, _ = c.Writer.WriteString(marshalResponse(
+		http.StatusOK,
+		notice,
+		createdGroup{group, botResults},
+	))
+}
@@ -3,6 +3,7 @@ package api
 import (
 	"encoding/json"
 	"github.com/gin-gonic/gin"
+	"net/http"
 	"tinkoff-invest-contest/internal/strategies"
 )
 
@@ -19,3 +20,20 @@ func GetStrategyDefaults(c *gin.Context) {
 	}
 	_, _ = c.Writer.WriteString(s)
 }
+
+func GetStrategySchema(c *gin.Context) {
+	name := c.Query("name")
+	schema, ok := strategies.Schemas[name]
+	if !ok {
+		_, _ = c.Writer.WriteString(marshalResponse(
+			http.StatusNotFound,
+			"No known strategy '"+name+"'",
+		))
+		return
+	}
+	_, _ = c.Writer.WriteString(marshalResponse(
+		http.StatusOK,
+		"",
+		schema,
+	))
+}
@@ -1,9 +1,19 @@
 package app
 
 import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
 	"sync"
-	"tinkoff-invest-contest/internal/appstate"
+	"time"
 	"tinkoff-invest-contest/internal/bot"
+	"tinkoff-invest-contest/internal/catalog"
+	"tinkoff-invest-contest/internal/paper"
+	"tinkoff-invest-contest/internal/recording"
+	"tinkoff-invest-contest/internal/screener"
 	"tinkoff-invest-contest/internal/tradeenv"
 	"tinkoff-invest-contest/internal/utils"
 
@@ -13,6 +23,11 @@ import (
 	_ "tinkoff-invest-contest/internal/strategies/kwatoko"
 )
 
+const (
+	defaultShutdownTimeout     = 30 * time.Second
+	defaultInstrumentsSnapshot = "instruments.json"
+)
+
 type botsTable struct {
 	Lock  sync.RWMutex
 	Table map[string]*bot.Bot
@@ -21,14 +36,176 @@ type botsTable struct {
 var (
 	SandboxEnv *tradeenv.TradeEnv
 	CombatEnv  *tradeenv.TradeEnv
+	PaperEnv   *tradeenv.TradeEnv
+	ReplayEnv  *tradeenv.TradeEnv // Paper trading on replayed market data only, isolated from the streams
 	Bots       *botsTable
+	Groups     *groupsTable
+	Catalog    *catalog.Catalog
+	Screener   *screener.Screener
+
+	ctx       context.Context
+	stopEnvs  context.CancelFunc
+	recorders []*recording.Recorder
 )
 
-func init() {
-	appstate.ExitActionsWG.Add(1)
-	SandboxEnv = tradeenv.New(utils.GetSandboxToken(), true)
-	CombatEnv = tradeenv.New(utils.GetCombatToken(), false)
+// Init sets up trade environments and bots run until appCtx is done.
+// Environments' streams outlive it until Shutdown, so that orders placed on shutdown get their fills
+func Init(appCtx context.Context) error {
+	var err error
+	ctx = appCtx
+	var envCtx context.Context
+	envCtx, stopEnvs = context.WithCancel(context.Background())
+	// Connecting is given up if the app is stopped during initialization
+	initialized := make(chan struct{})
+	defer close(initialized)
+	go func() {
+		select {
+		case <-appCtx.Done():
+			stopEnvs()
+		case <-initialized:
+		}
+	}()
+	SandboxEnv, err = tradeenv.New(envCtx, utils.GetSandboxToken(), true)
+	if err != nil {
+		return fmt.Errorf("sandbox environment: %w", err)
+	}
+	CombatEnv, err = tradeenv.New(envCtx, utils.GetCombatToken(), false)
+	if err != nil {
+		return fmt.Errorf("combat environment: %w", err)
+	}
+	paperTradingFee := GetPaperTradingFee(CombatEnv.Fee)
+	PaperEnv, err = tradeenv.NewPaper(envCtx, utils.GetSandboxToken(), paper.NewVenue(paperTradingFee))
+	if err != nil {
+		return fmt.Errorf("paper environment: %w", err)
+	}
+	ReplayEnv, err = tradeenv.NewReplay(envCtx, utils.GetSandboxToken(), paper.NewVenue(paperTradingFee))
+	if err != nil {
+		return fmt.Errorf("replay environment: %w", err)
+	}
 	Bots = &botsTable{
 		Table: make(map[string]*bot.Bot),
 	}
+	Groups = &groupsTable{
+		Table: make(map[string]*Group),
+	}
+	initCatalog()
+	initRecorders()
+	Screener = screener.New(Catalog, CombatEnv.Client)
+	return nil
+}
+
+// initCatalog loads the instruments snapshot and refreshes the catalog from the API in the background
+func initCatalog() {
+	Catalog = catalog.New()
+	path := os.Getenv("INSTRUMENTS_SNAPSHOT")
+	if path == "" {
+		path = defaultInstrumentsSnapshot
+	}
+	err := Catalog.LoadSnapshot(path)
+	if err != nil {
+		log.Printf("can't load instruments snapshot: %v", err)
+	}
+	go func() {
+		err := Catalog.Refresh(CombatEnv.Client)
+		if err != nil {
+			log.Printf("can't refresh instruments catalog: %v", err)
+			return
+		}
+		log.Printf("instruments catalog refreshed, %v instruments", Catalog.Len())
+	}()
+}
+
+// RecordingsDir returns the directory market data of the environment is recorded to, empty if it isn't recorded
+func RecordingsDir(sandbox bool) string {
+	dir := os.Getenv("MARKET_DATA_RECORDINGS")
+	if dir == "" {
+		return ""
+	}
+	if sandbox {
+		return filepath.Join(dir, "sandbox")
+	}
+	return filepath.Join(dir, "combat")
+}
+
+// initRecorders makes trade environments record their market data if MARKET_DATA_RECORDINGS is set
+func initRecorders() {
+	for _, env := range []*tradeenv.TradeEnv{SandboxEnv, CombatEnv} {
+		dir := RecordingsDir(env.IsSandbox())
+		if dir == "" {
+			continue
+		}
+		recorder, err := recording.NewRecorder(dir)
+		if err != nil {
+			log.Printf("can't record market data: %v", err)
+			continue
+		}
+		env.SetRecorder(recorder)
+		recorders = append(recorders, recorder)
+	}
+}
+
+// GetPaperTradingFee returns the commission rate of paper trading, PAPER_TRADING_FEE or defaultFee if it isn't set
+func GetPaperTradingFee(defaultFee float64) float64 {
+	s := os.Getenv("PAPER_TRADING_FEE")
+	if s == "" {
+		return defaultFee
+	}
+	fee, err := strconv.ParseFloat(s, 64)
+	if err != nil || fee < 0 {
+		log.Printf("invalid fee in PAPER_TRADING_FEE: %q", s)
+		return defaultFee
+	}
+	return fee
+}
+
+// Context is done when the app is shutting down
+func Context() context.Context {
+	return ctx
+}
+
+// GetShutdownTimeout returns the time given to bots to complete their orders and apply shutdown policies
+func GetShutdownTimeout() time.Duration {
+	s := os.Getenv("SHUTDOWN_TIMEOUT")
+	if s == "" {
+		return defaultShutdownTimeout
+	}
+	timeout, err := time.ParseDuration(s)
+	if err != nil {
+		log.Printf("invalid duration in SHUTDOWN_TIMEOUT: %v", err)
+		return defaultShutdownTimeout
+	}
+	return timeout
+}
+
+// Shutdown applies bots' shutdown policies concurrently, logs the summaries and releases trade environments.
+// Bots not done by the time shutdownCtx is done are abandoned
+func Shutdown(shutdownCtx context.Context) {
+	start := time.Now()
+	var wg sync.WaitGroup
+	Bots.Lock.RLock()
+	for _, b := range Bots.Table {
+		wg.Add(1)
+		go func(b *bot.Bot) {
+			defer wg.Done()
+			log.Println(b.Shutdown(shutdownCtx))
+		}(b)
+	}
+	Bots.Lock.RUnlock()
+	wg.Wait()
+
+	stopEnvs()
+
+	SandboxEnv.SetRecorder(nil)
+	CombatEnv.SetRecorder(nil)
+	SandboxEnv.Close()
+	CombatEnv.Close()
+	PaperEnv.Close()
+	ReplayEnv.Close()
+	for _, recorder := range recorders {
+		err := recorder.Close()
+		if err != nil {
+			log.Printf("can't close market data recording: %v", err)
+		}
+	}
+	log.Printf("shutdown completed in %v", time.Since(start).Round(time.Millisecond))
 }
@@ -0,0 +1,49 @@
+package app
+
+import (
+	"fmt"
+	"sync"
+)
+
+// Group is a set of bots created by one request with the same settings
+type Group struct {
+	Id     string   `json:"id"`
+	Name   string   `json:"name"`
+	BotIds []string `json:"botIds"`
+}
+
+type groupsTable struct {
+	Lock   sync.RWMutex
+	Table  map[string]*Group
+	nextId int
+}
+
+// Add registers a group of the bots, an empty name is replaced with the group's id
+func (t *groupsTable) Add(name string, botIds []string) *Group {
+	t.Lock.Lock()
+	defer t.Lock.Unlock()
+	group := &Group{
+		Id:     fmt.Sprint(t.nextId),
+		Name:   name,
+		BotIds: botIds,
+	}
+	if group.Name == "" {
+		group.Name = "group #" + group.Id
+	}
+	t.Table[group.Id] = group
+	t.nextId++
+	return group
+}
+
+func (t *groupsTable) Get(id string) (*Group, bool) {
+	t.Lock.RLock()
+	defer t.Lock.RUnlock()
+	group, ok := t.Table[id]
+	return group, ok
+}
+
+func (t *groupsTable) Remove(id string) {
+	t.Lock.Lock()
+	defer t.Lock.Unlock()
+	delete(t.Table, id)
+}
@@ -1,9 +0,0 @@
-package appstate
-
-import "sync"
-
-var ShouldExit = false
-var NoInternetConnection = false
-
-var ExitActionsWG sync.WaitGroup
-var PostExitActionsWG sync.WaitGroup
@@ -0,0 +1,57 @@
+package auth
+
+import (
+	"github.com/gin-gonic/gin"
+	"io"
+	"log"
+	"net/http"
+	"os"
+)
+
+// Until InitAudit is called, the audit is only written to stdout
+var auditLogger = log.New(os.Stdout, "[audit] ", log.LstdFlags)
+
+// GetAuditFilePath returns the path of the audit file provided via 'AUDIT_FILE' environment variable,
+// or "audit" if it is not set
+func GetAuditFilePath() string {
+	path := os.Getenv("AUDIT_FILE")
+	if path == "" {
+		path = "audit"
+	}
+	return path
+}
+
+// InitAudit opens the audit file for appending, the audit is written both to it and to stdout
+func InitAudit(path string) error {
+	auditFile, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	auditLogger = log.New(io.MultiWriter(os.Stdout, auditFile), "[audit] ", log.LstdFlags)
+	return nil
+}
+
+// Audit returns a middleware that records who performed a request and with what outcome.
+// It should be placed after Require, so that the user is known
+func Audit() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Next()
+		auditRequest(c, GetUser(c), c.Writer.Status())
+	}
+}
+
+func auditRequest(c *gin.Context, user *User, status int) {
+	name, role := "anonymous", ""
+	if user != nil {
+		name, role = user.Name, RoleToString(user.Role)
+	}
+	auditLogger.Printf("user=%q role=%v ip=%v %v %v -> %v %v",
+		name,
+		role,
+		c.ClientIP(),
+		c.Request.Method,
+		c.Request.URL.RequestURI(),
+		status,
+		http.StatusText(status),
+	)
+}
@@ -0,0 +1,219 @@
+package auth
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/go-yaml/yaml"
+	"golang.org/x/crypto/bcrypt"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+)
+
+type Role int
+
+const (
+	RoleViewer Role = iota
+	RoleOperator
+)
+
+const userContextKey = "auth.user"
+
+type User struct {
+	Name string
+	Role Role
+
+	passwordHash []byte
+	tokenHash    []byte
+}
+
+type userEntry struct {
+	Name         string `yaml:"Name"`
+	Role         string `yaml:"Role"`
+	PasswordHash string `yaml:"PasswordHash"` // bcrypt hash of the basic auth password
+	TokenHash    string `yaml:"TokenHash"`    // hex-encoded SHA-256 of the API token
+}
+
+var users = struct {
+	mu    sync.RWMutex
+	table map[string]*User
+	// Successfully verified basic auth credentials, keyed by SHA-256 of "name:password",
+	// so that bcrypt is not run on every request
+	verified map[string]*User
+}{
+	table:    make(map[string]*User),
+	verified: make(map[string]*User),
+}
+
+func StringToRole(s string) (Role, error) {
+	switch strings.ToLower(s) {
+	case "viewer":
+		return RoleViewer, nil
+	case "operator":
+		return RoleOperator, nil
+	default:
+		return -1, fmt.Errorf("unknown role: %q", s)
+	}
+}
+
+func RoleToString(role Role) string {
+	switch role {
+	case RoleViewer:
+		return "viewer"
+	case RoleOperator:
+		return "operator"
+	}
+	return ""
+}
+
+// GetUsersFilePath returns the path of the users file provided via 'USERS_FILE' environment variable,
+// or "users.yaml" if it is not set
+func GetUsersFilePath() string {
+	path := os.Getenv("USERS_FILE")
+	if path == "" {
+		path = "users.yaml"
+	}
+	return path
+}
+
+// LoadUsers reads users, their roles and credential hashes from a YAML file
+func LoadUsers(path string) error {
+	bytes, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	var entries []userEntry
+	err = yaml.Unmarshal(bytes, &entries)
+	if err != nil {
+		return err
+	}
+	table := make(map[string]*User)
+	for _, entry := range entries {
+		if entry.Name == "" {
+			return fmt.Errorf("user without a name in %v", path)
+		}
+		if entry.PasswordHash == "" && entry.TokenHash == "" {
+			return fmt.Errorf("user %q has neither password hash nor token hash", entry.Name)
+		}
+		role, err := StringToRole(entry.Role)
+		if err != nil {
+			return fmt.Errorf("user %q: %v", entry.Name, err)
+		}
+		user := &User{
+			Name: entry.Name,
+			Role: role,
+		}
+		if entry.PasswordHash != "" {
+			user.passwordHash = []byte(entry.PasswordHash)
+		}
+		if entry.TokenHash != "" {
+			user.tokenHash, err = hex.DecodeString(entry.TokenHash)
+			if err != nil || len(user.tokenHash) != sha256.Size {
+				return fmt.Errorf("user %q has malformed token hash", entry.Name)
+			}
+		}
+		table[entry.Name] = user
+	}
+	if len(table) == 0 {
+		return fmt.Errorf("no users defined in %v", path)
+	}
+	users.mu.Lock()
+	users.table = table
+	users.verified = make(map[string]*User)
+	users.mu.Unlock()
+	return nil
+}
+
+// HashPassword returns a bcrypt hash of a basic auth password suitable for the users file
+func HashPassword(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", err
+	}
+	return string(hash), nil
+}
+
+// HashToken returns a hex-encoded SHA-256 of an API token suitable for the users file
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func authenticate(r *http.Request) (*User, bool) {
+	header := r.Header.Get("Authorization")
+	if strings.HasPrefix(header, "Bearer ") {
+		return authenticateToken(strings.TrimPrefix(header, "Bearer "))
+	}
+	if name, password, ok := r.BasicAuth(); ok {
+		return authenticatePassword(name, password)
+	}
+	return nil, false
+}
+
+func authenticateToken(token string) (*User, bool) {
+	sum := sha256.Sum256([]byte(token))
+	users.mu.RLock()
+	defer users.mu.RUnlock()
+	for _, user := range users.table {
+		if user.tokenHash != nil && subtle.ConstantTimeCompare(user.tokenHash, sum[:]) == 1 {
+			return user, true
+		}
+	}
+	return nil, false
+}
+
+func authenticatePassword(name string, password string) (*User, bool) {
+	key := HashToken(name + ":" + password)
+	var ok bool
+	users.mu.RLock()
+	user, verified := users.verified[key]
+	if !verified {
+		user, ok = users.table[name]
+	}
+	users.mu.RUnlock()
+	if verified {
+		return user, true
+	}
+	if !ok || user.passwordHash == nil {
+		return nil, false
+	}
+	if bcrypt.CompareHashAndPassword(user.passwordHash, []byte(password)) != nil {
+		return nil, false
+	}
+	users.mu.Lock()
+	users.verified[key] = user
+	users.mu.Unlock()
+	return user, true
+}
+
+// Require returns a middleware that rejects requests lacking valid credentials of at least the given role
+func Require(role Role) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user, ok := authenticate(c.Request)
+		if !ok {
+			c.Header("WWW-Authenticate", `Basic realm="tinkoff-invest-contest", charset="UTF-8"`)
+			c.AbortWithStatus(http.StatusUnauthorized)
+			return
+		}
+		if user.Role < role {
+			auditRequest(c, user, http.StatusForbidden)
+			c.AbortWithStatus(http.StatusForbidden)
+			return
+		}
+		c.Set(userContextKey, user)
+		c.Next()
+	}
+}
+
+// GetUser returns the user authenticated by Require, if any
+func GetUser(c *gin.Context) *User {
+	user, ok := c.Get(userContextKey)
+	if !ok {
+		return nil
+	}
+	return user.(*User)
+}
@@ -0,0 +1,148 @@
+package auth
+
+import (
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func loadTestUsers(t *testing.T) {
+	passwordHash, err := HashPassword("secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	content := "- Name: alice\n  Role: operator\n  PasswordHash: " + passwordHash + "\n" +
+		"- Name: bob\n  Role: viewer\n  TokenHash: " + HashToken("bob-token") + "\n"
+	path := filepath.Join(t.TempDir(), "users.yaml")
+	err = os.WriteFile(path, []byte(content), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = LoadUsers(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func newRequest(user string, password string, token string) *http.Request {
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	if user != "" {
+		r.SetBasicAuth(user, password)
+	}
+	if token != "" {
+		r.Header.Set("Authorization", "Bearer "+token)
+	}
+	return r
+}
+
+func Test_authenticate(t *testing.T) {
+	loadTestUsers(t)
+	tests := []struct {
+		name     string
+		request  *http.Request
+		wantUser string
+		wantOk   bool
+	}{
+		{
+			name:     "test1",
+			request:  newRequest("alice", "secret", ""),
+			wantUser: "alice",
+			wantOk:   true,
+		},
+		{
+			name:    "test2",
+			request: newRequest("alice", "wrong", ""),
+			wantOk:  false,
+		},
+		{
+			name:     "test3",
+			request:  newRequest("", "", "bob-token"),
+			wantUser: "bob",
+			wantOk:   true,
+		},
+		{
+			name:    "test4",
+			request: newRequest("", "", "wrong-token"),
+			wantOk:  false,
+		},
+		{
+			name:    "test5",
+			request: newRequest("bob", "bob-token", ""),
+			wantOk:  false,
+		},
+		{
+			name:    "test6",
+			request: newRequest("", "", ""),
+			wantOk:  false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			user, ok := authenticate(tt.request)
+			if ok != tt.wantOk {
+				t.Fatalf("authenticate() ok = %v, want %v", ok, tt.wantOk)
+			}
+			if ok && user.Name != tt.wantUser {
+				t.Errorf("authenticate() user = %v, want %v", user.Name, tt.wantUser)
+			}
+		})
+	}
+}
+
+func TestRequire(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	loadTestUsers(t)
+	tests := []struct {
+		name       string
+		role       Role
+		request    *http.Request
+		wantStatus int
+	}{
+		{
+			name:       "test1",
+			role:       RoleViewer,
+			request:    newRequest("", "", "bob-token"),
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "test2",
+			role:       RoleOperator,
+			request:    newRequest("", "", "bob-token"),
+			wantStatus: http.StatusForbidden,
+		},
+		{
+			name:       "test3",
+			role:       RoleOperator,
+			request:    newRequest("alice", "secret", ""),
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "test4",
+			role:       RoleViewer,
+			request:    newRequest("", "", ""),
+			wantStatus: http.StatusUnauthorized,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router := gin.New()
+			router.GET("/", Require(tt.role), func(c *gin.Context) {
+				if GetUser(c) == nil {
+					t.Error("GetUser() = nil in a handler behind Require")
+				}
+				c.Status(http.StatusOK)
+			})
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, tt.request)
+			if w.Code != tt.wantStatus {
+				t.Errorf("status = %v, want %v", w.Code, tt.wantStatus)
+			}
+			if tt.wantStatus == http.StatusUnauthorized && w.Header().Get("WWW-Authenticate") == "" {
+				t.Error("WWW-Authenticate header is not set")
+			}
+		})
+	}
+}
@@ -0,0 +1,31 @@
+package auth
+
+import (
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// CheckOrigin accepts requests without Origin header (non-browser clients),
+// same-host requests, and origins listed in 'ALLOWED_ORIGINS' environment variable (comma-separated)
+func CheckOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	u, err := url.Parse(origin)
+	if err != nil {
+		return false
+	}
+	if strings.EqualFold(u.Host, r.Host) {
+		return true
+	}
+	for _, allowed := range strings.Split(os.Getenv("ALLOWED_ORIGINS"), ",") {
+		allowed = strings.TrimRight(strings.TrimSpace(allowed), "/")
+		if allowed != "" && strings.EqualFold(allowed, u.Scheme+"://"+u.Host) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,66 @@
+package auth
+
+import (
+	"net/http"
+	"os"
+	"testing"
+)
+
+func TestCheckOrigin(t *testing.T) {
+	type args struct {
+		host           string
+		origin         string
+		allowedOrigins string
+	}
+	tests := []struct {
+		name string
+		args args
+		want bool
+	}{
+		{
+			name: "test1",
+			args: args{
+				host:   "localhost:3001",
+				origin: "",
+			},
+			want: true,
+		},
+		{
+			name: "test2",
+			args: args{
+				host:   "localhost:3001",
+				origin: "http://localhost:3001",
+			},
+			want: true,
+		},
+		{
+			name: "test3",
+			args: args{
+				host:   "localhost:3001",
+				origin: "http://evil.example.com",
+			},
+			want: false,
+		},
+		{
+			name: "test4",
+			args: args{
+				host:           "localhost:3001",
+				origin:         "http://localhost:3000",
+				allowedOrigins: "http://grafana:3000, http://localhost:3000/",
+			},
+			want: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_ = os.Setenv("ALLOWED_ORIGINS", tt.args.allowedOrigins)
+			r := &http.Request{Host: tt.args.host, Header: http.Header{}}
+			if tt.args.origin != "" {
+				r.Header.Set("Origin", tt.args.origin)
+			}
+			if got := CheckOrigin(r); got != tt.want {
+				t.Errorf("CheckOrigin() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,117 @@
+package bars
+
+import (
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"time"
+	"tinkoff-invest-contest/internal/client/investapi"
+	"tinkoff-invest-contest/internal/utils"
+)
+
+// Aggregator builds candles of an interval from shorter candles, which may come as a stream,
+// where the latest candle is updated until the next one begins
+type Aggregator struct {
+	interval Interval
+	session  Session
+
+	start time.Time
+	// Aggregate of the candle's source candles except the latest one
+	closed *investapi.HistoricCandle
+	latest *investapi.HistoricCandle
+}
+
+func NewAggregator(interval Interval, session Session) *Aggregator {
+	return &Aggregator{
+		interval: interval,
+		session:  session,
+	}
+}
+
+// Add adds a source candle, or updates the latest one if it has the same time. Returns the candle it belongs to
+// as of now, and the previous candle if this one has started a new one
+func (a *Aggregator) Add(source *investapi.HistoricCandle) (current *investapi.HistoricCandle,
+	completed *investapi.HistoricCandle) {
+	if a.latest != nil && source.Time.AsTime().Before(a.latest.Time.AsTime()) {
+		// Out of order
+		return a.Current(), nil
+	}
+	start := a.session.Start(a.interval, source.Time.AsTime())
+	switch {
+	case a.latest == nil:
+	case !start.Equal(a.start):
+		completed = a.Current()
+		completed.IsComplete = true
+		a.closed, a.latest = nil, nil
+	case !source.Time.AsTime().Equal(a.latest.Time.AsTime()):
+		a.closed = merge(a.closed, a.latest)
+	}
+	a.start, a.latest = start, source
+	return a.Current(), completed
+}
+
+// Current returns the candle being built, nil if there's none
+func (a *Aggregator) Current() *investapi.HistoricCandle {
+	if a.latest == nil {
+		return nil
+	}
+	candle := merge(a.closed, a.latest)
+	candle.Time = timestamppb.New(a.start)
+	candle.IsComplete = false
+	return candle
+}
+
+// Reset forgets the candle being built, e.g. after a gap in the stream
+func (a *Aggregator) Reset() {
+	a.closed, a.latest = nil, nil
+}
+
+// merge returns a new candle spanning a and b, b being the later one. a may be nil
+func merge(a *investapi.HistoricCandle, b *investapi.HistoricCandle) *investapi.HistoricCandle {
+	if a == nil {
+		return &investapi.HistoricCandle{
+			Open:       b.Open,
+			High:       b.High,
+			Low:        b.Low,
+			Close:      b.Close,
+			Volume:     b.Volume,
+			Time:       b.Time,
+			IsComplete: b.IsComplete,
+		}
+	}
+	candle := &investapi.HistoricCandle{
+		Open:       a.Open,
+		High:       a.High,
+		Low:        a.Low,
+		Close:      b.Close,
+		Volume:     a.Volume + b.Volume,
+		Time:       a.Time,
+		IsComplete: b.IsComplete,
+	}
+	if utils.QuotationToFloat(b.High) > utils.QuotationToFloat(a.High) {
+		candle.High = b.High
+	}
+	if utils.QuotationToFloat(b.Low) < utils.QuotationToFloat(a.Low) {
+		candle.Low = b.Low
+	}
+	return candle
+}
+
+// Aggregate builds candles of the interval from source candles of a shorter interval sorted by time.
+// The last one is incomplete unless the source candles reach its end
+func Aggregate(sources []*investapi.HistoricCandle, source Interval, interval Interval,
+	session Session) []*investapi.HistoricCandle {
+	aggregator := NewAggregator(interval, session)
+	candles := make([]*investapi.HistoricCandle, 0)
+	for _, candle := range sources {
+		_, completed := aggregator.Add(candle)
+		if completed != nil {
+			candles = append(candles, completed)
+		}
+	}
+	if current := aggregator.Current(); current != nil {
+		last := sources[len(sources)-1]
+		end := last.Time.AsTime().Add(source.Duration)
+		current.IsComplete = last.IsComplete && session.Start(interval, end).After(current.Time.AsTime())
+		candles = append(candles, current)
+	}
+	return candles
+}
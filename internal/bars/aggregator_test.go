@@ -0,0 +1,78 @@
+package bars
+
+import (
+	"fmt"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"testing"
+	"time"
+	"tinkoff-invest-contest/internal/client/investapi"
+	"tinkoff-invest-contest/internal/utils"
+)
+
+func newCandle(t time.Time, open, high, low, close float64, volume int64) *investapi.HistoricCandle {
+	return &investapi.HistoricCandle{
+		Open:       utils.FloatToQuotation(open),
+		High:       utils.FloatToQuotation(high),
+		Low:        utils.FloatToQuotation(low),
+		Close:      utils.FloatToQuotation(close),
+		Volume:     volume,
+		Time:       timestamppb.New(t),
+		IsComplete: true,
+	}
+}
+
+func candleValues(candle *investapi.HistoricCandle) []float64 {
+	return []float64{
+		utils.QuotationToFloat(candle.Open),
+		utils.QuotationToFloat(candle.High),
+		utils.QuotationToFloat(candle.Low),
+		utils.QuotationToFloat(candle.Close),
+		float64(candle.Volume),
+	}
+}
+
+func TestAggregate(t *testing.T) {
+	open := time.Date(2022, 5, 25, 10, 0, 0, 0, MoexSession.Location)
+	minute := Interval{time.Minute}
+	sources := []*investapi.HistoricCandle{
+		newCandle(open, 10, 11, 9, 10.5, 5),
+		newCandle(open.Add(time.Minute), 10.5, 12, 10, 11, 3),
+		newCandle(open.Add(2*time.Minute), 11, 11.5, 8, 9, 4),
+		newCandle(open.Add(3*time.Minute), 9, 10, 9, 9.5, 1),
+	}
+	got := Aggregate(sources, minute, Interval{3 * time.Minute}, MoexSession)
+	if len(got) != 2 {
+		t.Fatalf("Aggregate() returned %v candles, want 2", len(got))
+	}
+	wants := [][]float64{{10, 12, 8, 9, 12}, {9, 10, 9, 9.5, 1}}
+	for i, want := range wants {
+		if s := candleValues(got[i]); fmt.Sprint(s) != fmt.Sprint(want) {
+			t.Errorf("Aggregate()[%v] = %v, want %v", i, s, want)
+		}
+	}
+	if !got[0].IsComplete || got[1].IsComplete {
+		t.Errorf("Aggregate() completeness = %v, %v, want true, false", got[0].IsComplete, got[1].IsComplete)
+	}
+	if !got[1].Time.AsTime().Equal(open.Add(3 * time.Minute)) {
+		t.Errorf("Aggregate()[1].Time = %v, want %v", got[1].Time.AsTime(), open.Add(3*time.Minute))
+	}
+}
+
+func TestAggregator_Add(t *testing.T) {
+	open := time.Date(2022, 5, 25, 10, 0, 0, 0, MoexSession.Location)
+	aggregator := NewAggregator(Interval{2 * time.Minute}, MoexSession)
+	// Updates of the latest candle replace it
+	aggregator.Add(newCandle(open, 10, 10, 10, 10, 1))
+	aggregator.Add(newCandle(open, 10, 11, 10, 11, 2))
+	current, completed := aggregator.Add(newCandle(open.Add(time.Minute), 11, 11, 9, 9, 3))
+	if completed != nil {
+		t.Fatalf("Add() completed = %v, want nil", completed)
+	}
+	if s := candleValues(current); fmt.Sprint(s) != fmt.Sprint([]float64{10, 11, 9, 9, 5}) {
+		t.Errorf("Add() current = %v, want [10 11 9 9 5]", s)
+	}
+	_, completed = aggregator.Add(newCandle(open.Add(2*time.Minute), 9, 9, 9, 9, 1))
+	if completed == nil || !completed.IsComplete || completed.Volume != 5 {
+		t.Errorf("Add() completed = %v, want a complete candle of volume 5", completed)
+	}
+}
@@ -0,0 +1,73 @@
+package bars
+
+import (
+	"fmt"
+	"math"
+	"tinkoff-invest-contest/internal/client/investapi"
+	"tinkoff-invest-contest/internal/utils"
+)
+
+// BarType tells how candles are transformed before they're passed to a strategy
+type BarType int
+
+const (
+	// BarTypeCandles passes candles as is
+	BarTypeCandles BarType = iota
+	// BarTypeHeikinAshi smooths candles, see HeikinAshi
+	BarTypeHeikinAshi
+)
+
+func StringToBarType(s string) (BarType, error) {
+	switch s {
+	case "", "candles":
+		return BarTypeCandles, nil
+	case "heikin_ashi":
+		return BarTypeHeikinAshi, nil
+	}
+	return 0, fmt.Errorf("unknown bar type: %q", s)
+}
+
+func BarTypeToString(barType BarType) string {
+	switch barType {
+	case BarTypeCandles:
+		return "candles"
+	case BarTypeHeikinAshi:
+		return "heikin_ashi"
+	}
+	return ""
+}
+
+// Apply transforms candles into bars of the type
+func (barType BarType) Apply(candles []*investapi.HistoricCandle) []*investapi.HistoricCandle {
+	if barType == BarTypeHeikinAshi {
+		return HeikinAshi(candles)
+	}
+	return candles
+}
+
+// HeikinAshi returns Heikin-Ashi bars of the candles: close is the average of the candle's prices, open is the middle
+// of the previous bar's body, high and low include the bar's open and close
+func HeikinAshi(candles []*investapi.HistoricCandle) []*investapi.HistoricCandle {
+	result := make([]*investapi.HistoricCandle, 0, len(candles))
+	var prevOpen, prevClose float64
+	for i, candle := range candles {
+		open, high := utils.QuotationToFloat(candle.Open), utils.QuotationToFloat(candle.High)
+		low, close := utils.QuotationToFloat(candle.Low), utils.QuotationToFloat(candle.Close)
+		haClose := (open + high + low + close) / 4
+		haOpen := (open + close) / 2
+		if i > 0 {
+			haOpen = (prevOpen + prevClose) / 2
+		}
+		result = append(result, &investapi.HistoricCandle{
+			Open:       utils.FloatToQuotation(haOpen),
+			High:       utils.FloatToQuotation(math.Max(high, math.Max(haOpen, haClose))),
+			Low:        utils.FloatToQuotation(math.Min(low, math.Min(haOpen, haClose))),
+			Close:      utils.FloatToQuotation(haClose),
+			Volume:     candle.Volume,
+			Time:       candle.Time,
+			IsComplete: candle.IsComplete,
+		})
+		prevOpen, prevClose = haOpen, haClose
+	}
+	return result
+}
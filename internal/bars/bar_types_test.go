@@ -0,0 +1,23 @@
+package bars
+
+import (
+	"fmt"
+	"testing"
+	"time"
+	"tinkoff-invest-contest/internal/client/investapi"
+)
+
+func TestHeikinAshi(t *testing.T) {
+	start := time.Date(2022, 5, 25, 10, 0, 0, 0, MoexSession.Location)
+	candles := []*investapi.HistoricCandle{
+		newCandle(start, 10, 12, 8, 11, 1),
+		newCandle(start.Add(time.Minute), 11, 14, 11, 13, 1),
+	}
+	got := HeikinAshi(candles)
+	wants := [][]float64{{10.5, 12, 8, 10.25, 1}, {10.375, 14, 10.375, 12.25, 1}}
+	for i, want := range wants {
+		if s := candleValues(got[i]); fmt.Sprint(s) != fmt.Sprint(want) {
+			t.Errorf("HeikinAshi()[%v] = %v, want %v", i, s, want)
+		}
+	}
+}
@@ -0,0 +1,145 @@
+package bars
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"tinkoff-invest-contest/internal/client/investapi"
+)
+
+const (
+	day  = 24 * time.Hour
+	week = 7 * day
+)
+
+// Interval is a candle interval of a whole number of minutes up to a day, or a week
+type Interval struct {
+	Duration time.Duration
+}
+
+// ParseInterval parses intervals like "3min", "4hour", "1day" or "1week"
+func ParseInterval(s string) (Interval, error) {
+	units := []struct {
+		suffix string
+		unit   time.Duration
+	}{
+		{"min", time.Minute},
+		{"hour", time.Hour},
+		{"day", day},
+		{"week", week},
+	}
+	for _, u := range units {
+		if !strings.HasSuffix(s, u.suffix) {
+			continue
+		}
+		n, err := strconv.Atoi(strings.TrimSuffix(s, u.suffix))
+		if err != nil || n <= 0 {
+			break
+		}
+		interval := Interval{Duration: time.Duration(n) * u.unit}
+		if interval.Duration > day && interval.Duration != week {
+			return Interval{}, fmt.Errorf("candle interval %q is longer than a day but isn't a week", s)
+		}
+		return interval, nil
+	}
+	return Interval{}, fmt.Errorf("unknown candle interval: %q", s)
+}
+
+// FromCandleInterval returns the interval of the API's candles
+func FromCandleInterval(candleInterval investapi.CandleInterval) (Interval, error) {
+	switch candleInterval {
+	case investapi.CandleInterval_CANDLE_INTERVAL_1_MIN:
+		return Interval{time.Minute}, nil
+	case investapi.CandleInterval_CANDLE_INTERVAL_5_MIN:
+		return Interval{5 * time.Minute}, nil
+	case investapi.CandleInterval_CANDLE_INTERVAL_15_MIN:
+		return Interval{15 * time.Minute}, nil
+	case investapi.CandleInterval_CANDLE_INTERVAL_HOUR:
+		return Interval{time.Hour}, nil
+	case investapi.CandleInterval_CANDLE_INTERVAL_DAY:
+		return Interval{day}, nil
+	}
+	return Interval{}, fmt.Errorf("unknown candle interval: %v", candleInterval)
+}
+
+func (interval Interval) String() string {
+	switch {
+	case interval.Duration == week:
+		return "1week"
+	case interval.Duration == day:
+		return "1day"
+	case interval.Duration%time.Hour == 0:
+		return fmt.Sprintf("%dhour", interval.Duration/time.Hour)
+	}
+	return fmt.Sprintf("%dmin", interval.Duration/time.Minute)
+}
+
+// IsValid tells whether the interval is a whole number of minutes up to a day, or a week
+func (interval Interval) IsValid() bool {
+	return interval.Duration >= time.Minute && interval.Duration%time.Minute == 0 &&
+		(interval.Duration <= day || interval.Duration == week)
+}
+
+// Subscription returns the stream the interval is aggregated from, the stream only has 1 and 5 minute candles
+func (interval Interval) Subscription() investapi.SubscriptionInterval {
+	if interval.Duration%(5*time.Minute) == 0 {
+		return investapi.SubscriptionInterval_SUBSCRIPTION_INTERVAL_FIVE_MINUTES
+	}
+	return investapi.SubscriptionInterval_SUBSCRIPTION_INTERVAL_ONE_MINUTE
+}
+
+// Source returns the longest API's candle interval the interval can be aggregated from
+func (interval Interval) Source() investapi.CandleInterval {
+	switch {
+	case interval.Duration%day == 0:
+		return investapi.CandleInterval_CANDLE_INTERVAL_DAY
+	case interval.Duration%time.Hour == 0:
+		return investapi.CandleInterval_CANDLE_INTERVAL_HOUR
+	case interval.Duration%(15*time.Minute) == 0:
+		return investapi.CandleInterval_CANDLE_INTERVAL_15_MIN
+	case interval.Duration%(5*time.Minute) == 0:
+		return investapi.CandleInterval_CANDLE_INTERVAL_5_MIN
+	}
+	return investapi.CandleInterval_CANDLE_INTERVAL_1_MIN
+}
+
+// Session is the daily trading session that intraday candles are aligned to
+type Session struct {
+	Location *time.Location
+	// Time of the session open since midnight
+	Open time.Duration
+}
+
+// MoexSession is the main trading session of Moscow Exchange, which opens at 10:00 MSK
+var MoexSession = Session{
+	Location: time.FixedZone("MSK", 3*60*60),
+	Open:     10 * time.Hour,
+}
+
+// Start returns the start of the interval's candle that t belongs to. Intraday candles are counted from
+// the session open of t's day, so that the first candle of the session is a whole one. Daily candles start at midnight,
+// weekly ones on Monday
+func (session Session) Start(interval Interval, t time.Time) time.Time {
+	t = t.In(session.Location)
+	midnight := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, session.Location)
+	switch {
+	case interval.Duration == week:
+		// Weekday is 0 on Sunday
+		return midnight.AddDate(0, 0, -(int(midnight.Weekday())+6)%7)
+	case interval.Duration == day:
+		return midnight
+	}
+	open := midnight.Add(session.Open)
+	n := t.Sub(open) / interval.Duration
+	if t.Before(open) && t.Sub(open)%interval.Duration != 0 {
+		// Round towards the past
+		n--
+	}
+	start := open.Add(n * interval.Duration)
+	if start.Before(midnight) {
+		// The candle of the previous day's grid is cut at midnight
+		return midnight
+	}
+	return start
+}
@@ -0,0 +1,85 @@
+package bars
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseInterval(t *testing.T) {
+	tests := []struct {
+		name    string
+		s       string
+		want    time.Duration
+		wantErr bool
+	}{
+		{name: "test1", s: "3min", want: 3 * time.Minute},
+		{name: "test2", s: "4hour", want: 4 * time.Hour},
+		{name: "test3", s: "1week", want: 7 * 24 * time.Hour},
+		{name: "test4", s: "2day", wantErr: true},
+		{name: "test5", s: "0min", wantErr: true},
+		{name: "test6", s: "10sec", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseInterval(tt.s)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseInterval() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got.Duration != tt.want {
+				t.Errorf("ParseInterval() = %v, want %v", got.Duration, tt.want)
+			}
+			if !tt.wantErr && got.String() != tt.s {
+				t.Errorf("String() = %v, want %v", got.String(), tt.s)
+			}
+		})
+	}
+}
+
+func TestSession_Start(t *testing.T) {
+	msk := MoexSession.Location
+	tests := []struct {
+		name     string
+		interval time.Duration
+		t        time.Time
+		want     time.Time
+	}{
+		{
+			name:     "test1",
+			interval: 3 * time.Minute,
+			t:        time.Date(2022, 5, 25, 10, 7, 0, 0, msk),
+			want:     time.Date(2022, 5, 25, 10, 6, 0, 0, msk),
+		},
+		{
+			name:     "test2",
+			interval: 4 * time.Hour,
+			t:        time.Date(2022, 5, 25, 15, 0, 0, 0, time.UTC),
+			want:     time.Date(2022, 5, 25, 18, 0, 0, 0, msk),
+		},
+		{
+			name:     "test3",
+			interval: 4 * time.Hour,
+			t:        time.Date(2022, 5, 25, 7, 30, 0, 0, msk),
+			want:     time.Date(2022, 5, 25, 6, 0, 0, 0, msk),
+		},
+		{
+			name:     "test4",
+			interval: 4 * time.Hour,
+			t:        time.Date(2022, 5, 25, 1, 0, 0, 0, msk),
+			want:     time.Date(2022, 5, 25, 0, 0, 0, 0, msk),
+		},
+		{
+			name:     "test5",
+			interval: 7 * 24 * time.Hour,
+			t:        time.Date(2022, 5, 29, 12, 0, 0, 0, msk),
+			want:     time.Date(2022, 5, 23, 0, 0, 0, 0, msk),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := MoexSession.Start(Interval{tt.interval}, tt.t)
+			if !got.Equal(tt.want) {
+				t.Errorf("Start() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
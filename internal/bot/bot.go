@@ -1,16 +1,26 @@
 package bot
 
 import (
+	"context"
 	"fmt"
 	"github.com/go-yaml/yaml"
 	"log"
 	"math"
+	"sync"
+	"sync/atomic"
 	"time"
-	"tinkoff-invest-contest/internal/appstate"
+	"tinkoff-invest-contest/internal/bars"
+	"tinkoff-invest-contest/internal/client"
 	"tinkoff-invest-contest/internal/client/investapi"
 	"tinkoff-invest-contest/internal/dashboard"
 	db "tinkoff-invest-contest/internal/database"
+	"tinkoff-invest-contest/internal/execution"
+	"tinkoff-invest-contest/internal/metrics"
+	"tinkoff-invest-contest/internal/position"
+	"tinkoff-invest-contest/internal/sizing"
 	"tinkoff-invest-contest/internal/strategies"
+	"tinkoff-invest-contest/internal/supervisor"
+	"tinkoff-invest-contest/internal/tape"
 	"tinkoff-invest-contest/internal/tradeenv"
 	"tinkoff-invest-contest/internal/utils"
 )
@@ -19,28 +29,68 @@ type Bot struct {
 	id   int
 	name string
 
-	instrument  utils.InstrumentInterface
-	allowMargin bool
-	fee         float64
+	instrument      utils.InstrumentInterface
+	allowMargin     bool
+	fee             float64
+	reconcilePolicy ReconcilePolicy
+	shutdownPolicy  ShutdownPolicy
 
 	tradeEnv *tradeenv.TradeEnv
 
 	occupiedAccountId   string
+	lastAccountId       string // the account released last, a flat bot reconciles with it
 	lastDiscardTS       time.Time
 	prevSignalDirection investapi.OrderDirection
 
-	ordersConfig strategies.OrdersConfig
+	candleInterval bars.Interval
+	barType        bars.BarType
+	// Builds candles of the interval from the streamed ones
+	aggregator     *bars.Aggregator
+	orderBookDepth int32
+	// Recent trades for the strategy, and the ones of the current candle
+	tape      *tape.Tape
+	tradeFlow tape.Flow
 
-	candleInterval investapi.CandleInterval
+	// Params are written by the loop under paramsMu, so the loop reads them without locking
+	paramsMu       sync.Mutex
+	ordersConfig   strategies.OrdersConfig
 	window         int
-	orderBookDepth int32
+	strategyName   string
+	strategyConfig string
 	strategy       strategies.Strategy
+	pendingParams  *Params
+
+	supervisor               *supervisor.Supervisor
+	paused, removing         bool
+	waitingForOrderExecution bool
+	orders                   sync.WaitGroup
+	orderError               chan error
+	// Orders outlive the bot's loop, so that they can be completed on shutdown
+	ordersCtx    context.Context
+	cancelOrders context.CancelFunc
+	executor     *execution.Executor
+	// Latest order book for the executor, which runs outside the loop
+	lastOrderBook atomic.Value
+
+	currentStopLoss *strategies.TradeSignalStopOrder
+	// Take-profit ladder, the nearest level goes first
+	currentTakeProfits []*strategies.TradeSignalStopOrder
+	// Lots the stop orders have been set for, take-profit levels close shares of it
+	initialLots   int64
+	exchangeStops exchangeStops
+
+	position position.Position
+	// PnL as of the latest price, for readers outside the loop
+	pnl atomic.Value
+}
 
-	started, paused, removing bool
-	waitingForOrderExecution  bool
-	orderError                chan error
-
-	currentStopLoss, currentTakeProfit *strategies.TradeSignalStopOrder
+// PnL is the bot's position valued at the latest price
+type PnL struct {
+	Id       int    `json:"id"`
+	Name     string `json:"name"`
+	Figi     string `json:"figi"`
+	Currency string `json:"currency"`
+	position.Snapshot
 }
 
 func New(
@@ -49,46 +99,51 @@ func New(
 	instrument utils.InstrumentInterface,
 	allowMargin bool,
 	fee float64,
+	reconcilePolicy ReconcilePolicy,
+	shutdownPolicy ShutdownPolicy,
 	tradeEnv *tradeenv.TradeEnv,
-	orderType investapi.OrderType,
-	stopLossOrderType investapi.OrderType,
-	takeProfitRatio float64,
-	stopLossRatio float64,
-	stopLossExecRatio float64,
-	candleInterval investapi.CandleInterval,
-	window int,
+	candleInterval bars.Interval,
+	barType bars.BarType,
 	orderBookDepth int32,
-	strategy strategies.Strategy,
+	params Params,
 ) *Bot {
 	bot := &Bot{
-		id:          id,
-		name:        name,
-		instrument:  instrument,
-		allowMargin: allowMargin,
-		fee:         fee,
-		tradeEnv:    tradeEnv,
-		ordersConfig: strategies.OrdersConfig{
-			OrderType:         orderType,
-			StopLossOrderType: stopLossOrderType,
-			TakeProfitRatio:   takeProfitRatio,
-			StopLossRatio:     stopLossRatio,
-			StopLossExecRatio: stopLossExecRatio,
-		},
-		candleInterval: candleInterval,
-		window:         window,
-		orderBookDepth: orderBookDepth,
-		strategy:       strategy,
-		orderError:     make(chan error),
+		id:              id,
+		name:            name,
+		instrument:      instrument,
+		allowMargin:     allowMargin,
+		fee:             fee,
+		reconcilePolicy: reconcilePolicy,
+		shutdownPolicy:  shutdownPolicy,
+		tradeEnv:        tradeEnv,
+		candleInterval:  candleInterval,
+		barType:         barType,
+		aggregator:      bars.NewAggregator(candleInterval, bars.MoexSession),
+		orderBookDepth:  orderBookDepth,
+		tape:            tape.New(tapeWindow, tapeMaxLen),
+		ordersConfig:    params.OrdersConfig,
+		window:          params.Window,
+		strategyName:    params.StrategyName,
+		strategyConfig:  params.StrategyConfig,
+		strategy:        params.Strategy,
+		orderError:      make(chan error, 1),
 	}
+	bot.ordersCtx, bot.cancelOrders = context.WithCancel(context.Background())
+	bot.executor = execution.NewExecutor(tradeEnv, params.OrdersConfig.Execution, bot.getOrderBook)
+	bot.supervisor = supervisor.New(fmt.Sprintf("%v bot %q", bot.logPrefix(), bot.name), supervisor.GetRestartPolicy())
 
-	bot.tradeEnv.InitNewMarketDataChannels()
+	bot.tradeEnv.AddInstrument(instrument)
+	bot.tradeEnv.InitNewMarketDataChannels(bot.id)
 
-	dashboard.AddBotDashboard(bot.id, bot.name)
+	err := dashboard.AddBotDashboard(bot.id, bot.name)
+	if err != nil {
+		log.Printf("%v can't add Grafana dashboard: %v", bot.logPrefix(), err)
+	}
 
 	return bot
 }
 
-func (bot *Bot) loop() error {
+func (bot *Bot) loop(ctx context.Context) error {
 	log.Printf("%v bot %q has started", bot.logPrefix(), bot.name)
 	currentTimestamp := time.Time{}
 	var (
@@ -97,66 +152,142 @@ func (bot *Bot) loop() error {
 		currentCandle        *investapi.Candle
 		currentOrderBook     *investapi.OrderBook
 		shouldReleaseAccount bool
+		streamDown           bool
 	)
 	marketData := bot.tradeEnv.GetMarketDataChannels(bot.id)
-	for !appstate.ShouldExit && !bot.removing {
+	reconcileTicker := time.NewTicker(reconcileInterval)
+	defer reconcileTicker.Stop()
+	exchangeStopsTicker := time.NewTicker(exchangeStopsSyncInterval)
+	defer exchangeStopsTicker.Stop()
+	// Wakes the loop up to wait while paused when there's no market data
+	idleTicker := time.NewTicker(500 * time.Millisecond)
+	defer idleTicker.Stop()
+	applyParams := func() {
+		// Orders in progress read the params
+		if !bot.waitingForOrderExecution && bot.applyPendingParams() {
+			// Get historic candles for the new window on the next candle
+			currentTimestamp = time.Time{}
+		}
+	}
+	for ctx.Err() == nil && !bot.removing {
+		applyParams()
 		select {
+		case <-ctx.Done():
+			return nil
+
 		// Get candle from stream
 		case candle := <-marketData.Candle:
-			currentCandle = candle
+			currentCandle = bot.aggregate(candle)
 			if currentCandle.Time.AsTime() != currentTimestamp {
 				// On a new candle, get historic candles in amount of >= window
-				candles, err = bot.tradeEnv.GetAtLeastNLastCandles(bot.instrument.GetFigi(), bot.candleInterval, bot.window)
+				candles, err = bot.tradeEnv.GetAtLeastNLastBars(bot.instrument.GetFigi(), bot.candleInterval, bars.MoexSession,
+					bot.window)
 				if err != nil {
 					log.Println(bot.logPrefix(), utils.PrettifyError(err))
 					return err
 				}
+				if len(candles) < bot.window-1 {
+					err = fmt.Errorf("only %v candles of history are available, %v are needed", len(candles), bot.window-1)
+					log.Println(bot.logPrefix(), err)
+					if bot.tradeEnv.IsReplay() {
+						// History grows as the recording is replayed
+						continue
+					}
+					return err
+				}
 				// Trim excessive candles
 				candles = candles[len(candles)-(bot.window-1):]
 				db.WriteHistoricCandles(bot.id, candles)
 				currentTimestamp = currentCandle.Time.AsTime()
 			}
 			go db.WriteLastCandle(bot.id, currentCandle)
+			if !bot.tradeFlow.Start.IsZero() {
+				go db.WriteTradeFlow(bot.id, bot.tradeFlow)
+			}
+			// The order goroutine owns the position until the order is done
+			if !bot.waitingForOrderExecution {
+				bot.publishPosition(utils.QuotationToFloat(currentCandle.Close))
+			}
 
 		case orderBook := <-marketData.OrderBook:
 			if !orderBook.IsConsistent || len(orderBook.Bids) == 0 || len(orderBook.Asks) == 0 {
 				continue
 			}
 			currentOrderBook = orderBook
+			bot.lastOrderBook.Store(orderBook)
+
+		case trade := <-marketData.Trade:
+			bot.addTrade(trade)
+			continue
+
+		case state := <-marketData.StreamState:
+			streamDown = state == client.StreamDisconnected
+			if streamDown {
+				log.Printf("%v market data stream is down, trading is suspended", bot.logPrefix())
+			} else {
+				// Don't act on the data received before the outage
+				currentCandle, currentOrderBook = nil, nil
+				bot.aggregator.Reset()
+				bot.lastOrderBook.Store((*investapi.OrderBook)(nil))
+				currentTimestamp = time.Time{}
+				bot.backfillTape()
+				log.Printf("%v market data stream is back, waiting for fresh data to continue trading", bot.logPrefix())
+			}
+			continue
+
+		case <-reconcileTicker.C:
+			if !bot.waitingForOrderExecution {
+				err = bot.reconcile(bot.ordersCtx)
+				if err != nil {
+					log.Printf("%v can't reconcile position: %v", bot.logPrefix(), utils.PrettifyError(err))
+				}
+			}
+			continue
+
+		case <-exchangeStopsTicker.C:
+			if !bot.waitingForOrderExecution && bot.exchangeStops.isActive() {
+				err = bot.syncExchangeStops()
+				if err != nil {
+					log.Printf("%v can't sync exchange stop orders: %v", bot.logPrefix(), utils.PrettifyError(err))
+				}
+			}
+			continue
 
 		case orderError := <-bot.orderError:
+			bot.waitingForOrderExecution = false
 			if orderError != nil {
 				log.Printf("%v order error: %v", bot.logPrefix(), utils.PrettifyError(orderError))
 				return orderError
 			}
-			bot.waitingForOrderExecution = false
 
-		default:
-			for bot.paused && !bot.removing {
+		case <-idleTicker.C:
+			for bot.paused && !bot.removing && ctx.Err() == nil {
 				time.Sleep(2 * time.Second)
+				applyParams()
 			}
-			time.Sleep(500 * time.Millisecond)
 			continue
 		}
 
-		if currentCandle == nil || currentOrderBook == nil {
+		if currentCandle == nil || currentOrderBook == nil || streamDown {
 			continue
 		}
 
 		// Get trade signal
+		marketDataCandles := append(candles,
+			&investapi.HistoricCandle{
+				Open:   currentCandle.Open,
+				High:   currentCandle.High,
+				Low:    currentCandle.Low,
+				Close:  currentCandle.Close,
+				Volume: currentCandle.Volume,
+			},
+		)
 		signal, outputValues := bot.strategy.GetTradeSignal(
 			bot.instrument,
 			strategies.MarketData{
-				Candles: append(candles,
-					&investapi.HistoricCandle{
-						Open:   currentCandle.Open,
-						High:   currentCandle.High,
-						Low:    currentCandle.Low,
-						Close:  currentCandle.Close,
-						Volume: currentCandle.Volume,
-					},
-				),
+				Candles:   bot.barType.Apply(marketDataCandles),
 				OrderBook: currentOrderBook,
+				Trades:    bot.tape.Trades(),
 			},
 			bot.ordersConfig,
 		)
@@ -170,30 +301,16 @@ func (bot *Bot) loop() error {
 
 		if bot.currentStopLoss != nil {
 			signal = nil
-			if bot.currentStopLoss.IsTriggered(currentCandle.Close) {
-				signal = &strategies.TradeSignal{
-					Order: &strategies.TradeSignalOrder{
-						Direction: bot.currentStopLoss.Direction,
-					},
-				}
-				if bot.currentStopLoss.Type == investapi.StopOrderType_STOP_ORDER_TYPE_STOP_LIMIT {
-					signal.Order.Type = investapi.OrderType_ORDER_TYPE_LIMIT
-					signal.Order.Price = bot.currentStopLoss.ExecPrice
-				} else {
-					signal.Order.Type = investapi.OrderType_ORDER_TYPE_MARKET
-					signal.Order.Price = bot.currentStopLoss.TriggerPrice
-				}
-			} else if bot.currentTakeProfit.IsTriggered(currentCandle.Close) {
-				signal = &strategies.TradeSignal{
-					Order: &strategies.TradeSignalOrder{
-						Type:      investapi.OrderType_ORDER_TYPE_MARKET,
-						Direction: bot.currentTakeProfit.Direction,
-						Price:     bot.currentTakeProfit.TriggerPrice,
-					},
-				}
+			if bot.ordersConfig.IsTrailingStopEnabled() {
+				bot.trailStopLoss(marketDataCandles)
 			}
-			if signal != nil {
-				bot.currentStopLoss, bot.currentTakeProfit = nil, nil
+			go db.WriteStrategyOutput(bot.id, map[string]any{
+				"stop_loss": utils.QuotationToFloat(bot.currentStopLoss.TriggerPrice),
+			}, currentCandle.Time.AsTime())
+			if bot.exchangeStops.isActive() {
+				signal = bot.emulateTakeProfits(currentCandle.Close)
+			} else {
+				signal = bot.emulateStopOrders(currentCandle.Close)
 			}
 		}
 
@@ -207,14 +324,27 @@ func (bot *Bot) loop() error {
 				if accountId == "" {
 					continue
 				}
-				maxDealValue := bot.tradeEnv.CalculateMaxDealValue(
+				maxDealValue, err := bot.tradeEnv.CalculateMaxDealValue(
 					accountId,
 					signal.Order.Direction,
 					bot.instrument,
 					currentCandle.Close,
 					bot.allowMargin,
 				)
+				if err != nil {
+					discard()
+					unlock()
+					log.Println(bot.logPrefix(), utils.PrettifyError(err))
+					return err
+				}
 				lots = bot.tradeEnv.CalculateLotsCanAfford(signal.Order.Direction, maxDealValue, bot.instrument, currentCandle.Close, bot.fee)
+				lots, err = bot.sizePosition(accountId, signal, currentCandle.Close, lots, marketDataCandles)
+				if err != nil {
+					discard()
+					unlock()
+					log.Println(bot.logPrefix(), utils.PrettifyError(err))
+					return err
+				}
 				if lots == 0 {
 					bot.lastDiscardTS = time.Now()
 					discard()
@@ -223,6 +353,9 @@ func (bot *Bot) loop() error {
 				}
 				unlock()
 				bot.occupiedAccountId = accountId
+			} else if signal.Order.Quantity > 0 {
+				// Partial exit
+				lots = signal.Order.Quantity
 			} else if signal.Order.Direction != bot.prevSignalDirection {
 				shouldReleaseAccount = true
 				lots, err = bot.tradeEnv.GetLotsHave(bot.occupiedAccountId, bot.instrument)
@@ -236,16 +369,39 @@ func (bot *Bot) loop() error {
 			}
 
 			bot.waitingForOrderExecution = true
-			go func() {
+			bot.orders.Add(1)
+			go func(shouldReleaseAccount bool, signalTime time.Time, signalOrderBook *investapi.OrderBook) {
+				defer bot.orders.Done()
+				defer func() {
+					if r := recover(); r != nil {
+						bot.orderError <- supervisor.NewPanicError(r)
+					}
+				}()
 				// Place an order and wait for it to be filled
-				avgPositionPrice, err := bot.tradeEnv.DoOrder(
-					bot.instrument.GetFigi(),
-					lots,
-					signal.Order.Price,
-					signal.Order.Direction,
-					bot.occupiedAccountId,
-					signal.Order.Type,
-				)
+				metrics.Orders.WithLabelValues(fmt.Sprint(bot.id), "placed").Inc()
+				report, err := bot.executor.Execute(bot.ordersCtx, execution.Order{
+					Figi:              bot.instrument.GetFigi(),
+					Lots:              lots,
+					Price:             signal.Order.Price,
+					Direction:         signal.Order.Direction,
+					AccountId:         bot.occupiedAccountId,
+					Type:              signal.Order.Type,
+					MinPriceIncrement: bot.instrument.GetMinPriceIncrement(),
+				})
+				if report.LotsExecuted > 0 {
+					// A split order could have been executed partially
+					bot.updatePosition(signal.Order.Direction, report.LotsExecuted*int64(bot.instrument.GetLot()), report.AvgPrice)
+				}
+				// Limit orders cancelled unfilled still count in the fill ratio
+				if report.LotsExecuted > 0 || report.OrderType == investapi.OrderType_ORDER_TYPE_LIMIT && report.LotsPosted > 0 {
+					bot.reportExecution(report, signal.Order.Direction, signalOrderBook, signalTime)
+				}
+				if err != nil {
+					metrics.Orders.WithLabelValues(fmt.Sprint(bot.id), "rejected").Inc()
+					bot.orderError <- err
+					return
+				}
+				metrics.Orders.WithLabelValues(fmt.Sprint(bot.id), "filled").Inc()
 				log.Println(bot.logPrefix())
 				log.Printf("%v %v %v %v for %v %v (actual avg. %v %v), account: %v",
 					bot.logPrefix(),
@@ -254,7 +410,7 @@ func (bot *Bot) loop() error {
 					bot.instrument.GetTicker(),
 					utils.QuotationToFloat(signal.Order.Price),
 					bot.instrument.GetCurrency(),
-					avgPositionPrice,
+					report.AvgPrice,
 					bot.instrument.GetCurrency(),
 					bot.occupiedAccountId,
 				)
@@ -262,7 +418,7 @@ func (bot *Bot) loop() error {
 					bot.id,
 					signal.Order.Direction,
 					lots*int64(bot.instrument.GetLot()),
-					avgPositionPrice,
+					report.AvgPrice,
 					bot.instrument.GetCurrency(),
 				)
 				if err != nil {
@@ -270,13 +426,22 @@ func (bot *Bot) loop() error {
 				}
 
 				if shouldReleaseAccount {
-					bot.tradeEnv.ReleaseAccount(bot.occupiedAccountId, bot.instrument.GetCurrency())
-					bot.occupiedAccountId = ""
+					bot.releaseAccount()
+				}
+				if signal.Order.Quantity > 0 {
+					// The rest of the position keeps its direction
+					bot.onTakeProfitHit()
+					if bot.ordersConfig.UseExchangeStopOrders && bot.currentStopLoss != nil && !bot.exchangeStops.isActive() {
+						// The stop loss has been withdrawn to take profit
+						bot.placeExchangeStops()
+					}
+				} else {
+					bot.prevSignalDirection = signal.Order.Direction
 				}
-				bot.prevSignalDirection = signal.Order.Direction
 
 				if signal.StopLoss != nil {
-					bot.currentStopLoss, bot.currentTakeProfit = signal.StopLoss, signal.TakeProfit
+					bot.currentStopLoss, bot.currentTakeProfits = signal.StopLoss, signal.TakeProfits
+					bot.initialLots = lots
 					if bot.currentStopLoss.Type == investapi.StopOrderType_STOP_ORDER_TYPE_STOP_LIMIT {
 						log.Printf("%v setting stop loss = %v -> %v %v",
 							bot.logPrefix(),
@@ -291,38 +456,97 @@ func (bot *Bot) loop() error {
 							bot.instrument.GetCurrency(),
 						)
 					}
-					log.Printf("%v setting take profit = %v %v",
-						bot.logPrefix(),
-						utils.QuotationToFloat(bot.currentTakeProfit.TriggerPrice),
-						bot.instrument.GetCurrency(),
-					)
+					for _, takeProfit := range bot.currentTakeProfits {
+						log.Printf("%v setting take profit = %v %v (%v%% of position)",
+							bot.logPrefix(),
+							utils.QuotationToFloat(takeProfit.TriggerPrice),
+							bot.instrument.GetCurrency(),
+							takeProfit.Share,
+						)
+					}
+					if bot.ordersConfig.UseExchangeStopOrders {
+						bot.placeExchangeStops()
+					}
 				}
 				log.Println(bot.logPrefix())
 
-				bot.orderError <- err
-			}()
+				bot.orderError <- nil
+			}(shouldReleaseAccount, time.Now(), currentOrderBook)
 		}
 	}
 	return nil
 }
 
-func (bot *Bot) Serve() {
-	bot.started = true
-	for !appstate.ShouldExit && !bot.removing {
-		utils.WaitForInternetConnection()
-		bot.tradeEnv.SubscribeCandles(bot.id, bot.instrument.GetFigi(), investapi.SubscriptionInterval(bot.candleInterval))
-		bot.tradeEnv.SubscribeOrderBook(bot.id, bot.instrument.GetFigi(), bot.orderBookDepth)
+// Serve runs the bot under supervision until it's removed, ctx is done or the restart policy gives up.
+// Does nothing if the bot is already running
+func (bot *Bot) Serve(ctx context.Context) {
+	bot.supervisor.Run(ctx, bot.run)
+}
 
-		err := bot.loop()
-		if err != nil {
-			log.Printf("%v bot %q has crashed, restarting...", bot.logPrefix(), bot.name)
-			time.Sleep(10 * time.Second)
-		}
+// run is a single attempt to run the bot, a failed attempt is restarted by the supervisor
+func (bot *Bot) run(ctx context.Context) error {
+	if bot.removing {
+		return nil
+	}
+	bot.supervisor.SetPaused(bot.paused)
+	// An update scheduled after the bot has started but before the loop is running
+	bot.applyPendingParams()
+	if bot.tradeEnv.Client.WaitUntilAvailable(ctx) != nil {
+		return nil
+	}
+	err := bot.tradeEnv.SubscribeCandles(bot.id, bot.instrument.GetFigi(), bot.candleInterval.Subscription())
+	if err == nil {
+		err = bot.tradeEnv.SubscribeOrderBook(bot.id, bot.instrument.GetFigi(), bot.orderBookDepth)
+	}
+	if err == nil {
+		err = bot.tradeEnv.SubscribeTrades(bot.id, bot.instrument.GetFigi())
+	}
+	if err != nil {
+		return fmt.Errorf("can't subscribe to market data: %w", err)
+	}
+
+	// The order of the previous attempt must complete before trading continues
+	if bot.waitOrders(ctx) != nil {
+		return nil
+	}
+	select {
+	case <-bot.orderError:
+	default:
+	}
+	bot.waitingForOrderExecution = false
+	// An order could have failed half-way or been missed while the loop was down
+	err = bot.reconcile(bot.ordersCtx)
+	if err != nil {
+		log.Printf("%v can't reconcile position: %v", bot.logPrefix(), utils.PrettifyError(err))
+	}
+
+	bot.backfillTape()
+
+	return bot.loop(ctx)
+}
+
+// waitOrders blocks until the orders in flight are done or ctx is done
+func (bot *Bot) waitOrders(ctx context.Context) error {
+	ordersDone := make(chan struct{})
+	go func() {
+		bot.orders.Wait()
+		close(ordersDone)
+	}()
+	select {
+	case <-ordersDone:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
 	}
 }
 
 func (bot *Bot) TogglePause() {
-	bot.paused = !bot.paused
+	bot.setPaused(!bot.paused)
+}
+
+func (bot *Bot) setPaused(paused bool) {
+	bot.paused = paused
+	bot.supervisor.SetPaused(paused)
 	if bot.paused {
 		log.Printf("%v bot %q is paused", bot.logPrefix(), bot.name)
 	} else {
@@ -340,39 +564,296 @@ func (bot *Bot) IsPaused() bool {
 	return bot.paused
 }
 
+// IsStarted tells whether the bot is running (maybe paused or waiting to be restarted)
 func (bot *Bot) IsStarted() bool {
-	return bot.started
+	switch bot.supervisor.GetState() {
+	case supervisor.StateRunning, supervisor.StatePaused, supervisor.StateBackingOff:
+		return true
+	}
+	return false
+}
+
+func (bot *Bot) GetStatus() supervisor.Status {
+	return bot.supervisor.GetStatus()
+}
+
+// trailStopLoss moves the stop loss after the price, the last candle is the current one
+func (bot *Bot) trailStopLoss(candles []*investapi.HistoricCandle) {
+	price := utils.QuotationToFloat(candles[len(candles)-1].Close)
+	moved := bot.currentStopLoss.Trail(
+		price,
+		bot.position.GetAvgPrice(),
+		bot.ordersConfig.TrailingStopDistance(price, candles),
+		bot.ordersConfig.TrailingStopActivationRatio,
+		bot.instrument.GetMinPriceIncrement(),
+	)
+	if moved {
+		log.Printf("%v moving trailing stop loss to %v %v",
+			bot.logPrefix(),
+			utils.QuotationToFloat(bot.currentStopLoss.TriggerPrice),
+			bot.instrument.GetCurrency(),
+		)
+		bot.exchangeStops.stopLossStale = bot.exchangeStops.isActive()
+	}
+}
+
+// emulateStopOrders returns a signal to close the position, or a part of it, if a stop order is triggered at price
+func (bot *Bot) emulateStopOrders(price *investapi.Quotation) *strategies.TradeSignal {
+	if bot.currentStopLoss.IsTriggered(price) {
+		signal := &strategies.TradeSignal{
+			Order: &strategies.TradeSignalOrder{
+				Direction: bot.currentStopLoss.Direction,
+			},
+		}
+		if bot.currentStopLoss.Type == investapi.StopOrderType_STOP_ORDER_TYPE_STOP_LIMIT {
+			signal.Order.Type = investapi.OrderType_ORDER_TYPE_LIMIT
+			signal.Order.Price = bot.currentStopLoss.ExecPrice
+		} else {
+			signal.Order.Type = investapi.OrderType_ORDER_TYPE_MARKET
+			signal.Order.Price = bot.currentStopLoss.TriggerPrice
+		}
+		bot.clearStopOrders()
+		return signal
+	}
+	if len(bot.currentTakeProfits) == 0 || !bot.currentTakeProfits[0].IsTriggered(price) {
+		return nil
+	}
+	takeProfit := bot.currentTakeProfits[0]
+	signal := &strategies.TradeSignal{
+		Order: &strategies.TradeSignalOrder{
+			Type:      investapi.OrderType_ORDER_TYPE_MARKET,
+			Direction: takeProfit.Direction,
+			Price:     takeProfit.TriggerPrice,
+		},
+	}
+	remainingLots := bot.remainingLots()
+	lots := strategies.LotsToClose(bot.initialLots, remainingLots, takeProfit.Share, len(bot.currentTakeProfits) == 1)
+	if lots < remainingLots {
+		signal.Order.Quantity = lots
+		bot.currentTakeProfits = bot.currentTakeProfits[1:]
+		return signal
+	}
+	bot.clearStopOrders()
+	return signal
+}
+
+// sizePosition applies bot's sizing model to the signal at the current price, maxLots is what the account can afford
+func (bot *Bot) sizePosition(accountId string, signal *strategies.TradeSignal, currentPrice *investapi.Quotation, maxLots int64,
+	candles []*investapi.HistoricCandle) (int64, error) {
+	config := bot.ordersConfig.Sizing
+	price := utils.QuotationToFloat(currentPrice)
+	input := sizing.Input{
+		Price:            price,
+		Lot:              int64(bot.instrument.GetLot()),
+		Fee:              bot.fee,
+		MaxLots:          maxLots,
+		Candles:          candles,
+		StopLossDistance: price * bot.ordersConfig.StopLossRatio,
+	}
+	if signal.StopLoss != nil {
+		input.StopLossDistance = math.Abs(price - utils.QuotationToFloat(signal.StopLoss.TriggerPrice))
+	}
+	if config.NeedsEquity() {
+		var err error
+		input.Equity, err = bot.tradeEnv.GetMoneyHave(accountId, bot.instrument.GetCurrency())
+		if err != nil {
+			return 0, err
+		}
+	}
+	lots, description := config.Size(input)
+	log.Printf("%v %q sizing: %v lots (%v)", bot.logPrefix(), sizing.ModelToString(config.Model), lots, description)
+	return lots, nil
+}
+
+// reportExecution logs how a parent order was executed, publishes its slippage and records its execution quality
+// against the order book at the time of the signal
+func (bot *Bot) reportExecution(report execution.Report, direction investapi.OrderDirection,
+	signalOrderBook *investapi.OrderBook, signalTime time.Time) {
+	algorithm := execution.AlgorithmToString(report.Algorithm)
+	if report.LotsExecuted > 0 {
+		metrics.ExecutionSlippage.WithLabelValues(fmt.Sprint(bot.id), algorithm).Observe(report.Slippage)
+	}
+	fill := execution.NewFillQuality(fmt.Sprint(bot.id), bot.instrument.GetFigi(), report, direction, signalOrderBook,
+		bot.instrument.GetMinPriceIncrement(), signalTime, time.Now())
+	execution.Quality.Record(fill)
+	go db.WriteExecutionQuality(bot.id, fill)
+	log.Printf("%v %q execution: %v of %v lots in %v child order(s), avg. %v %v vs signal %v %v, slippage %.3f%%",
+		bot.logPrefix(),
+		algorithm,
+		report.LotsExecuted,
+		report.LotsRequested,
+		report.ChildOrders,
+		report.AvgPrice,
+		bot.instrument.GetCurrency(),
+		report.SignalPrice,
+		bot.instrument.GetCurrency(),
+		report.Slippage*100,
+	)
+}
+
+func (bot *Bot) getOrderBook() *investapi.OrderBook {
+	orderBook, _ := bot.lastOrderBook.Load().(*investapi.OrderBook)
+	return orderBook
+}
+
+// onTakeProfitHit is called once a take-profit level has closed a part of the position
+func (bot *Bot) onTakeProfitHit() {
+	if !bot.ordersConfig.BreakEvenAfterFirstTarget || bot.currentStopLoss == nil {
+		return
+	}
+	moved := bot.currentStopLoss.MoveToBreakEven(bot.position.GetAvgPrice(), bot.fee, bot.instrument.GetMinPriceIncrement())
+	if moved {
+		log.Printf("%v moving stop loss to break-even at %v %v",
+			bot.logPrefix(),
+			utils.QuotationToFloat(bot.currentStopLoss.TriggerPrice),
+			bot.instrument.GetCurrency(),
+		)
+		bot.exchangeStops.stopLossStale = bot.exchangeStops.isActive()
+	}
+}
+
+// clearStopOrders forgets the stop orders, cancelling the ones placed on exchange
+func (bot *Bot) clearStopOrders() {
+	bot.cancelExchangeStops()
+	bot.currentStopLoss, bot.currentTakeProfits = nil, nil
+	bot.initialLots = 0
+}
+
+// remainingLots returns the absolute size of bot's position in lots
+func (bot *Bot) remainingLots() int64 {
+	return int64(math.Abs(float64(bot.position.GetQuantity() / int64(bot.instrument.GetLot()))))
+}
+
+// updatePosition accounts a fill in bot's position and publishes position metrics
+func (bot *Bot) updatePosition(direction investapi.OrderDirection, quantity int64, price float64) {
+	bot.position.Apply(direction, quantity, price, bot.fee)
+	bot.publishPosition(price)
+}
+
+// publishPosition publishes position metrics, unrealized PnL is calculated at given price
+func (bot *Bot) publishPosition(price float64) {
+	metrics.BotPosition.WithLabelValues(fmt.Sprint(bot.id), bot.instrument.GetFigi()).Set(float64(bot.position.GetQuantity()))
+	metrics.BotRealizedPnL.WithLabelValues(bot.metricsLabels()...).Set(bot.position.GetRealizedPnL())
+	metrics.BotUnrealizedPnL.WithLabelValues(bot.metricsLabels()...).Set(bot.position.UnrealizedPnL(price))
+
+	bot.pnl.Store(PnL{
+		Id:       bot.id,
+		Name:     bot.name,
+		Figi:     bot.instrument.GetFigi(),
+		Currency: bot.instrument.GetCurrency(),
+		Snapshot: bot.position.Snapshot(price),
+	})
+}
+
+// aggregate adds a streamed candle to the candle of bot's interval, returning the latter as of now.
+// The first candle after start or an outage lacks the part streamed before
+func (bot *Bot) aggregate(candle *investapi.Candle) *investapi.Candle {
+	current, _ := bot.aggregator.Add(&investapi.HistoricCandle{
+		Open:   candle.Open,
+		High:   candle.High,
+		Low:    candle.Low,
+		Close:  candle.Close,
+		Volume: candle.Volume,
+		Time:   candle.Time,
+	})
+	return &investapi.Candle{
+		Figi:        candle.Figi,
+		Interval:    candle.Interval,
+		Open:        current.Open,
+		High:        current.High,
+		Low:         current.Low,
+		Close:       current.Close,
+		Volume:      current.Volume,
+		Time:        current.Time,
+		LastTradeTs: candle.LastTradeTs,
+	}
+}
+
+// GetPnL returns the bot's position as of the latest candle or fill
+func (bot *Bot) GetPnL() PnL {
+	if pnl, ok := bot.pnl.Load().(PnL); ok {
+		return pnl
+	}
+	return PnL{
+		Id:       bot.id,
+		Name:     bot.name,
+		Figi:     bot.instrument.GetFigi(),
+		Currency: bot.instrument.GetCurrency(),
+	}
+}
+
+func (bot *Bot) metricsLabels() []string {
+	return []string{fmt.Sprint(bot.id), bot.instrument.GetFigi(), bot.instrument.GetCurrency()}
 }
 
 func (bot *Bot) logPrefix() string {
 	return fmt.Sprintf("[bot#%v]", bot.id)
 }
 
-func (bot *Bot) GetYAML() string {
+func (bot *Bot) GetYAML() (string, error) {
+	bot.paramsMu.Lock()
+	defer bot.paramsMu.Unlock()
+	strategyParams, err := bot.strategy.GetYAML()
+	if err != nil {
+		return "", err
+	}
 	obj := struct {
-		FIGI        string  `yaml:"FIGI"`
-		AllowMargin bool    `yaml:"AllowMargin"`
-		Fee         float64 `yaml:"Fee"`
+		FIGI            string  `yaml:"FIGI"`
+		AllowMargin     bool    `yaml:"AllowMargin"`
+		Fee             float64 `yaml:"Fee"`
+		ReconcilePolicy string  `yaml:"ReconcilePolicy"`
+		ShutdownPolicy  string  `yaml:"ShutdownPolicy"`
 
 		Window         int    `yaml:"Window"`
 		CandleInterval string `yaml:"CandleInterval"`
+		BarType        string `yaml:"BarType"`
+
+		Orders any `yaml:"Orders"`
 
 		Strategy any `yaml:"Strategy"`
 	}{
-		FIGI:           bot.instrument.GetFigi(),
-		AllowMargin:    bot.allowMargin,
-		Fee:            bot.fee,
-		Window:         bot.window,
-		CandleInterval: utils.CandleIntervalToString(bot.candleInterval),
+		FIGI:            bot.instrument.GetFigi(),
+		AllowMargin:     bot.allowMargin,
+		Fee:             bot.fee,
+		ReconcilePolicy: ReconcilePolicyToString(bot.reconcilePolicy),
+		ShutdownPolicy:  ShutdownPolicyToString(bot.shutdownPolicy),
+		Window:          bot.window,
+		CandleInterval:  bot.candleInterval.String(),
+		BarType:         bars.BarTypeToString(bot.barType),
+		Orders: struct {
+			OrderType               string  `yaml:"OrderType"`
+			StopLossOrderType       string  `yaml:"StopLossOrderType"`
+			TakeProfitRatio         float64 `yaml:"TakeProfitRatio"`
+			StopLossRatio           float64 `yaml:"StopLossRatio"`
+			StopLossExecRatio       float64 `yaml:"StopLossExecRatio,omitempty"`
+			TrailingStopRatio       float64 `yaml:"TrailingStopRatio,omitempty"`
+			TrailingStopATRMultiple float64 `yaml:"TrailingStopATRMultiple,omitempty"`
+			TakeProfitLevels        string  `yaml:"TakeProfitLevels,omitempty"`
+			ExecutionAlgorithm      string  `yaml:"ExecutionAlgorithm"`
+			SizingModel             string  `yaml:"SizingModel"`
+		}{
+			OrderType:               bot.ordersConfig.OrderType.String(),
+			StopLossOrderType:       bot.ordersConfig.StopLossOrderType.String(),
+			TakeProfitRatio:         bot.ordersConfig.TakeProfitRatio,
+			StopLossRatio:           bot.ordersConfig.StopLossRatio,
+			StopLossExecRatio:       bot.ordersConfig.StopLossExecRatio,
+			TrailingStopRatio:       bot.ordersConfig.TrailingStopRatio,
+			TrailingStopATRMultiple: bot.ordersConfig.TrailingStopATRMultiple,
+			TakeProfitLevels:        strategies.FormatTakeProfitLevels(bot.ordersConfig.TakeProfitLevels),
+			ExecutionAlgorithm:      execution.AlgorithmToString(bot.ordersConfig.Execution.Algorithm),
+			SizingModel:             sizing.ModelToString(bot.ordersConfig.Sizing.Model),
+		},
 		Strategy: struct {
 			Name   string `yaml:"Name"`
 			Params any    `yaml:"Params"`
 		}{
 			Name:   bot.strategy.GetName(),
-			Params: bot.strategy.GetYAML(),
+			Params: strategyParams,
 		},
 	}
 	bytes, err := yaml.Marshal(obj)
-	utils.MaybeCrash(err)
-	return string(bytes)
+	if err != nil {
+		return "", err
+	}
+	return string(bytes), nil
 }
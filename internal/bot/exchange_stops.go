@@ -0,0 +1,197 @@
+package bot
+
+import (
+	"fmt"
+	"log"
+	"time"
+	"tinkoff-invest-contest/internal/client/investapi"
+	"tinkoff-invest-contest/internal/dashboard"
+	"tinkoff-invest-contest/internal/metrics"
+	"tinkoff-invest-contest/internal/strategies"
+	"tinkoff-invest-contest/internal/utils"
+)
+
+// How often exchange stop orders are checked for execution
+const exchangeStopsSyncInterval = 10 * time.Second
+
+// exchangeStops holds the id of the stop order placed on exchange for bot's stop loss.
+// Take-profit levels are watched by the bot, which withdraws the stop loss before taking profit,
+// so that no two orders on exchange close the same lots
+type exchangeStops struct {
+	stopLossId string
+	// Executions before it have been accounted
+	since time.Time
+	// Stop loss has moved or the position has shrunk, so it has to be placed again
+	stopLossStale bool
+}
+
+func (stops *exchangeStops) isActive() bool {
+	return stops.stopLossId != ""
+}
+
+// placeExchangeStops places the current stop loss on exchange.
+// If it can't be placed, the stop orders are emulated by the bot
+func (bot *Bot) placeExchangeStops() {
+	err := bot.postExchangeStopLoss()
+	if err != nil {
+		log.Printf("%v can't place exchange stop loss, stop orders will be emulated: %v", bot.logPrefix(), utils.PrettifyError(err))
+		bot.exchangeStops = exchangeStops{}
+		return
+	}
+	log.Printf("%v placed stop loss on exchange, %v take profit level(s) are watched by the bot",
+		bot.logPrefix(), len(bot.currentTakeProfits))
+}
+
+// postExchangeStopLoss places the current stop loss on exchange for the whole position
+func (bot *Bot) postExchangeStopLoss() error {
+	stopOrderType := investapi.StopOrderType_STOP_ORDER_TYPE_STOP_LOSS
+	if bot.currentStopLoss.Type == investapi.StopOrderType_STOP_ORDER_TYPE_STOP_LIMIT {
+		stopOrderType = investapi.StopOrderType_STOP_ORDER_TYPE_STOP_LIMIT
+	}
+	// Whatever has been executed before is accounted already
+	since := time.Now()
+	stopOrderId, err := bot.tradeEnv.PostStopOrder(
+		bot.instrument.GetFigi(),
+		bot.remainingLots(),
+		bot.currentStopLoss.TriggerPrice,
+		bot.currentStopLoss.ExecPrice,
+		bot.currentStopLoss.Direction,
+		bot.occupiedAccountId,
+		stopOrderType,
+	)
+	if err != nil {
+		return err
+	}
+	bot.exchangeStops = exchangeStops{
+		stopLossId: stopOrderId,
+		since:      since,
+	}
+	return nil
+}
+
+// cancelExchangeStops cancels bot's stop orders on exchange, returns the number of cancelled ones
+func (bot *Bot) cancelExchangeStops() (cancelled int) {
+	if bot.exchangeStops.stopLossId != "" {
+		err := bot.tradeEnv.CancelStopOrder(bot.occupiedAccountId, bot.exchangeStops.stopLossId)
+		if err != nil {
+			log.Printf("%v can't cancel stop order %v: %v", bot.logPrefix(), bot.exchangeStops.stopLossId, utils.PrettifyError(err))
+		} else {
+			cancelled++
+		}
+	}
+	bot.exchangeStops = exchangeStops{}
+	return
+}
+
+// syncExchangeStops accounts the stop loss if it's no longer active and places it again if it's stale.
+// Must not be called while an order is being executed
+func (bot *Bot) syncExchangeStops() error {
+	activeIds, err := bot.tradeEnv.GetActiveStopOrderIds(bot.occupiedAccountId, bot.instrument.GetFigi())
+	if err != nil {
+		return err
+	}
+	if !activeIds[bot.exchangeStops.stopLossId] {
+		return bot.accountExchangeStopLoss()
+	}
+	if bot.exchangeStops.stopLossStale {
+		err = bot.tradeEnv.CancelStopOrder(bot.occupiedAccountId, bot.exchangeStops.stopLossId)
+		if err != nil {
+			// It might have just been triggered, which is found out next time
+			return err
+		}
+		bot.exchangeStops = exchangeStops{}
+		err = bot.postExchangeStopLoss()
+		if err != nil {
+			log.Printf("%v can't place stop loss again, stop orders will be emulated", bot.logPrefix())
+			return err
+		}
+	}
+	return nil
+}
+
+// accountExchangeStopLoss accounts what the stop loss, which is no longer active, has executed at the actual prices.
+// While its order is on the book, the rest is accounted later. If no order is left and the position is still open,
+// the stop loss has been cancelled (e.g. by hand or at the end of the session) and the bot emulates it from now on
+func (bot *Bot) accountExchangeStopLoss() error {
+	direction := bot.currentStopLoss.Direction
+	executed, err := bot.tradeEnv.GetExecution(bot.occupiedAccountId, bot.instrument, direction, bot.exchangeStops.since)
+	if err != nil {
+		return err
+	}
+	lots := executed.Lots
+	if remainingLots := bot.remainingLots(); lots > remainingLots {
+		lots = remainingLots
+	}
+	if lots > 0 {
+		bot.exchangeStops.since = executed.Last.Add(time.Nanosecond)
+		bot.applyExchangeStopExecution(direction, lots, executed.AvgPrice)
+		if bot.remainingLots() == 0 {
+			bot.prevSignalDirection = direction
+			// The stop order is gone, there is nothing to cancel
+			bot.exchangeStops = exchangeStops{}
+			bot.releaseAccount()
+			return nil
+		}
+	}
+	brokerPosition, err := bot.tradeEnv.GetBrokerPosition(bot.occupiedAccountId, bot.instrument)
+	if err != nil {
+		return err
+	}
+	if len(brokerPosition.ActiveOrders) > 0 {
+		return nil
+	}
+	log.Printf("%v stop loss %v is no longer on exchange, %v lots are left, stop orders will be emulated",
+		bot.logPrefix(), bot.exchangeStops.stopLossId, bot.remainingLots())
+	bot.exchangeStops = exchangeStops{}
+	return nil
+}
+
+// withdrawExchangeStopLoss cancels the stop loss on exchange, so that the bot can take profit without
+// closing the same lots twice. Once the take profit is filled, the stop loss is placed again for the rest of the position
+func (bot *Bot) withdrawExchangeStopLoss() error {
+	err := bot.tradeEnv.CancelStopOrder(bot.occupiedAccountId, bot.exchangeStops.stopLossId)
+	if err != nil {
+		// It might have been triggered, which is accounted by the next sync
+		return err
+	}
+	bot.exchangeStops = exchangeStops{}
+	return nil
+}
+
+// emulateTakeProfits returns a signal to take profit at price while the stop loss is on exchange, withdrawing the stop loss first
+func (bot *Bot) emulateTakeProfits(price *investapi.Quotation) *strategies.TradeSignal {
+	if len(bot.currentTakeProfits) == 0 || !bot.currentTakeProfits[0].IsTriggered(price) {
+		return nil
+	}
+	err := bot.withdrawExchangeStopLoss()
+	if err != nil {
+		log.Printf("%v can't withdraw exchange stop loss to take profit: %v", bot.logPrefix(), utils.PrettifyError(err))
+		return nil
+	}
+	return bot.emulateStopOrders(price)
+}
+
+// applyExchangeStopExecution accounts lots filled by an exchange stop order at the actual average price
+func (bot *Bot) applyExchangeStopExecution(direction investapi.OrderDirection, lots int64, price float64) {
+	metrics.Orders.WithLabelValues(fmt.Sprint(bot.id), "filled").Inc()
+	bot.updatePosition(direction, lots*int64(bot.instrument.GetLot()), price)
+	log.Printf("%v exchange stop order: %v %v %v for %v %v, account: %v",
+		bot.logPrefix(),
+		utils.OrderDirectionToString(direction),
+		lots*int64(bot.instrument.GetLot()),
+		bot.instrument.GetTicker(),
+		price,
+		bot.instrument.GetCurrency(),
+		bot.occupiedAccountId,
+	)
+	err := dashboard.AnnotateOrder(
+		bot.id,
+		direction,
+		lots*int64(bot.instrument.GetLot()),
+		price,
+		bot.instrument.GetCurrency(),
+	)
+	if err != nil {
+		log.Println(bot.logPrefix(), utils.PrettifyError(err))
+	}
+}
@@ -0,0 +1,114 @@
+package bot
+
+import (
+	"fmt"
+	"log"
+	"reflect"
+	"strings"
+	"tinkoff-invest-contest/internal/botspec"
+	"tinkoff-invest-contest/internal/strategies"
+)
+
+// Params are the bot's settings that can be updated while it's running
+type Params struct {
+	botspec.Params
+	// Built from StrategyName and StrategyConfig
+	Strategy strategies.Strategy
+}
+
+func (bot *Bot) IsSandbox() bool {
+	return bot.tradeEnv.IsSandbox()
+}
+
+// GetParams returns the params the bot runs with, including a pending update,
+// so that consecutive updates build on each other
+func (bot *Bot) GetParams() Params {
+	bot.paramsMu.Lock()
+	defer bot.paramsMu.Unlock()
+	if bot.pendingParams != nil {
+		return *bot.pendingParams
+	}
+	return bot.params()
+}
+
+func (bot *Bot) params() Params {
+	return Params{
+		Params: botspec.Params{
+			Window:         bot.window,
+			OrdersConfig:   bot.ordersConfig,
+			StrategyName:   bot.strategyName,
+			StrategyConfig: bot.strategyConfig,
+		},
+		Strategy: bot.strategy,
+	}
+}
+
+// UpdateParams schedules an update of the params, which is applied between ticks once no order is being executed.
+// A later update replaces a pending one. The params of a bot that isn't started are updated right away
+func (bot *Bot) UpdateParams(params Params) {
+	bot.paramsMu.Lock()
+	defer bot.paramsMu.Unlock()
+	bot.pendingParams = &params
+	// A bot that starts meanwhile applies the update in run, once the lock is released
+	if !bot.IsStarted() {
+		// There's no loop to apply it
+		bot.applyPendingParamsLocked()
+	} else {
+		log.Printf("%v params update is scheduled", bot.logPrefix())
+	}
+}
+
+// applyPendingParams applies the pending params update if there is one, telling whether the window has changed
+func (bot *Bot) applyPendingParams() bool {
+	bot.paramsMu.Lock()
+	defer bot.paramsMu.Unlock()
+	return bot.applyPendingParamsLocked()
+}
+
+// applyPendingParamsLocked is applyPendingParams for the callers that hold paramsMu
+func (bot *Bot) applyPendingParamsLocked() bool {
+	if bot.pendingParams == nil {
+		return false
+	}
+	old, params := bot.params(), *bot.pendingParams
+	bot.pendingParams = nil
+
+	bot.window = params.Window
+	bot.ordersConfig = params.OrdersConfig
+	bot.strategyName = params.StrategyName
+	bot.strategyConfig = params.StrategyConfig
+	bot.strategy = params.Strategy
+	bot.executor.SetConfig(params.OrdersConfig.Execution)
+
+	changes := paramsChanges(old.Params, params.Params)
+	if len(changes) == 0 {
+		log.Printf("%v params updated, nothing has changed", bot.logPrefix())
+	} else {
+		log.Printf("%v params updated: %v", bot.logPrefix(), strings.Join(changes, ", "))
+	}
+	return old.Window != params.Window
+}
+
+// paramsChanges lists the params that differ as "Name: old -> new", nested structs are flattened
+func paramsChanges(old botspec.Params, new botspec.Params) []string {
+	return fieldsChanges("", reflect.ValueOf(old), reflect.ValueOf(new))
+}
+
+func fieldsChanges(prefix string, old reflect.Value, new reflect.Value) []string {
+	var changes []string
+	for i := 0; i < old.NumField(); i++ {
+		name := prefix + old.Type().Field(i).Name
+		if !old.Type().Field(i).IsExported() {
+			continue
+		}
+		oldField, newField := old.Field(i), new.Field(i)
+		if oldField.Kind() == reflect.Struct {
+			changes = append(changes, fieldsChanges(name+".", oldField, newField)...)
+			continue
+		}
+		if !reflect.DeepEqual(oldField.Interface(), newField.Interface()) {
+			changes = append(changes, fmt.Sprintf("%v: %v -> %v", name, oldField.Interface(), newField.Interface()))
+		}
+	}
+	return changes
+}
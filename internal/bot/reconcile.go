@@ -0,0 +1,191 @@
+package bot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"time"
+	"tinkoff-invest-contest/internal/client/investapi"
+	"tinkoff-invest-contest/internal/metrics"
+	"tinkoff-invest-contest/internal/tradeenv"
+	"tinkoff-invest-contest/internal/utils"
+)
+
+// How often bot's position is compared with the broker's one
+const reconcileInterval = 5 * time.Minute
+
+// ReconcilePolicy tells what to do when bot's position doesn't match the broker's one
+type ReconcilePolicy int
+
+const (
+	// ReconcilePolicyPause pauses the bot for manual review
+	ReconcilePolicyPause ReconcilePolicy = iota
+	// ReconcilePolicyAdopt takes the broker's position as bot's own
+	ReconcilePolicyAdopt
+	// ReconcilePolicyFlatten cancels active orders and closes the position at market
+	ReconcilePolicyFlatten
+)
+
+func StringToReconcilePolicy(s string) (ReconcilePolicy, error) {
+	switch s {
+	case "", "pause":
+		return ReconcilePolicyPause, nil
+	case "adopt":
+		return ReconcilePolicyAdopt, nil
+	case "flatten":
+		return ReconcilePolicyFlatten, nil
+	}
+	return 0, errors.New("unknown reconcile policy: " + s)
+}
+
+func ReconcilePolicyToString(policy ReconcilePolicy) string {
+	switch policy {
+	case ReconcilePolicyPause:
+		return "pause"
+	case ReconcilePolicyAdopt:
+		return "adopt"
+	case ReconcilePolicyFlatten:
+		return "flatten"
+	}
+	return ""
+}
+
+// reconcile compares bot's position with the broker's position and active orders on the occupied account,
+// and applies bot's reconcile policy if they don't match.
+// A flat bot checks the account it has released last, unless another bot has occupied it since.
+// Must not be called while an order is being executed
+func (bot *Bot) reconcile(ctx context.Context) error {
+	if bot.occupiedAccountId == "" {
+		if bot.lastAccountId == "" || !bot.tradeEnv.OccupyAccount(bot.lastAccountId, bot.instrument.GetCurrency()) {
+			return nil
+		}
+		// Hold the account while checking it, so that no other bot starts trading on it
+		bot.occupiedAccountId = bot.lastAccountId
+		defer func() {
+			if bot.occupiedAccountId != "" && bot.position.GetQuantity() == 0 {
+				bot.releaseAccount()
+			}
+		}()
+	}
+	if bot.exchangeStops.isActive() {
+		// Stop orders executed by the exchange must be accounted first
+		err := bot.syncExchangeStops()
+		if err != nil {
+			return err
+		}
+		if bot.occupiedAccountId == "" {
+			return nil
+		}
+	}
+	brokerPosition, err := bot.tradeEnv.GetBrokerPosition(bot.occupiedAccountId, bot.instrument)
+	if err != nil {
+		return err
+	}
+	expectedLots := bot.position.GetQuantity() / int64(bot.instrument.GetLot())
+	if brokerPosition.Lots == expectedLots && len(brokerPosition.ActiveOrders) == 0 {
+		return nil
+	}
+
+	log.Printf("%v position discrepancy on account %v: expected %v lots, broker has %v lots and %v active order(s), applying %q policy",
+		bot.logPrefix(),
+		bot.occupiedAccountId,
+		expectedLots,
+		brokerPosition.Lots,
+		len(brokerPosition.ActiveOrders),
+		ReconcilePolicyToString(bot.reconcilePolicy),
+	)
+	metrics.ReconcileDiscrepancies.WithLabelValues(fmt.Sprint(bot.id), ReconcilePolicyToString(bot.reconcilePolicy)).Inc()
+
+	switch bot.reconcilePolicy {
+	case ReconcilePolicyAdopt:
+		bot.adoptBrokerPosition(brokerPosition)
+	case ReconcilePolicyFlatten:
+		return bot.flatten(ctx, brokerPosition)
+	default:
+		log.Printf("%v pausing bot %q for manual review", bot.logPrefix(), bot.name)
+		bot.setPaused(true)
+	}
+	return nil
+}
+
+func (bot *Bot) adoptBrokerPosition(brokerPosition *tradeenv.BrokerPosition) {
+	bot.position.Reset(brokerPosition.Lots*int64(bot.instrument.GetLot()), brokerPosition.AvgPrice)
+	bot.publishPosition(brokerPosition.AvgPrice)
+	switch {
+	case brokerPosition.Lots > 0:
+		bot.prevSignalDirection = investapi.OrderDirection_ORDER_DIRECTION_BUY
+	case brokerPosition.Lots < 0:
+		bot.prevSignalDirection = investapi.OrderDirection_ORDER_DIRECTION_SELL
+	default:
+		bot.releaseAccount()
+	}
+	log.Printf("%v adopted broker's position of %v lots at avg. %v %v",
+		bot.logPrefix(), brokerPosition.Lots, brokerPosition.AvgPrice, bot.instrument.GetCurrency())
+}
+
+func (bot *Bot) flatten(ctx context.Context, brokerPosition *tradeenv.BrokerPosition) error {
+	bot.cancelExchangeStops()
+	if len(brokerPosition.ActiveOrders) > 0 {
+		bot.cancelActiveOrders(brokerPosition)
+		// Orders could have been (partially) filled before cancellation
+		var err error
+		brokerPosition, err = bot.tradeEnv.GetBrokerPosition(bot.occupiedAccountId, bot.instrument)
+		if err != nil {
+			return err
+		}
+	}
+	// Start from the broker's state, so that the closing fill is accounted in PnL
+	bot.position.Reset(brokerPosition.Lots*int64(bot.instrument.GetLot()), brokerPosition.AvgPrice)
+
+	if brokerPosition.Lots != 0 {
+		direction := investapi.OrderDirection_ORDER_DIRECTION_SELL
+		if brokerPosition.Lots < 0 {
+			direction = investapi.OrderDirection_ORDER_DIRECTION_BUY
+		}
+		lots := int64(math.Abs(float64(brokerPosition.Lots)))
+		metrics.Orders.WithLabelValues(fmt.Sprint(bot.id), "placed").Inc()
+		avgPositionPrice, err := bot.tradeEnv.DoOrder(
+			ctx,
+			bot.instrument.GetFigi(),
+			lots,
+			&investapi.Quotation{},
+			direction,
+			bot.occupiedAccountId,
+			investapi.OrderType_ORDER_TYPE_MARKET,
+		)
+		if err != nil {
+			metrics.Orders.WithLabelValues(fmt.Sprint(bot.id), "rejected").Inc()
+			return err
+		}
+		metrics.Orders.WithLabelValues(fmt.Sprint(bot.id), "filled").Inc()
+		bot.updatePosition(direction, lots*int64(bot.instrument.GetLot()), avgPositionPrice)
+	}
+	bot.releaseAccount()
+	log.Printf("%v position has been flattened", bot.logPrefix())
+	return nil
+}
+
+// cancelActiveOrders cancels broker's active orders, returns the number of cancelled ones
+func (bot *Bot) cancelActiveOrders(brokerPosition *tradeenv.BrokerPosition) (cancelled int) {
+	for _, order := range brokerPosition.ActiveOrders {
+		err := bot.tradeEnv.CancelOrder(bot.occupiedAccountId, order.OrderId)
+		if err != nil {
+			log.Printf("%v can't cancel order %v: %v", bot.logPrefix(), order.OrderId, utils.PrettifyError(err))
+			continue
+		}
+		cancelled++
+	}
+	return
+}
+
+// releaseAccount gives the occupied account back, once the bot is flat
+func (bot *Bot) releaseAccount() {
+	bot.clearStopOrders()
+	err := bot.tradeEnv.ReleaseAccount(bot.occupiedAccountId, bot.instrument.GetCurrency())
+	if err != nil {
+		log.Println(bot.logPrefix(), utils.PrettifyError(err))
+	}
+	bot.lastAccountId, bot.occupiedAccountId = bot.occupiedAccountId, ""
+}
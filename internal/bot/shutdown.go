@@ -0,0 +1,113 @@
+package bot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"tinkoff-invest-contest/internal/utils"
+)
+
+// ShutdownPolicy tells what to do with bot's open position and orders on exit
+type ShutdownPolicy int
+
+const (
+	// ShutdownPolicyLeave leaves the position and orders as they are
+	ShutdownPolicyLeave ShutdownPolicy = iota
+	// ShutdownPolicyFlatten cancels active orders and closes the position at market
+	ShutdownPolicyFlatten
+	// ShutdownPolicyCancel cancels active orders and exchange stop orders, leaving the position as it is
+	ShutdownPolicyCancel
+)
+
+func StringToShutdownPolicy(s string) (ShutdownPolicy, error) {
+	switch s {
+	case "", "leave":
+		return ShutdownPolicyLeave, nil
+	case "flatten":
+		return ShutdownPolicyFlatten, nil
+	case "cancel":
+		return ShutdownPolicyCancel, nil
+	}
+	return 0, errors.New("unknown shutdown policy: " + s)
+}
+
+func ShutdownPolicyToString(policy ShutdownPolicy) string {
+	switch policy {
+	case ShutdownPolicyLeave:
+		return "leave"
+	case ShutdownPolicyFlatten:
+		return "flatten"
+	case ShutdownPolicyCancel:
+		return "cancel"
+	}
+	return ""
+}
+
+// Shutdown waits for the bot to stop and for its order in flight to complete, then applies bot's shutdown policy.
+// Whatever isn't done by the time ctx is done is abandoned. Returns a summary of what was done
+func (bot *Bot) Shutdown(ctx context.Context) string {
+	defer bot.cancelOrders()
+	summary := make([]string, 0)
+	done := func() string {
+		return fmt.Sprintf("%v shutdown (%v): %v",
+			bot.logPrefix(), ShutdownPolicyToString(bot.shutdownPolicy), strings.Join(summary, "; "))
+	}
+
+	if bot.supervisor.Wait(ctx) != nil {
+		summary = append(summary, "timed out waiting for the bot to stop, position is left as is")
+		return done()
+	}
+	if bot.waitingForOrderExecution {
+		if bot.waitOrders(ctx) != nil {
+			summary = append(summary, "timed out waiting for the order in flight, position is left as is")
+			return done()
+		}
+		select {
+		case err := <-bot.orderError:
+			if err != nil {
+				summary = append(summary, "order in flight has failed ("+utils.PrettifyError(err)+")")
+			} else {
+				summary = append(summary, "order in flight has been filled")
+			}
+		default:
+		}
+		bot.waitingForOrderExecution = false
+	}
+	if bot.occupiedAccountId == "" {
+		summary = append(summary, "no open position")
+		return done()
+	}
+
+	lots := bot.position.GetQuantity() / int64(bot.instrument.GetLot())
+	switch bot.shutdownPolicy {
+	case ShutdownPolicyFlatten:
+		brokerPosition, err := bot.tradeEnv.GetBrokerPosition(bot.occupiedAccountId, bot.instrument)
+		if err == nil {
+			lots = brokerPosition.Lots
+			err = bot.flatten(ctx, brokerPosition)
+		}
+		if err != nil {
+			summary = append(summary, "can't flatten position ("+utils.PrettifyError(err)+")")
+			return done()
+		}
+		summary = append(summary, fmt.Sprintf("position of %v lots has been flattened", lots))
+
+	case ShutdownPolicyCancel:
+		brokerPosition, err := bot.tradeEnv.GetBrokerPosition(bot.occupiedAccountId, bot.instrument)
+		if err != nil {
+			summary = append(summary, "can't get active orders ("+utils.PrettifyError(err)+")")
+			return done()
+		}
+		cancelled := bot.cancelActiveOrders(brokerPosition)
+		if bot.exchangeStops.isActive() {
+			summary = append(summary, fmt.Sprintf("%v exchange stop order(s) cancelled", bot.cancelExchangeStops()))
+		}
+		summary = append(summary, fmt.Sprintf("%v of %v active order(s) cancelled, position of %v lots is left on account %v",
+			cancelled, len(brokerPosition.ActiveOrders), brokerPosition.Lots, bot.occupiedAccountId))
+
+	default:
+		summary = append(summary, fmt.Sprintf("position of %v lots is left on account %v", lots, bot.occupiedAccountId))
+	}
+	return done()
+}
@@ -0,0 +1,32 @@
+package bot
+
+import (
+	"log"
+	"time"
+	"tinkoff-invest-contest/internal/bars"
+	"tinkoff-invest-contest/internal/client/investapi"
+	"tinkoff-invest-contest/internal/utils"
+)
+
+const (
+	// Strategies see the trades of this period
+	tapeWindow = 5 * time.Minute
+	// Trades of the period beyond this number are dropped, the oldest first
+	tapeMaxLen = 10000
+)
+
+// backfillTape puts the trades made before the bot has subscribed to them, or while the stream was down, on the tape
+func (bot *Bot) backfillTape() {
+	trades, err := bot.tradeEnv.GetLastTrades(bot.instrument.GetFigi(), tapeWindow)
+	if err != nil {
+		log.Printf("%v can't get last trades: %v", bot.logPrefix(), utils.PrettifyError(err))
+	}
+	bot.tape.Reset(trades)
+}
+
+// addTrade puts a streamed trade on the tape and accounts it in the trade flow of its candle
+func (bot *Bot) addTrade(trade *investapi.Trade) {
+	if bot.tape.Add(trade) {
+		bot.tradeFlow.Add(trade, bars.MoexSession.Start(bot.candleInterval, trade.Time.AsTime()))
+	}
+}
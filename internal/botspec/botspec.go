@@ -0,0 +1,120 @@
+package botspec
+
+import (
+	"errors"
+	"tinkoff-invest-contest/internal/bars"
+	"tinkoff-invest-contest/internal/strategies"
+	"tinkoff-invest-contest/internal/utils"
+	"tinkoff-invest-contest/internal/validation"
+)
+
+const (
+	// MinWindow keeps at least one historic candle besides the current one
+	MinWindow = 2
+	// MaxWindow bounds the number of candles requested on every new candle
+	MaxWindow = 1000
+)
+
+// OrderBookDepths are the depths the API can subscribe to
+var OrderBookDepths = []int32{1, 10, 20, 30, 40, 50}
+
+// Spec describes a bot to create
+type Spec struct {
+	Sandbox bool
+	Paper   bool // Paper trading or replay, the venue can't sell more than the account holds
+	// Nil if the instrument couldn't be found, the checks that need it are skipped then
+	Instrument  utils.InstrumentInterface
+	AllowMargin bool
+
+	// Zero if it couldn't be parsed, the check is skipped then
+	CandleInterval bars.Interval
+	OrderBookDepth int32
+
+	Params
+}
+
+// Params are the part of a spec that can be updated while a bot is running
+type Params struct {
+	Window       int
+	OrdersConfig strategies.OrdersConfig
+
+	StrategyName   string
+	StrategyConfig string
+}
+
+// Validate checks the spec against API constraints and builds its strategy.
+// All the errors are returned at once, fields are named as API arguments
+func Validate(spec Spec) (strategies.Strategy, validation.Errors) {
+	var errs validation.Errors
+
+	if spec.CandleInterval.Duration != 0 && !spec.CandleInterval.IsValid() {
+		errs.Add("candleInterval", "must be a whole number of minutes up to a day, or a week, got %v",
+			spec.CandleInterval.Duration)
+	}
+	if !isValidDepth(spec.OrderBookDepth) {
+		errs.Add("orderBookDepth", "must be one of %v, got %v", OrderBookDepths, spec.OrderBookDepth)
+	}
+	if spec.AllowMargin {
+		if spec.Paper {
+			errs.Add("allowMargin", "margin trading is not available in paper trading")
+		} else if spec.Sandbox {
+			errs.Add("allowMargin", "margin trading is not available in sandbox")
+		} else if spec.Instrument != nil && !IsMarginEligible(spec.Instrument) {
+			errs.Add("allowMargin", "%v is not eligible for margin trading", spec.Instrument.GetTicker())
+		}
+	}
+
+	strategy, paramsErrors := ValidateParams(spec.Sandbox, spec.Params)
+	return strategy, append(errs, paramsErrors...)
+}
+
+// ValidateParams checks the params of a bot in sandbox or combat environment and builds its strategy
+func ValidateParams(sandbox bool, params Params) (strategies.Strategy, validation.Errors) {
+	var errs validation.Errors
+	if params.Window < MinWindow || params.Window > MaxWindow {
+		errs.Add("window", "must be from %v to %v, got %v", MinWindow, MaxWindow, params.Window)
+	}
+	if sandbox && params.OrdersConfig.UseExchangeStopOrders {
+		errs.Add("useExchangeStopOrders", "exchange stop orders are only supported in combat environment")
+	}
+	errs = append(errs, params.OrdersConfig.Validate()...)
+
+	strategy, err := newStrategy(params.StrategyName, params.StrategyConfig)
+	var paramErrors validation.Errors
+	switch {
+	case errors.As(err, &paramErrors):
+		errs.Merge("strategyConfig.", paramErrors)
+	case errors.Is(err, errUnknownStrategy):
+		errs.Add("strategyName", "no known strategy %q", params.StrategyName)
+	case err != nil:
+		errs.Add("strategyConfig", "%v", err)
+	}
+	return strategy, errs
+}
+
+var errUnknownStrategy = errors.New("unknown strategy")
+
+func newStrategy(name string, config string) (strategies.Strategy, error) {
+	newStrategyFromJSON, ok := strategies.JSONConstructors[name]
+	if !ok {
+		return nil, errUnknownStrategy
+	}
+	return newStrategyFromJSON(config)
+}
+
+func isValidDepth(depth int32) bool {
+	for _, validDepth := range OrderBookDepths {
+		if depth == validDepth {
+			return true
+		}
+	}
+	return false
+}
+
+// IsMarginEligible tells whether the broker lends money or securities for the instrument
+func IsMarginEligible(instrument utils.InstrumentInterface) bool {
+	longEligible := instrument.GetDlong() != nil && utils.QuotationToFloat(instrument.GetDlong()) > 0
+	shortEligible := instrument.GetShortEnabledFlag() && instrument.GetDshort() != nil &&
+		utils.QuotationToFloat(instrument.GetDshort()) > 0
+	return longEligible || shortEligible
+}
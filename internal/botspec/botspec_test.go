@@ -0,0 +1,105 @@
+package botspec
+
+import (
+	"reflect"
+	"testing"
+	"time"
+	"tinkoff-invest-contest/internal/bars"
+	"tinkoff-invest-contest/internal/client/investapi"
+	"tinkoff-invest-contest/internal/strategies"
+	_ "tinkoff-invest-contest/internal/strategies/bollinger"
+)
+
+func TestValidate(t *testing.T) {
+	valid := Spec{
+		Instrument: &investapi.Share{
+			Ticker:           "SBER",
+			Dlong:            &investapi.Quotation{Nano: 250000000},
+			Dshort:           &investapi.Quotation{Nano: 250000000},
+			ShortEnabledFlag: true,
+		},
+		CandleInterval: bars.Interval{Duration: time.Minute},
+		OrderBookDepth: 20,
+		Params: Params{
+			Window: 50,
+			OrdersConfig: strategies.OrdersConfig{
+				OrderType:         investapi.OrderType_ORDER_TYPE_MARKET,
+				StopLossOrderType: investapi.OrderType_ORDER_TYPE_MARKET,
+				TakeProfitRatio:   0.01,
+				StopLossRatio:     0.005,
+			},
+			StrategyName:   "bollinger",
+			StrategyConfig: `{"coef": 3, "pointDev": 0.0005}`,
+		},
+	}
+	tests := []struct {
+		name       string
+		modify     func(spec *Spec)
+		wantFields []string
+	}{
+		{
+			name:   "test1",
+			modify: func(spec *Spec) { spec.AllowMargin = true },
+		},
+		{
+			name: "test2",
+			modify: func(spec *Spec) {
+				spec.CandleInterval = bars.Interval{Duration: 90 * time.Second}
+				spec.Window = 1
+				spec.OrderBookDepth = 15
+				spec.OrdersConfig.TakeProfitRatio = 2
+			},
+			wantFields: []string{"candleInterval", "orderBookDepth", "window", "takeProfitRatio"},
+		},
+		{
+			name: "test3",
+			modify: func(spec *Spec) {
+				spec.AllowMargin = true
+				spec.Instrument = &investapi.Share{Ticker: "SBER", Dlong: &investapi.Quotation{}}
+				spec.StrategyConfig = `{"coef": -1, "pointDev": 1}`
+			},
+			wantFields: []string{"allowMargin", "strategyConfig.coef", "strategyConfig.pointDev"},
+		},
+		{
+			name: "test4",
+			modify: func(spec *Spec) {
+				spec.Sandbox = true
+				spec.AllowMargin = true
+				spec.OrdersConfig.UseExchangeStopOrders = true
+				spec.StrategyName = "unknown"
+			},
+			wantFields: []string{"allowMargin", "useExchangeStopOrders", "strategyName"},
+		},
+		{
+			name:       "test5",
+			modify:     func(spec *Spec) { spec.StrategyConfig = "{" },
+			wantFields: []string{"strategyConfig"},
+		},
+		{
+			name: "test6",
+			modify: func(spec *Spec) {
+				spec.Sandbox = true
+				spec.Paper = true
+				spec.AllowMargin = true
+			},
+			wantFields: []string{"allowMargin"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			spec := valid
+			tt.modify(&spec)
+			strategy, errs := Validate(spec)
+			var gotFields []string
+			for _, fieldError := range errs {
+				gotFields = append(gotFields, fieldError.Field)
+			}
+			if !reflect.DeepEqual(gotFields, tt.wantFields) {
+				t.Errorf("Validate() fields = %v, want %v", gotFields, tt.wantFields)
+			}
+			if len(errs) == 0 && strategy == nil {
+				t.Errorf("Validate() strategy = nil for a valid spec")
+			}
+		})
+	}
+}
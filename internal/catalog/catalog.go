@@ -0,0 +1,355 @@
+package catalog
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"tinkoff-invest-contest/internal/client"
+	"tinkoff-invest-contest/internal/client/investapi"
+	"tinkoff-invest-contest/internal/utils"
+)
+
+// Entry is an instrument of the catalog
+type Entry struct {
+	Figi      string               `json:"figi"`
+	Ticker    string               `json:"ticker"`
+	ClassCode string               `json:"classCode"`
+	Isin      string               `json:"isin,omitempty"`
+	Name      string               `json:"name"`
+	Currency  string               `json:"currency"`
+	Lot       int32                `json:"lot"`
+	Type      utils.InstrumentType `json:"-"`
+	TypeName  string               `json:"type"`
+	Exchange  string               `json:"exchange"`
+	Sector    string               `json:"sector,omitempty"`
+
+	ShortEnabled      bool `json:"shortEnabled"`
+	ApiTradeAvailable bool `json:"apiTradeAvailable"`
+
+	instrument utils.InstrumentInterface
+	// Whether the instrument came from the API rather than from a snapshot, which lacks some fields (e.g. dlong)
+	fresh bool
+}
+
+type namedInstrument interface {
+	utils.InstrumentInterface
+	GetName() string
+	GetClassCode() string
+	GetExchange() string
+	GetShortEnabledFlag() bool
+	GetApiTradeAvailableFlag() bool
+}
+
+func newEntry(instrument namedInstrument, instrumentType utils.InstrumentType, fresh bool) *Entry {
+	entry := &Entry{
+		Figi:      instrument.GetFigi(),
+		Ticker:    instrument.GetTicker(),
+		ClassCode: instrument.GetClassCode(),
+		Name:      instrument.GetName(),
+		Currency:  instrument.GetCurrency(),
+		Lot:       instrument.GetLot(),
+		Type:      instrumentType,
+		TypeName:  utils.InstrumentTypeToString(instrumentType),
+		Exchange:  instrument.GetExchange(),
+
+		ShortEnabled:      instrument.GetShortEnabledFlag(),
+		ApiTradeAvailable: instrument.GetApiTradeAvailableFlag(),
+
+		instrument: instrument,
+		fresh:      fresh,
+	}
+	// Futures don't have one
+	if withIsin, ok := instrument.(interface{ GetIsin() string }); ok {
+		entry.Isin = withIsin.GetIsin()
+	}
+	// Currencies don't have one
+	if withSector, ok := instrument.(interface{ GetSector() string }); ok {
+		entry.Sector = withSector.GetSector()
+	}
+	return entry
+}
+
+// Catalog indexes instruments by FIGI, ticker with class code, ISIN and name
+type Catalog struct {
+	mu                sync.RWMutex
+	byFigi            map[string]*Entry
+	byTickerClassCode map[string]*Entry
+	byIsin            map[string][]*Entry
+	// Sorted by ticker
+	entries []*Entry
+}
+
+func New() *Catalog {
+	c := &Catalog{}
+	c.set(nil)
+	return c
+}
+
+// set replaces catalog's contents
+func (c *Catalog) set(entries []*Entry) {
+	byFigi := make(map[string]*Entry, len(entries))
+	byTickerClassCode := make(map[string]*Entry, len(entries))
+	byIsin := make(map[string][]*Entry)
+	for _, entry := range entries {
+		byFigi[entry.Figi] = entry
+		byTickerClassCode[tickerClassCodeKey(entry.Ticker, entry.ClassCode)] = entry
+		if entry.Isin != "" {
+			byIsin[entry.Isin] = append(byIsin[entry.Isin], entry)
+		}
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Ticker < entries[j].Ticker
+	})
+	c.mu.Lock()
+	c.byFigi, c.byTickerClassCode, c.byIsin, c.entries = byFigi, byTickerClassCode, byIsin, entries
+	c.mu.Unlock()
+}
+
+func tickerClassCodeKey(ticker string, classCode string) string {
+	return strings.ToUpper(ticker) + "@" + strings.ToUpper(classCode)
+}
+
+func (c *Catalog) Len() int {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return len(c.entries)
+}
+
+// LoadSnapshot loads instruments from a JSON file holding lists of shares, bonds, etfs, futures and currencies
+// in the Invest API format, such as instruments.json
+func (c *Catalog) LoadSnapshot(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	entries, err := parseSnapshot(data)
+	if err != nil {
+		return fmt.Errorf("can't parse instruments snapshot %v: %w", path, err)
+	}
+	c.set(entries)
+	return nil
+}
+
+func parseSnapshot(data []byte) ([]*Entry, error) {
+	var snapshot map[string][]json.RawMessage
+	err := json.Unmarshal(data, &snapshot)
+	if err != nil {
+		return nil, err
+	}
+	newInstrument := map[string]func() (proto.Message, utils.InstrumentType){
+		"shares": func() (proto.Message, utils.InstrumentType) {
+			return &investapi.Share{}, utils.InstrumentType_INSTRUMENT_TYPE_SHARE
+		},
+		"bonds": func() (proto.Message, utils.InstrumentType) {
+			return &investapi.Bond{}, utils.InstrumentType_INSTRUMENT_TYPE_BOND
+		},
+		"etfs": func() (proto.Message, utils.InstrumentType) {
+			return &investapi.Etf{}, utils.InstrumentType_INSTRUMENT_TYPE_ETF
+		},
+		"futures": func() (proto.Message, utils.InstrumentType) {
+			return &investapi.Future{}, utils.InstrumentType_INSTRUMENT_TYPE_FUTURE
+		},
+		"currencies": func() (proto.Message, utils.InstrumentType) {
+			return &investapi.Currency{}, utils.InstrumentType_INSTRUMENT_TYPE_CURRENCY
+		},
+	}
+	unmarshalOptions := protojson.UnmarshalOptions{DiscardUnknown: true}
+	var entries []*Entry
+	for key, items := range snapshot {
+		newItem, ok := newInstrument[key]
+		if !ok {
+			continue
+		}
+		for _, item := range items {
+			instrument, instrumentType := newItem()
+			err = unmarshalOptions.Unmarshal(item, instrument)
+			if err != nil {
+				return nil, fmt.Errorf("%v: %w", key, err)
+			}
+			entries = append(entries, newEntry(instrument.(namedInstrument), instrumentType, false))
+		}
+	}
+	if len(entries) == 0 {
+		return nil, errors.New("no instruments found")
+	}
+	return entries, nil
+}
+
+// Refresh replaces catalog's contents with the instruments available through the API
+func (c *Catalog) Refresh(client *client.Client) error {
+	var entries []*Entry
+	status := investapi.InstrumentStatus_INSTRUMENT_STATUS_BASE
+	shares, err := client.Shares(status)
+	if err != nil {
+		return err
+	}
+	for _, instrument := range shares {
+		entries = append(entries, newEntry(instrument, utils.InstrumentType_INSTRUMENT_TYPE_SHARE, true))
+	}
+	bonds, err := client.Bonds(status)
+	if err != nil {
+		return err
+	}
+	for _, instrument := range bonds {
+		entries = append(entries, newEntry(instrument, utils.InstrumentType_INSTRUMENT_TYPE_BOND, true))
+	}
+	etfs, err := client.Etfs(status)
+	if err != nil {
+		return err
+	}
+	for _, instrument := range etfs {
+		entries = append(entries, newEntry(instrument, utils.InstrumentType_INSTRUMENT_TYPE_ETF, true))
+	}
+	futures, err := client.Futures(status)
+	if err != nil {
+		return err
+	}
+	for _, instrument := range futures {
+		entries = append(entries, newEntry(instrument, utils.InstrumentType_INSTRUMENT_TYPE_FUTURE, true))
+	}
+	currencies, err := client.Currencies(status)
+	if err != nil {
+		return err
+	}
+	for _, instrument := range currencies {
+		entries = append(entries, newEntry(instrument, utils.InstrumentType_INSTRUMENT_TYPE_CURRENCY, true))
+	}
+	c.set(entries)
+	return nil
+}
+
+// Entries returns all instruments sorted by ticker
+func (c *Catalog) Entries() []*Entry {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return append([]*Entry(nil), c.entries...)
+}
+
+func (c *Catalog) GetByFigi(figi string) (*Entry, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	entry, ok := c.byFigi[figi]
+	return entry, ok
+}
+
+func (c *Catalog) GetByTicker(ticker string, classCode string) (*Entry, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	entry, ok := c.byTickerClassCode[tickerClassCodeKey(ticker, classCode)]
+	return entry, ok
+}
+
+// GetByIsin returns all listings of the ISIN
+func (c *Catalog) GetByIsin(isin string) []*Entry {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.byIsin[strings.ToUpper(isin)]
+}
+
+// Search finds instruments by FIGI, ticker, ISIN or name. Exact FIGI, ticker and ISIN matches go first,
+// then tickers starting with the query, then names containing it
+func (c *Catalog) Search(query string, limit int) []*Entry {
+	query = strings.ToUpper(strings.TrimSpace(query))
+	if query == "" || limit <= 0 {
+		return nil
+	}
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	const (
+		exactMatch = iota
+		tickerPrefixMatch
+		nameMatch
+		noMatch
+	)
+	rank := func(entry *Entry) int {
+		switch {
+		case entry.Figi == query || strings.ToUpper(entry.Ticker) == query || entry.Isin == query:
+			return exactMatch
+		case strings.HasPrefix(strings.ToUpper(entry.Ticker), query):
+			return tickerPrefixMatch
+		case strings.Contains(strings.ToUpper(entry.Name), query):
+			return nameMatch
+		}
+		return noMatch
+	}
+	found := make([][]*Entry, noMatch)
+	for _, entry := range c.entries {
+		if r := rank(entry); r != noMatch {
+			found[r] = append(found[r], entry)
+		}
+	}
+	var result []*Entry
+	for _, entries := range found {
+		for _, entry := range entries {
+			if len(result) == limit {
+				return result
+			}
+			result = append(result, entry)
+		}
+	}
+	return result
+}
+
+// Instrument returns the instrument with the given FIGI. Instruments loaded from a snapshot are requested
+// from the API once, since the snapshot might be outdated or incomplete.
+// FIGIs unknown to the catalog are requested as instrumentType and added to the catalog
+func (c *Catalog) Instrument(client *client.Client, figi string,
+	instrumentType utils.InstrumentType) (utils.InstrumentInterface, error) {
+	entry, ok := c.GetByFigi(figi)
+	if ok && entry.fresh {
+		return entry.instrument, nil
+	}
+	if ok {
+		instrumentType = entry.Type
+	}
+	instrument, err := client.InstrumentByFigi(figi, instrumentType)
+	if err != nil {
+		return nil, err
+	}
+	if named, isNamed := instrument.(namedInstrument); isNamed {
+		entry = newEntry(named, instrumentType, true)
+		c.mu.Lock()
+		c.put(entry)
+		c.mu.Unlock()
+	}
+	return instrument, nil
+}
+
+// put adds the entry or replaces the one with the same FIGI. Entries are never modified in place,
+// since they're shared with callers
+func (c *Catalog) put(entry *Entry) {
+	old, exists := c.byFigi[entry.Figi]
+	c.byFigi[entry.Figi] = entry
+	c.byTickerClassCode[tickerClassCodeKey(entry.Ticker, entry.ClassCode)] = entry
+	if exists {
+		if old.Isin != "" {
+			listings := c.byIsin[old.Isin][:0:0]
+			for _, listing := range c.byIsin[old.Isin] {
+				if listing != old {
+					listings = append(listings, listing)
+				}
+			}
+			c.byIsin[old.Isin] = listings
+		}
+		for i := range c.entries {
+			if c.entries[i] == old {
+				c.entries = append(c.entries[:i:i], c.entries[i+1:]...)
+				break
+			}
+		}
+	}
+	if entry.Isin != "" {
+		c.byIsin[entry.Isin] = append(c.byIsin[entry.Isin], entry)
+	}
+	i := sort.Search(len(c.entries), func(i int) bool { return c.entries[i].Ticker >= entry.Ticker })
+	entries := make([]*Entry, 0, len(c.entries)+1)
+	entries = append(entries, c.entries[:i]...)
+	entries = append(entries, entry)
+	c.entries = append(entries, c.entries[i:]...)
+}
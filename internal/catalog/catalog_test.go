@@ -0,0 +1,135 @@
+package catalog
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"tinkoff-invest-contest/internal/utils"
+)
+
+const testSnapshot = `{
+	"shares": [
+		{"figi": "BBG004730N88", "ticker": "SBER", "classCode": "TQBR", "isin": "RU0009029540", "name": "Сбер Банк", "currency": "rub", "lot": 10},
+		{"figi": "BBG004730RP0", "ticker": "GAZP", "classCode": "TQBR", "isin": "RU0007661625", "name": "Газпром", "currency": "rub", "lot": 10},
+		{"figi": "BBG004S681W1", "ticker": "MTSS", "classCode": "TQBR", "isin": "RU0007775219", "name": "МТС", "currency": "rub", "lot": 10}
+	],
+	"bonds": [
+		{"figi": "BBG00XH4W3N3", "ticker": "RU000A0ZYG52", "classCode": "TQCB", "isin": "RU000A0ZYG52", "name": "Сбербанк ПАО 001Р-SBER15", "currency": "rub", "lot": 1}
+	],
+	"futures": [
+		{"figi": "FUTSBRF06220", "ticker": "SRM2", "classCode": "SPBFUT", "name": "SBRF-6.22 Сбербанк", "currency": "rub", "lot": 1, "unknownField": 1}
+	],
+	"indicatives": []
+}`
+
+func newTestCatalog(t *testing.T) *Catalog {
+	path := filepath.Join(t.TempDir(), "instruments.json")
+	if err := os.WriteFile(path, []byte(testSnapshot), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	c := New()
+	if err := c.LoadSnapshot(path); err != nil {
+		t.Fatalf("LoadSnapshot() error = %v", err)
+	}
+	return c
+}
+
+func TestCatalog_LoadSnapshot(t *testing.T) {
+	c := newTestCatalog(t)
+	if c.Len() != 5 {
+		t.Fatalf("Len() = %v, want 5", c.Len())
+	}
+	tests := []struct {
+		name     string
+		figi     string
+		wantType utils.InstrumentType
+		wantIsin string
+	}{
+		{
+			name:     "test1",
+			figi:     "BBG004730N88",
+			wantType: utils.InstrumentType_INSTRUMENT_TYPE_SHARE,
+			wantIsin: "RU0009029540",
+		},
+		{
+			name:     "test2",
+			figi:     "BBG00XH4W3N3",
+			wantType: utils.InstrumentType_INSTRUMENT_TYPE_BOND,
+			wantIsin: "RU000A0ZYG52",
+		},
+		{
+			name:     "test3",
+			figi:     "FUTSBRF06220",
+			wantType: utils.InstrumentType_INSTRUMENT_TYPE_FUTURE,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			entry, ok := c.GetByFigi(tt.figi)
+			if !ok {
+				t.Fatalf("GetByFigi() found nothing")
+			}
+			if entry.Type != tt.wantType || entry.Isin != tt.wantIsin {
+				t.Errorf("GetByFigi() = %v %v, want %v %v", entry.Type, entry.Isin, tt.wantType, tt.wantIsin)
+			}
+		})
+	}
+	if entry, ok := c.GetByTicker("gazp", "tqbr"); !ok || entry.Figi != "BBG004730RP0" {
+		t.Errorf("GetByTicker() = %v, %v", entry, ok)
+	}
+	if entries := c.GetByIsin("RU0007775219"); len(entries) != 1 || entries[0].Ticker != "MTSS" {
+		t.Errorf("GetByIsin() = %v", entries)
+	}
+}
+
+func TestCatalog_Search(t *testing.T) {
+	c := newTestCatalog(t)
+	tests := []struct {
+		name  string
+		query string
+		limit int
+		want  []string
+	}{
+		{
+			name:  "test1",
+			query: "sber",
+			limit: 10,
+			want:  []string{"SBER", "RU000A0ZYG52"},
+		},
+		{
+			name:  "test2",
+			query: "RU0007661625",
+			limit: 10,
+			want:  []string{"GAZP"},
+		},
+		{
+			name:  "test3",
+			query: "сбер",
+			limit: 2,
+			want:  []string{"RU000A0ZYG52", "SBER"},
+		},
+		{
+			name:  "test4",
+			query: "m",
+			limit: 10,
+			want:  []string{"MTSS"},
+		},
+		{
+			name:  "test5",
+			query: " ",
+			limit: 10,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, entry := range c.Search(tt.query, tt.limit) {
+				got = append(got, entry.Ticker)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Search() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
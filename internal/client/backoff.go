@@ -0,0 +1,28 @@
+package client
+
+import (
+	"math/rand"
+	"time"
+)
+
+// Backoff computes jittered exponential delays between consecutive attempts
+type Backoff struct {
+	Base time.Duration
+	Max  time.Duration
+}
+
+// Duration returns a random delay in [ceil/2, ceil], where ceil = min(Max, Base * 2^attempt)
+func (b Backoff) Duration(attempt int) time.Duration {
+	ceil := b.Base
+	for i := 0; i < attempt && ceil < b.Max; i++ {
+		ceil *= 2
+	}
+	if ceil > b.Max {
+		ceil = b.Max
+	}
+	if ceil <= 0 {
+		return 0
+	}
+	half := ceil / 2
+	return half + time.Duration(rand.Int63n(int64(ceil-half)+1))
+}
@@ -0,0 +1,50 @@
+package client
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBackoff_Duration(t *testing.T) {
+	type args struct {
+		attempt int
+	}
+	tests := []struct {
+		name    string
+		backoff Backoff
+		args    args
+		wantMin time.Duration
+		wantMax time.Duration
+	}{
+		{
+			name:    "test1",
+			backoff: Backoff{Base: 100 * time.Millisecond, Max: 10 * time.Second},
+			args:    args{attempt: 0},
+			wantMin: 50 * time.Millisecond,
+			wantMax: 100 * time.Millisecond,
+		},
+		{
+			name:    "test2",
+			backoff: Backoff{Base: 100 * time.Millisecond, Max: 10 * time.Second},
+			args:    args{attempt: 3},
+			wantMin: 400 * time.Millisecond,
+			wantMax: 800 * time.Millisecond,
+		},
+		{
+			name:    "test3",
+			backoff: Backoff{Base: 100 * time.Millisecond, Max: time.Second},
+			args:    args{attempt: 50},
+			wantMin: 500 * time.Millisecond,
+			wantMax: time.Second,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for i := 0; i < 100; i++ {
+				if got := tt.backoff.Duration(tt.args.attempt); got < tt.wantMin || got > tt.wantMax {
+					t.Fatalf("Duration() = %v, want within [%v, %v]", got, tt.wantMin, tt.wantMax)
+				}
+			}
+		})
+	}
+}
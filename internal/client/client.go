@@ -4,10 +4,12 @@ import (
 	"context"
 	"crypto/tls"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/connectivity"
 	"google.golang.org/grpc/credentials"
 	"google.golang.org/grpc/metadata"
 	"google.golang.org/protobuf/types/known/timestamppb"
-	"log"
+	"sync"
+	"sync/atomic"
 	"time"
 	"tinkoff-invest-contest/internal/client/investapi"
 	"tinkoff-invest-contest/internal/utils"
@@ -20,8 +22,27 @@ type Client struct {
 	token   string
 	appname string
 
-	marketDataStream investapi.MarketDataStreamService_MarketDataStreamClient
-	tradesStream     investapi.OrdersStreamService_TradesStreamClient
+	conn        *grpc.ClientConn
+	connConfig  ConnectionConfig
+	callConfig  CallConfig
+	rateLimiter *rateLimiter
+	probeFailed int32
+
+	// Unix nanoseconds of the last message received from the streams
+	lastMarketDataMessageTS int64
+	lastTradesMessageTS     int64
+	tradesStreamAlive       int32
+
+	streamBackoff Backoff
+
+	marketDataStreamMu    sync.Mutex
+	marketDataStream      investapi.MarketDataStreamService_MarketDataStreamClient
+	closeMarketDataStream context.CancelFunc
+
+	tradesStreamMu       sync.Mutex
+	tradesStream         investapi.OrdersStreamService_TradesStreamClient
+	closeTradesStream    context.CancelFunc
+	tradesStreamAccounts []string
 
 	InstrumentsService      investapi.InstrumentsServiceClient
 	OperationsService       investapi.OperationsServiceClient
@@ -36,43 +57,59 @@ type Client struct {
 
 // NewClient creates a new Tinkoff Invest API gRPC client
 func NewClient(token string) *Client {
-	utils.WaitForInternetConnection()
 	var err error
-	clientConn, err := grpc.Dial(ServiceAddress, grpc.WithTransportCredentials(credentials.NewTLS(&tls.Config{})))
-	utils.MaybeCrash(err)
 	client := Client{
-		token:                   token,
-		InstrumentsService:      investapi.NewInstrumentsServiceClient(clientConn),
-		OperationsService:       investapi.NewOperationsServiceClient(clientConn),
-		OrdersService:           investapi.NewOrdersServiceClient(clientConn),
-		MarketDataService:       investapi.NewMarketDataServiceClient(clientConn),
-		SandboxService:          investapi.NewSandboxServiceClient(clientConn),
-		UsersService:            investapi.NewUsersServiceClient(clientConn),
-		StopOrdersService:       investapi.NewStopOrdersServiceClient(clientConn),
-		MarketDataStreamService: investapi.NewMarketDataStreamServiceClient(clientConn),
-		OrdersStreamService:     investapi.NewOrdersStreamServiceClient(clientConn),
+		token:       token,
+		connConfig:  GetConnectionConfig(),
+		callConfig:  GetCallConfig(),
+		rateLimiter: newRateLimiter(),
+		streamBackoff: Backoff{
+			Base: time.Second,
+			Max:  time.Minute,
+		},
 	}
+	client.conn, err = grpc.Dial(ServiceAddress, append(client.connConfig.dialOptions(),
+		grpc.WithTransportCredentials(credentials.NewTLS(&tls.Config{})),
+		grpc.WithChainUnaryInterceptor(metricsInterceptor, client.callInterceptor, unavailableInterceptor),
+	)...)
+	utils.MaybeCrash(err)
+	client.InstrumentsService = investapi.NewInstrumentsServiceClient(client.conn)
+	client.OperationsService = investapi.NewOperationsServiceClient(client.conn)
+	client.OrdersService = investapi.NewOrdersServiceClient(client.conn)
+	client.MarketDataService = investapi.NewMarketDataServiceClient(client.conn)
+	client.SandboxService = investapi.NewSandboxServiceClient(client.conn)
+	client.UsersService = investapi.NewUsersServiceClient(client.conn)
+	client.StopOrdersService = investapi.NewStopOrdersServiceClient(client.conn)
+	client.MarketDataStreamService = investapi.NewMarketDataStreamServiceClient(client.conn)
+	client.OrdersStreamService = investapi.NewOrdersStreamServiceClient(client.conn)
+
+	client.monitorConnection()
 
 	return &client
 }
 
-// InitMarketDataStream initializes a market data stream.
-// Call it after creating a Client if you need any data from Invest API market data streams
-func (c *Client) InitMarketDataStream() {
-	var err error
-	c.marketDataStream, err = c.MarketDataStreamService.MarketDataStream(
-		newContextWithBearerToken(c.token),
-	)
-	utils.MaybeCrash(err)
+// ConnState returns the state of the underlying gRPC connection
+func (c *Client) ConnState() connectivity.State {
+	return c.conn.GetState()
 }
 
-func (c *Client) InitTradesStream(accountIds []string) {
-	var err error
-	c.tradesStream, err = c.OrdersStreamService.TradesStream(
-		newContextWithBearerToken(c.token),
-		&investapi.TradesStreamRequest{Accounts: accountIds},
-	)
-	utils.MaybeCrash(err)
+func (c *Client) LastMarketDataMessageTime() time.Time {
+	return unixNanoToTime(atomic.LoadInt64(&c.lastMarketDataMessageTS))
+}
+
+func (c *Client) LastTradesMessageTime() time.Time {
+	return unixNanoToTime(atomic.LoadInt64(&c.lastTradesMessageTS))
+}
+
+func (c *Client) IsTradesStreamAlive() bool {
+	return atomic.LoadInt32(&c.tradesStreamAlive) == 1
+}
+
+func unixNanoToTime(ts int64) time.Time {
+	if ts == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, ts)
 }
 
 func newContextWithBearerToken(token string) context.Context {
@@ -83,8 +120,26 @@ func newContextWithBearerToken(token string) context.Context {
 	return metadata.NewOutgoingContext(context.Background(), md)
 }
 
+func (c *Client) Bonds(instrumentStatus investapi.InstrumentStatus) ([]*investapi.Bond, error) {
+	if err := c.ensureAvailable(); err != nil {
+		return nil, err
+	}
+	bondsResp, err := c.InstrumentsService.Bonds(
+		newContextWithBearerToken(c.token),
+		&investapi.InstrumentsRequest{
+			InstrumentStatus: instrumentStatus,
+		},
+	)
+	if err != nil {
+		return nil, err
+	}
+	return bondsResp.Instruments, nil
+}
+
 func (c *Client) BondBy(idType investapi.InstrumentIdType, classCode string, id string) (*investapi.Bond, error) {
-	utils.WaitForInternetConnection()
+	if err := c.ensureAvailable(); err != nil {
+		return nil, err
+	}
 	bondResp, err := c.InstrumentsService.BondBy(
 		newContextWithBearerToken(c.token),
 		&investapi.InstrumentRequest{
@@ -99,8 +154,58 @@ func (c *Client) BondBy(idType investapi.InstrumentIdType, classCode string, id
 	return bondResp.Instrument, nil
 }
 
+func (c *Client) CancelOrder(accountId string, orderId string) (*investapi.CancelOrderResponse, error) {
+	if err := c.ensureAvailable(); err != nil {
+		return nil, err
+	}
+	cancelOrderResp, err := c.OrdersService.CancelOrder(
+		newContextWithBearerToken(c.token),
+		&investapi.CancelOrderRequest{
+			AccountId: accountId,
+			OrderId:   orderId,
+		},
+	)
+	if err != nil {
+		return nil, err
+	}
+	return cancelOrderResp, nil
+}
+
+func (c *Client) CancelSandboxOrder(accountId string, orderId string) (*investapi.CancelOrderResponse, error) {
+	if err := c.ensureAvailable(); err != nil {
+		return nil, err
+	}
+	cancelOrderResp, err := c.SandboxService.CancelSandboxOrder(
+		newContextWithBearerToken(c.token),
+		&investapi.CancelOrderRequest{
+			AccountId: accountId,
+			OrderId:   orderId,
+		},
+	)
+	if err != nil {
+		return nil, err
+	}
+	return cancelOrderResp, nil
+}
+
+func (c *Client) CancelStopOrder(accountId string, stopOrderId string) error {
+	if err := c.ensureAvailable(); err != nil {
+		return err
+	}
+	_, err := c.StopOrdersService.CancelStopOrder(
+		newContextWithBearerToken(c.token),
+		&investapi.CancelStopOrderRequest{
+			AccountId:   accountId,
+			StopOrderId: stopOrderId,
+		},
+	)
+	return err
+}
+
 func (c *Client) CloseSandboxAccount(accountId string) (*investapi.CloseSandboxAccountResponse, error) {
-	utils.WaitForInternetConnection()
+	if err := c.ensureAvailable(); err != nil {
+		return nil, err
+	}
 	closeSandboxAccountResp, err := c.SandboxService.CloseSandboxAccount(
 		newContextWithBearerToken(c.token),
 		&investapi.CloseSandboxAccountRequest{
@@ -113,8 +218,26 @@ func (c *Client) CloseSandboxAccount(accountId string) (*investapi.CloseSandboxA
 	return closeSandboxAccountResp, nil
 }
 
+func (c *Client) Currencies(instrumentStatus investapi.InstrumentStatus) ([]*investapi.Currency, error) {
+	if err := c.ensureAvailable(); err != nil {
+		return nil, err
+	}
+	currenciesResp, err := c.InstrumentsService.Currencies(
+		newContextWithBearerToken(c.token),
+		&investapi.InstrumentsRequest{
+			InstrumentStatus: instrumentStatus,
+		},
+	)
+	if err != nil {
+		return nil, err
+	}
+	return currenciesResp.Instruments, nil
+}
+
 func (c *Client) CurrencyBy(idType investapi.InstrumentIdType, classCode string, id string) (*investapi.Currency, error) {
-	utils.WaitForInternetConnection()
+	if err := c.ensureAvailable(); err != nil {
+		return nil, err
+	}
 	currencyResp, err := c.InstrumentsService.CurrencyBy(
 		newContextWithBearerToken(c.token),
 		&investapi.InstrumentRequest{
@@ -129,8 +252,26 @@ func (c *Client) CurrencyBy(idType investapi.InstrumentIdType, classCode string,
 	return currencyResp.Instrument, nil
 }
 
+func (c *Client) Etfs(instrumentStatus investapi.InstrumentStatus) ([]*investapi.Etf, error) {
+	if err := c.ensureAvailable(); err != nil {
+		return nil, err
+	}
+	etfsResp, err := c.InstrumentsService.Etfs(
+		newContextWithBearerToken(c.token),
+		&investapi.InstrumentsRequest{
+			InstrumentStatus: instrumentStatus,
+		},
+	)
+	if err != nil {
+		return nil, err
+	}
+	return etfsResp.Instruments, nil
+}
+
 func (c *Client) EtfBy(idType investapi.InstrumentIdType, classCode string, id string) (*investapi.Etf, error) {
-	utils.WaitForInternetConnection()
+	if err := c.ensureAvailable(); err != nil {
+		return nil, err
+	}
 	etfResp, err := c.InstrumentsService.EtfBy(
 		newContextWithBearerToken(c.token),
 		&investapi.InstrumentRequest{
@@ -145,8 +286,26 @@ func (c *Client) EtfBy(idType investapi.InstrumentIdType, classCode string, id s
 	return etfResp.Instrument, nil
 }
 
+func (c *Client) Futures(instrumentStatus investapi.InstrumentStatus) ([]*investapi.Future, error) {
+	if err := c.ensureAvailable(); err != nil {
+		return nil, err
+	}
+	futuresResp, err := c.InstrumentsService.Futures(
+		newContextWithBearerToken(c.token),
+		&investapi.InstrumentsRequest{
+			InstrumentStatus: instrumentStatus,
+		},
+	)
+	if err != nil {
+		return nil, err
+	}
+	return futuresResp.Instruments, nil
+}
+
 func (c *Client) FutureBy(idType investapi.InstrumentIdType, classCode string, id string) (*investapi.Future, error) {
-	utils.WaitForInternetConnection()
+	if err := c.ensureAvailable(); err != nil {
+		return nil, err
+	}
 	futureResp, err := c.InstrumentsService.FutureBy(
 		newContextWithBearerToken(c.token),
 		&investapi.InstrumentRequest{
@@ -162,7 +321,9 @@ func (c *Client) FutureBy(idType investapi.InstrumentIdType, classCode string, i
 }
 
 func (c *Client) GetAccounts() ([]*investapi.Account, error) {
-	utils.WaitForInternetConnection()
+	if err := c.ensureAvailable(); err != nil {
+		return nil, err
+	}
 	accountsResp, err := c.UsersService.GetAccounts(
 		newContextWithBearerToken(c.token),
 		&investapi.GetAccountsRequest{},
@@ -174,7 +335,9 @@ func (c *Client) GetAccounts() ([]*investapi.Account, error) {
 }
 
 func (c *Client) GetCandles(figi string, from time.Time, to time.Time, interval investapi.CandleInterval) ([]*investapi.HistoricCandle, error) {
-	utils.WaitForInternetConnection()
+	if err := c.ensureAvailable(); err != nil {
+		return nil, err
+	}
 	candlesResp, err := c.MarketDataService.GetCandles(
 		newContextWithBearerToken(c.token),
 		&investapi.GetCandlesRequest{
@@ -190,8 +353,29 @@ func (c *Client) GetCandles(figi string, from time.Time, to time.Time, interval
 	return candlesResp.Candles, nil
 }
 
+// GetLastTrades returns the instrument's trades between from and to, the API only has the trades of the last hour
+func (c *Client) GetLastTrades(figi string, from time.Time, to time.Time) ([]*investapi.Trade, error) {
+	if err := c.ensureAvailable(); err != nil {
+		return nil, err
+	}
+	tradesResp, err := c.MarketDataService.GetLastTrades(
+		newContextWithBearerToken(c.token),
+		&investapi.GetLastTradesRequest{
+			Figi: figi,
+			From: timestamppb.New(from),
+			To:   timestamppb.New(to),
+		},
+	)
+	if err != nil {
+		return nil, err
+	}
+	return tradesResp.Trades, nil
+}
+
 func (c *Client) GetInfo() (*investapi.GetInfoResponse, error) {
-	utils.WaitForInternetConnection()
+	if err := c.ensureAvailable(); err != nil {
+		return nil, err
+	}
 	infoResp, err := c.UsersService.GetInfo(
 		newContextWithBearerToken(c.token),
 		&investapi.GetInfoRequest{},
@@ -203,7 +387,9 @@ func (c *Client) GetInfo() (*investapi.GetInfoResponse, error) {
 }
 
 func (c *Client) GetMarginAttributes(accountId string) (*investapi.GetMarginAttributesResponse, error) {
-	utils.WaitForInternetConnection()
+	if err := c.ensureAvailable(); err != nil {
+		return nil, err
+	}
 	marginAttributesResp, err := c.UsersService.GetMarginAttributes(
 		newContextWithBearerToken(c.token),
 		&investapi.GetMarginAttributesRequest{
@@ -216,8 +402,48 @@ func (c *Client) GetMarginAttributes(accountId string) (*investapi.GetMarginAttr
 	return marginAttributesResp, nil
 }
 
+// GetOperations returns the executed operations of the account on the instrument between from and to
+func (c *Client) GetOperations(accountId string, figi string, from time.Time, to time.Time) ([]*investapi.Operation, error) {
+	if err := c.ensureAvailable(); err != nil {
+		return nil, err
+	}
+	operationsResp, err := c.OperationsService.GetOperations(
+		newContextWithBearerToken(c.token),
+		&investapi.OperationsRequest{
+			AccountId: accountId,
+			From:      timestamppb.New(from),
+			To:        timestamppb.New(to),
+			State:     investapi.OperationState_OPERATION_STATE_EXECUTED,
+			Figi:      figi,
+		},
+	)
+	if err != nil {
+		return nil, err
+	}
+	return operationsResp.Operations, nil
+}
+
+func (c *Client) GetOrderBook(figi string, depth int32) (*investapi.GetOrderBookResponse, error) {
+	if err := c.ensureAvailable(); err != nil {
+		return nil, err
+	}
+	orderBookResp, err := c.MarketDataService.GetOrderBook(
+		newContextWithBearerToken(c.token),
+		&investapi.GetOrderBookRequest{
+			Figi:  figi,
+			Depth: depth,
+		},
+	)
+	if err != nil {
+		return nil, err
+	}
+	return orderBookResp, nil
+}
+
 func (c *Client) GetOrderState(accountId string, orderId string) (*investapi.OrderState, error) {
-	utils.WaitForInternetConnection()
+	if err := c.ensureAvailable(); err != nil {
+		return nil, err
+	}
 	orderState, err := c.OrdersService.GetOrderState(
 		newContextWithBearerToken(c.token),
 		&investapi.GetOrderStateRequest{
@@ -231,8 +457,26 @@ func (c *Client) GetOrderState(accountId string, orderId string) (*investapi.Ord
 	return orderState, nil
 }
 
+func (c *Client) GetOrders(accountId string) ([]*investapi.OrderState, error) {
+	if err := c.ensureAvailable(); err != nil {
+		return nil, err
+	}
+	ordersResp, err := c.OrdersService.GetOrders(
+		newContextWithBearerToken(c.token),
+		&investapi.GetOrdersRequest{
+			AccountId: accountId,
+		},
+	)
+	if err != nil {
+		return nil, err
+	}
+	return ordersResp.Orders, nil
+}
+
 func (c *Client) GetPortfolio(accountId string) (*investapi.PortfolioResponse, error) {
-	utils.WaitForInternetConnection()
+	if err := c.ensureAvailable(); err != nil {
+		return nil, err
+	}
 	portfolioResp, err := c.OperationsService.GetPortfolio(
 		newContextWithBearerToken(c.token),
 		&investapi.PortfolioRequest{
@@ -246,7 +490,9 @@ func (c *Client) GetPortfolio(accountId string) (*investapi.PortfolioResponse, e
 }
 
 func (c *Client) GetPositions(accountId string) (*investapi.PositionsResponse, error) {
-	utils.WaitForInternetConnection()
+	if err := c.ensureAvailable(); err != nil {
+		return nil, err
+	}
 	positionsResp, err := c.OperationsService.GetPositions(
 		newContextWithBearerToken(c.token),
 		&investapi.PositionsRequest{
@@ -260,7 +506,9 @@ func (c *Client) GetPositions(accountId string) (*investapi.PositionsResponse, e
 }
 
 func (c *Client) GetSandboxAccounts() ([]*investapi.Account, error) {
-	utils.WaitForInternetConnection()
+	if err := c.ensureAvailable(); err != nil {
+		return nil, err
+	}
 	sandboxAccountsResp, err := c.SandboxService.GetSandboxAccounts(
 		newContextWithBearerToken(c.token),
 		&investapi.GetAccountsRequest{},
@@ -272,7 +520,9 @@ func (c *Client) GetSandboxAccounts() ([]*investapi.Account, error) {
 }
 
 func (c *Client) GetSandboxOrderState(accountId string, orderId string) (*investapi.OrderState, error) {
-	utils.WaitForInternetConnection()
+	if err := c.ensureAvailable(); err != nil {
+		return nil, err
+	}
 	orderState, err := c.SandboxService.GetSandboxOrderState(
 		newContextWithBearerToken(c.token),
 		&investapi.GetOrderStateRequest{
@@ -286,8 +536,26 @@ func (c *Client) GetSandboxOrderState(accountId string, orderId string) (*invest
 	return orderState, nil
 }
 
+func (c *Client) GetSandboxOrders(accountId string) ([]*investapi.OrderState, error) {
+	if err := c.ensureAvailable(); err != nil {
+		return nil, err
+	}
+	ordersResp, err := c.SandboxService.GetSandboxOrders(
+		newContextWithBearerToken(c.token),
+		&investapi.GetOrdersRequest{
+			AccountId: accountId,
+		},
+	)
+	if err != nil {
+		return nil, err
+	}
+	return ordersResp.Orders, nil
+}
+
 func (c *Client) GetSandboxPortfolio(accountId string) (*investapi.PortfolioResponse, error) {
-	utils.WaitForInternetConnection()
+	if err := c.ensureAvailable(); err != nil {
+		return nil, err
+	}
 	portfolioResp, err := c.SandboxService.GetSandboxPortfolio(
 		newContextWithBearerToken(c.token),
 		&investapi.PortfolioRequest{
@@ -301,7 +569,9 @@ func (c *Client) GetSandboxPortfolio(accountId string) (*investapi.PortfolioResp
 }
 
 func (c *Client) GetSandboxPositions(accountId string) (*investapi.PositionsResponse, error) {
-	utils.WaitForInternetConnection()
+	if err := c.ensureAvailable(); err != nil {
+		return nil, err
+	}
 	positionsResp, err := c.SandboxService.GetSandboxPositions(
 		newContextWithBearerToken(c.token),
 		&investapi.PositionsRequest{
@@ -314,8 +584,42 @@ func (c *Client) GetSandboxPositions(accountId string) (*investapi.PositionsResp
 	return positionsResp, nil
 }
 
+func (c *Client) GetStopOrders(accountId string) ([]*investapi.StopOrder, error) {
+	if err := c.ensureAvailable(); err != nil {
+		return nil, err
+	}
+	stopOrdersResp, err := c.StopOrdersService.GetStopOrders(
+		newContextWithBearerToken(c.token),
+		&investapi.GetStopOrdersRequest{
+			AccountId: accountId,
+		},
+	)
+	if err != nil {
+		return nil, err
+	}
+	return stopOrdersResp.StopOrders, nil
+}
+
+func (c *Client) GetTradingStatus(figi string) (*investapi.GetTradingStatusResponse, error) {
+	if err := c.ensureAvailable(); err != nil {
+		return nil, err
+	}
+	tradingStatusResp, err := c.MarketDataService.GetTradingStatus(
+		newContextWithBearerToken(c.token),
+		&investapi.GetTradingStatusRequest{
+			Figi: figi,
+		},
+	)
+	if err != nil {
+		return nil, err
+	}
+	return tradingStatusResp, nil
+}
+
 func (c *Client) OpenSandboxAccount() (*investapi.OpenSandboxAccountResponse, error) {
-	utils.WaitForInternetConnection()
+	if err := c.ensureAvailable(); err != nil {
+		return nil, err
+	}
 	openSandboxAccountResp, err := c.SandboxService.OpenSandboxAccount(
 		newContextWithBearerToken(c.token),
 		&investapi.OpenSandboxAccountRequest{},
@@ -328,7 +632,9 @@ func (c *Client) OpenSandboxAccount() (*investapi.OpenSandboxAccountResponse, er
 
 func (c *Client) PostOrder(figi string, quantity int64, price float64, direction investapi.OrderDirection,
 	accountId string, orderType investapi.OrderType, orderId string) (*investapi.PostOrderResponse, error) {
-	utils.WaitForInternetConnection()
+	if err := c.ensureAvailable(); err != nil {
+		return nil, err
+	}
 	postOrderResp, err := c.OrdersService.PostOrder(
 		newContextWithBearerToken(c.token),
 		&investapi.PostOrderRequest{
@@ -349,7 +655,9 @@ func (c *Client) PostOrder(figi string, quantity int64, price float64, direction
 
 func (c *Client) PostSandboxOrder(figi string, quantity int64, price float64, direction investapi.OrderDirection,
 	accountId string, orderType investapi.OrderType, orderId string) (*investapi.PostOrderResponse, error) {
-	utils.WaitForInternetConnection()
+	if err := c.ensureAvailable(); err != nil {
+		return nil, err
+	}
 	postOrderResp, err := c.SandboxService.PostSandboxOrder(
 		newContextWithBearerToken(c.token),
 		&investapi.PostOrderRequest{
@@ -368,35 +676,35 @@ func (c *Client) PostSandboxOrder(figi string, quantity int64, price float64, di
 	return postOrderResp, nil
 }
 
-func (c *Client) RunMarketDataStreamLoop(handleResponse func(marketDataResp *investapi.MarketDataResponse),
-	resubscribe func() error) {
-	var err error
-	var resp *investapi.MarketDataResponse
-	utils.WaitForInternetConnection()
-	for {
-		if err != nil {
-			time.Sleep(5 * time.Second)
-			log.Println("error:", err.Error())
-			log.Println("market data stream has collapsed, resubscribing...")
-			err = resubscribe()
-		} else {
-			resp, err = c.marketDataStream.Recv()
-			go handleResponse(resp)
-		}
+// PostStopOrder posts a good-till-cancel stop order, price is only used for stop-limit orders
+func (c *Client) PostStopOrder(figi string, quantity int64, price float64, stopPrice float64, direction investapi.OrderDirection,
+	accountId string, stopOrderType investapi.StopOrderType) (string, error) {
+	if err := c.ensureAvailable(); err != nil {
+		return "", err
 	}
-}
-
-func (c *Client) RunTradesStreamLoop(handleResponse func(tradesResp *investapi.TradesStreamResponse)) {
-	var resp *investapi.TradesStreamResponse
-	utils.WaitForInternetConnection()
-	for {
-		resp, _ = c.tradesStream.Recv()
-		go handleResponse(resp)
+	req := &investapi.PostStopOrderRequest{
+		Figi:           figi,
+		Quantity:       quantity,
+		StopPrice:      utils.FloatToQuotation(stopPrice),
+		Direction:      investapi.StopOrderDirection(direction),
+		AccountId:      accountId,
+		ExpirationType: investapi.StopOrderExpirationType_STOP_ORDER_EXPIRATION_TYPE_GOOD_TILL_CANCEL,
+		StopOrderType:  stopOrderType,
 	}
+	if stopOrderType == investapi.StopOrderType_STOP_ORDER_TYPE_STOP_LIMIT {
+		req.Price = utils.FloatToQuotation(price)
+	}
+	postStopOrderResp, err := c.StopOrdersService.PostStopOrder(newContextWithBearerToken(c.token), req)
+	if err != nil {
+		return "", err
+	}
+	return postStopOrderResp.StopOrderId, nil
 }
 
 func (c *Client) SandboxPayIn(accountId string, currency string, amount float64) (*investapi.SandboxPayInResponse, error) {
-	utils.WaitForInternetConnection()
+	if err := c.ensureAvailable(); err != nil {
+		return nil, err
+	}
 	sandboxPayInResp, err := c.SandboxService.SandboxPayIn(
 		newContextWithBearerToken(c.token),
 		&investapi.SandboxPayInRequest{
@@ -410,8 +718,26 @@ func (c *Client) SandboxPayIn(accountId string, currency string, amount float64)
 	return sandboxPayInResp, nil
 }
 
+func (c *Client) Shares(instrumentStatus investapi.InstrumentStatus) ([]*investapi.Share, error) {
+	if err := c.ensureAvailable(); err != nil {
+		return nil, err
+	}
+	sharesResp, err := c.InstrumentsService.Shares(
+		newContextWithBearerToken(c.token),
+		&investapi.InstrumentsRequest{
+			InstrumentStatus: instrumentStatus,
+		},
+	)
+	if err != nil {
+		return nil, err
+	}
+	return sharesResp.Instruments, nil
+}
+
 func (c *Client) ShareBy(idType investapi.InstrumentIdType, classCode string, id string) (*investapi.Share, error) {
-	utils.WaitForInternetConnection()
+	if err := c.ensureAvailable(); err != nil {
+		return nil, err
+	}
 	shareResp, err := c.InstrumentsService.ShareBy(
 		newContextWithBearerToken(c.token),
 		&investapi.InstrumentRequest{
@@ -427,14 +753,16 @@ func (c *Client) ShareBy(idType investapi.InstrumentIdType, classCode string, id
 }
 
 func (c *Client) SubscribeCandles(figi string, interval investapi.SubscriptionInterval) error {
-	utils.WaitForInternetConnection()
+	if err := c.ensureAvailable(); err != nil {
+		return err
+	}
 	instruments := []*investapi.CandleInstrument{
 		{
 			Figi:     figi,
 			Interval: interval,
 		},
 	}
-	err := c.marketDataStream.Send(&investapi.MarketDataRequest{Payload: &investapi.MarketDataRequest_SubscribeCandlesRequest{
+	err := c.sendMarketDataRequest(&investapi.MarketDataRequest{Payload: &investapi.MarketDataRequest_SubscribeCandlesRequest{
 		SubscribeCandlesRequest: &investapi.SubscribeCandlesRequest{
 			SubscriptionAction: investapi.SubscriptionAction_SUBSCRIPTION_ACTION_SUBSCRIBE,
 			Instruments:        instruments,
@@ -444,11 +772,13 @@ func (c *Client) SubscribeCandles(figi string, interval investapi.SubscriptionIn
 }
 
 func (c *Client) SubscribeInfo(figi string) error {
-	utils.WaitForInternetConnection()
+	if err := c.ensureAvailable(); err != nil {
+		return err
+	}
 	instruments := []*investapi.InfoInstrument{
 		{Figi: figi},
 	}
-	err := c.marketDataStream.Send(&investapi.MarketDataRequest{Payload: &investapi.MarketDataRequest_SubscribeInfoRequest{
+	err := c.sendMarketDataRequest(&investapi.MarketDataRequest{Payload: &investapi.MarketDataRequest_SubscribeInfoRequest{
 		SubscribeInfoRequest: &investapi.SubscribeInfoRequest{
 			SubscriptionAction: investapi.SubscriptionAction_SUBSCRIPTION_ACTION_SUBSCRIBE,
 			Instruments:        instruments,
@@ -458,14 +788,16 @@ func (c *Client) SubscribeInfo(figi string) error {
 }
 
 func (c *Client) SubscribeOrderBook(figi string, depth int32) error {
-	utils.WaitForInternetConnection()
+	if err := c.ensureAvailable(); err != nil {
+		return err
+	}
 	instruments := []*investapi.OrderBookInstrument{
 		{
 			Figi:  figi,
 			Depth: depth,
 		},
 	}
-	err := c.marketDataStream.Send(&investapi.MarketDataRequest{Payload: &investapi.MarketDataRequest_SubscribeOrderBookRequest{
+	err := c.sendMarketDataRequest(&investapi.MarketDataRequest{Payload: &investapi.MarketDataRequest_SubscribeOrderBookRequest{
 		SubscribeOrderBookRequest: &investapi.SubscribeOrderBookRequest{
 			SubscriptionAction: investapi.SubscriptionAction_SUBSCRIPTION_ACTION_SUBSCRIBE,
 			Instruments:        instruments,
@@ -474,15 +806,33 @@ func (c *Client) SubscribeOrderBook(figi string, depth int32) error {
 	return err
 }
 
+func (c *Client) SubscribeTrades(figi string) error {
+	if err := c.ensureAvailable(); err != nil {
+		return err
+	}
+	instruments := []*investapi.TradeInstrument{
+		{Figi: figi},
+	}
+	err := c.sendMarketDataRequest(&investapi.MarketDataRequest{Payload: &investapi.MarketDataRequest_SubscribeTradesRequest{
+		SubscribeTradesRequest: &investapi.SubscribeTradesRequest{
+			SubscriptionAction: investapi.SubscriptionAction_SUBSCRIPTION_ACTION_SUBSCRIBE,
+			Instruments:        instruments,
+		},
+	}})
+	return err
+}
+
 func (c *Client) UnsubscribeCandles(figi string, interval investapi.SubscriptionInterval) error {
-	utils.WaitForInternetConnection()
+	if err := c.ensureAvailable(); err != nil {
+		return err
+	}
 	instruments := []*investapi.CandleInstrument{
 		{
 			Figi:     figi,
 			Interval: interval,
 		},
 	}
-	err := c.marketDataStream.Send(&investapi.MarketDataRequest{Payload: &investapi.MarketDataRequest_SubscribeCandlesRequest{
+	err := c.sendMarketDataRequest(&investapi.MarketDataRequest{Payload: &investapi.MarketDataRequest_SubscribeCandlesRequest{
 		SubscribeCandlesRequest: &investapi.SubscribeCandlesRequest{
 			SubscriptionAction: investapi.SubscriptionAction_SUBSCRIPTION_ACTION_UNSUBSCRIBE,
 			Instruments:        instruments,
@@ -492,11 +842,13 @@ func (c *Client) UnsubscribeCandles(figi string, interval investapi.Subscription
 }
 
 func (c *Client) UnsubscribeInfo(figi string) error {
-	utils.WaitForInternetConnection()
+	if err := c.ensureAvailable(); err != nil {
+		return err
+	}
 	instruments := []*investapi.InfoInstrument{
 		{Figi: figi},
 	}
-	err := c.marketDataStream.Send(&investapi.MarketDataRequest{Payload: &investapi.MarketDataRequest_SubscribeInfoRequest{
+	err := c.sendMarketDataRequest(&investapi.MarketDataRequest{Payload: &investapi.MarketDataRequest_SubscribeInfoRequest{
 		SubscribeInfoRequest: &investapi.SubscribeInfoRequest{
 			SubscriptionAction: investapi.SubscriptionAction_SUBSCRIPTION_ACTION_UNSUBSCRIBE,
 			Instruments:        instruments,
@@ -506,14 +858,16 @@ func (c *Client) UnsubscribeInfo(figi string) error {
 }
 
 func (c *Client) UnsubscribeOrderBook(figi string, depth int32) error {
-	utils.WaitForInternetConnection()
+	if err := c.ensureAvailable(); err != nil {
+		return err
+	}
 	instruments := []*investapi.OrderBookInstrument{
 		{
 			Figi:  figi,
 			Depth: depth,
 		},
 	}
-	err := c.marketDataStream.Send(&investapi.MarketDataRequest{Payload: &investapi.MarketDataRequest_SubscribeOrderBookRequest{
+	err := c.sendMarketDataRequest(&investapi.MarketDataRequest{Payload: &investapi.MarketDataRequest_SubscribeOrderBookRequest{
 		SubscribeOrderBookRequest: &investapi.SubscribeOrderBookRequest{
 			SubscriptionAction: investapi.SubscriptionAction_SUBSCRIPTION_ACTION_UNSUBSCRIBE,
 			Instruments:        instruments,
@@ -521,3 +875,19 @@ func (c *Client) UnsubscribeOrderBook(figi string, depth int32) error {
 	}})
 	return err
 }
+
+func (c *Client) UnsubscribeTrades(figi string) error {
+	if err := c.ensureAvailable(); err != nil {
+		return err
+	}
+	instruments := []*investapi.TradeInstrument{
+		{Figi: figi},
+	}
+	err := c.sendMarketDataRequest(&investapi.MarketDataRequest{Payload: &investapi.MarketDataRequest_SubscribeTradesRequest{
+		SubscribeTradesRequest: &investapi.SubscribeTradesRequest{
+			SubscriptionAction: investapi.SubscriptionAction_SUBSCRIPTION_ACTION_UNSUBSCRIBE,
+			Instruments:        instruments,
+		},
+	}})
+	return err
+}
@@ -0,0 +1,197 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/connectivity"
+	"google.golang.org/grpc/keepalive"
+	"google.golang.org/grpc/status"
+	"log"
+	"os"
+	"sync/atomic"
+	"time"
+	"tinkoff-invest-contest/internal/client/investapi"
+	"tinkoff-invest-contest/internal/utils"
+)
+
+// ErrUnavailable is matched (with errors.Is) by errors of calls that failed because Invest API is unreachable.
+// Such calls are safe to retry later
+var ErrUnavailable = errors.New("invest API is unavailable")
+
+type UnavailableError struct {
+	State connectivity.State
+	Cause error
+}
+
+func (e *UnavailableError) Error() string {
+	if e.Cause != nil {
+		return fmt.Sprintf("%v (connection %v): %v", ErrUnavailable, e.State, e.Cause)
+	}
+	return fmt.Sprintf("%v (connection %v)", ErrUnavailable, e.State)
+}
+
+func (e *UnavailableError) Is(target error) bool {
+	return target == ErrUnavailable
+}
+
+func (e *UnavailableError) Unwrap() error {
+	return e.Cause
+}
+
+// GRPCStatus lets status.Code recognize the error as UNAVAILABLE
+func (e *UnavailableError) GRPCStatus() *status.Status {
+	if s, ok := status.FromError(e.Cause); ok && e.Cause != nil {
+		return s
+	}
+	return status.New(codes.Unavailable, e.Error())
+}
+
+// IsUnavailable reports whether err means that Invest API couldn't be reached
+func IsUnavailable(err error) bool {
+	return errors.Is(err, ErrUnavailable)
+}
+
+const (
+	// ProbeState judges availability by gRPC connection state only
+	ProbeState = "state"
+	// ProbeRPC additionally makes a cheap authorized call to Invest API every probe interval
+	ProbeRPC = "rpc"
+)
+
+type ConnectionConfig struct {
+	Probe         string
+	ProbeInterval time.Duration
+	// How long a call may wait for a connection that is being established before failing
+	ConnectTimeout time.Duration
+	// Keepalive pings period and timeout
+	KeepaliveTime    time.Duration
+	KeepaliveTimeout time.Duration
+}
+
+// GetConnectionConfig reads connection settings from environment variables
+// ('CONNECTION_PROBE', 'CONNECTION_PROBE_INTERVAL', 'CONNECTION_CONNECT_TIMEOUT',
+// 'CONNECTION_KEEPALIVE_TIME', 'CONNECTION_KEEPALIVE_TIMEOUT'), falling back to defaults
+func GetConnectionConfig() ConnectionConfig {
+	config := ConnectionConfig{
+		Probe:            ProbeState,
+		ProbeInterval:    30 * time.Second,
+		ConnectTimeout:   5 * time.Second,
+		KeepaliveTime:    30 * time.Second,
+		KeepaliveTimeout: 10 * time.Second,
+	}
+	if probe := os.Getenv("CONNECTION_PROBE"); probe != "" {
+		if probe != ProbeState && probe != ProbeRPC {
+			log.Printf("unknown connection probe %q, using %q", probe, config.Probe)
+		} else {
+			config.Probe = probe
+		}
+	}
+	utils.EnvDuration("CONNECTION_PROBE_INTERVAL", &config.ProbeInterval)
+	utils.EnvDuration("CONNECTION_CONNECT_TIMEOUT", &config.ConnectTimeout)
+	utils.EnvDuration("CONNECTION_KEEPALIVE_TIME", &config.KeepaliveTime)
+	utils.EnvDuration("CONNECTION_KEEPALIVE_TIMEOUT", &config.KeepaliveTimeout)
+	return config
+}
+
+func (config ConnectionConfig) dialOptions() []grpc.DialOption {
+	return []grpc.DialOption{
+		grpc.WithKeepaliveParams(keepalive.ClientParameters{
+			Time:                config.KeepaliveTime,
+			Timeout:             config.KeepaliveTimeout,
+			PermitWithoutStream: true,
+		}),
+	}
+}
+
+// ensureAvailable fails fast with UnavailableError if the connection is broken,
+// and waits at most ConnectTimeout for a connection that is being established
+func (c *Client) ensureAvailable() error {
+	if atomic.LoadInt32(&c.probeFailed) == 1 {
+		return &UnavailableError{State: c.conn.GetState(), Cause: errors.New("connection probe failed")}
+	}
+	state := c.conn.GetState()
+	switch state {
+	case connectivity.Ready:
+		return nil
+	case connectivity.TransientFailure, connectivity.Shutdown:
+		return &UnavailableError{State: state}
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), c.connConfig.ConnectTimeout)
+	defer cancel()
+	c.conn.Connect()
+	for state != connectivity.Ready {
+		if state == connectivity.TransientFailure || state == connectivity.Shutdown {
+			return &UnavailableError{State: state}
+		}
+		if !c.conn.WaitForStateChange(ctx, state) {
+			return &UnavailableError{State: state, Cause: ctx.Err()}
+		}
+		state = c.conn.GetState()
+	}
+	return nil
+}
+
+// WaitUntilAvailable blocks until the connection is ready or ctx is done
+func (c *Client) WaitUntilAvailable(ctx context.Context) error {
+	logged := false
+	for {
+		err := c.ensureAvailable()
+		if err == nil {
+			if logged {
+				log.Println("Invest API connection established")
+			}
+			return nil
+		}
+		if !logged {
+			log.Println("waiting for Invest API connection...", err)
+			logged = true
+		}
+		state := c.conn.GetState()
+		waitCtx, cancel := context.WithTimeout(ctx, c.connConfig.ProbeInterval)
+		c.conn.Connect()
+		c.conn.WaitForStateChange(waitCtx, state)
+		cancel()
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+	}
+}
+
+// monitorConnection logs connection state transitions and runs an active probe, if configured
+func (c *Client) monitorConnection() {
+	go func() {
+		state := c.conn.GetState()
+		for c.conn.WaitForStateChange(context.Background(), state) {
+			newState := c.conn.GetState()
+			if newState == connectivity.TransientFailure || state == connectivity.TransientFailure {
+				log.Printf("Invest API connection: %v -> %v", state, newState)
+			}
+			state = newState
+		}
+	}()
+	if c.connConfig.Probe != ProbeRPC {
+		return
+	}
+	go func() {
+		for {
+			ctx, cancel := context.WithTimeout(newContextWithBearerToken(c.token), c.connConfig.ConnectTimeout)
+			_, err := c.UsersService.GetInfo(ctx, &investapi.GetInfoRequest{})
+			cancel()
+			failed := int32(0)
+			if code := status.Code(err); code == codes.Unavailable || code == codes.DeadlineExceeded {
+				failed = 1
+			}
+			if atomic.SwapInt32(&c.probeFailed, failed) != failed {
+				if failed == 1 {
+					log.Println("Invest API connection probe failed:", err)
+				} else {
+					log.Println("Invest API connection probe succeeded")
+				}
+			}
+			time.Sleep(c.connConfig.ProbeInterval)
+		}
+	}()
+}
@@ -0,0 +1,36 @@
+package client
+
+import (
+	"context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"strings"
+	"time"
+	"tinkoff-invest-contest/internal/metrics"
+)
+
+// shortMethodName turns "/tinkoff.public.invest.api.contract.v1.UsersService/GetInfo" into "UsersService/GetInfo"
+func shortMethodName(method string) string {
+	return method[strings.LastIndex(method, ".")+1:]
+}
+
+func metricsInterceptor(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn,
+	invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+	start := time.Now()
+	err := invoker(ctx, method, req, reply, cc, opts...)
+	name := shortMethodName(method)
+	metrics.GRPCCallDuration.WithLabelValues(name).Observe(time.Since(start).Seconds())
+	metrics.GRPCCalls.WithLabelValues(name, status.Code(err).String()).Inc()
+	return err
+}
+
+// unavailableInterceptor turns UNAVAILABLE status into UnavailableError
+func unavailableInterceptor(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn,
+	invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+	err := invoker(ctx, method, req, reply, cc, opts...)
+	if err != nil && status.Code(err) == codes.Unavailable {
+		return &UnavailableError{State: cc.GetState(), Cause: err}
+	}
+	return err
+}
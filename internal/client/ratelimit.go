@@ -0,0 +1,116 @@
+package client
+
+import (
+	"context"
+	"google.golang.org/grpc/metadata"
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// rateLimiter follows Invest API per-service limits reported via
+// 'x-ratelimit-remaining' and 'x-ratelimit-reset' (seconds until the limit resets) response headers
+type rateLimiter struct {
+	mu       sync.Mutex
+	services map[string]*rateLimitState
+}
+
+type rateLimitState struct {
+	remaining int
+	resetAt   time.Time
+}
+
+func newRateLimiter() *rateLimiter {
+	return &rateLimiter{
+		services: make(map[string]*rateLimitState),
+	}
+}
+
+// serviceName turns "/tinkoff.public.invest.api.contract.v1.UsersService/GetInfo" into "UsersService"
+func serviceName(method string) string {
+	name := shortMethodName(method)
+	return name[:strings.Index(name, "/")]
+}
+
+// wait blocks until the service limit is reset, if it is exhausted
+func (l *rateLimiter) wait(ctx context.Context, service string) error {
+	l.mu.Lock()
+	state, ok := l.services[service]
+	var delay time.Duration
+	if ok && state.remaining <= 0 {
+		delay = time.Until(state.resetAt)
+	}
+	l.mu.Unlock()
+	if delay <= 0 {
+		return nil
+	}
+	log.Printf("%v rate limit is exhausted, waiting %v", service, delay.Round(time.Millisecond))
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// update records the limit state from response headers (or trailers)
+func (l *rateLimiter) update(service string, mds ...metadata.MD) {
+	remaining, okRemaining := firstInt(mds, "x-ratelimit-remaining")
+	reset, okReset := firstInt(mds, "x-ratelimit-reset")
+	if !okRemaining && !okReset {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	state, ok := l.services[service]
+	if !ok {
+		state = &rateLimitState{remaining: 1}
+		l.services[service] = state
+	}
+	if okRemaining {
+		state.remaining = remaining
+	}
+	if okReset {
+		state.resetAt = time.Now().Add(time.Duration(reset) * time.Second)
+	}
+}
+
+// exhaust marks the service limit as exhausted, e.g. after RESOURCE_EXHAUSTED status
+func (l *rateLimiter) exhaust(service string, fallbackReset time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	state, ok := l.services[service]
+	if !ok {
+		state = &rateLimitState{}
+		l.services[service] = state
+	}
+	state.remaining = 0
+	if time.Until(state.resetAt) <= 0 {
+		state.resetAt = time.Now().Add(fallbackReset)
+	}
+}
+
+func firstInt(mds []metadata.MD, key string) (int, bool) {
+	for _, md := range mds {
+		if values := md.Get(key); len(values) > 0 {
+			n, err := strconv.Atoi(values[0])
+			if err == nil {
+				return n, true
+			}
+		}
+	}
+	return 0, false
+}
+
+func firstString(mds []metadata.MD, key string) string {
+	for _, md := range mds {
+		if values := md.Get(key); len(values) > 0 {
+			return values[0]
+		}
+	}
+	return ""
+}
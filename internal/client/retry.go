@@ -0,0 +1,142 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+	"tinkoff-invest-contest/internal/utils"
+)
+
+// Error is returned by Client methods when Invest API call fails.
+// TrackingId is the value of 'x-tracking-id' header, which the support asks for
+type Error struct {
+	Method     string
+	TrackingId string
+	Err        error
+}
+
+func (e *Error) Error() string {
+	if e.TrackingId == "" {
+		return fmt.Sprintf("%v: %v", e.Method, e.Err)
+	}
+	return fmt.Sprintf("%v: %v (tracking id: %v)", e.Method, e.Err, e.TrackingId)
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+func (e *Error) GRPCStatus() *status.Status {
+	s, _ := status.FromError(e.Err)
+	return s
+}
+
+type CallConfig struct {
+	// Deadline of a single attempt
+	Timeout     time.Duration
+	MaxAttempts int
+	Backoff     Backoff
+	// How long to wait on RESOURCE_EXHAUSTED if the API didn't tell when the limit resets
+	RateLimitFallbackReset time.Duration
+}
+
+// GetCallConfig reads call settings from environment variables
+// ('CALL_TIMEOUT', 'CALL_MAX_ATTEMPTS', 'CALL_BACKOFF_BASE', 'CALL_BACKOFF_MAX'), falling back to defaults
+func GetCallConfig() CallConfig {
+	config := CallConfig{
+		Timeout:     15 * time.Second,
+		MaxAttempts: 4,
+		Backoff: Backoff{
+			Base: 200 * time.Millisecond,
+			Max:  10 * time.Second,
+		},
+		RateLimitFallbackReset: 5 * time.Second,
+	}
+	utils.EnvDuration("CALL_TIMEOUT", &config.Timeout)
+	utils.EnvDuration("CALL_BACKOFF_BASE", &config.Backoff.Base)
+	utils.EnvDuration("CALL_BACKOFF_MAX", &config.Backoff.Max)
+	if s := os.Getenv("CALL_MAX_ATTEMPTS"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 1 {
+			log.Printf("invalid CALL_MAX_ATTEMPTS %q, using %v", s, config.MaxAttempts)
+		} else {
+			config.MaxAttempts = n
+		}
+	}
+	return config
+}
+
+// isIdempotent reports whether a method can be repeated after a failure with unknown outcome.
+// Order posting is included, since its order id is an idempotency key
+func isIdempotent(method string) bool {
+	name := method[strings.LastIndex(method, "/")+1:]
+	switch name {
+	case "PostOrder", "PostSandboxOrder",
+		"Shares", "Bonds", "Etfs", "Futures", "Currencies", "TradingSchedules":
+		return true
+	}
+	return strings.HasPrefix(name, "Get") || strings.HasSuffix(name, "By")
+}
+
+func isRetryable(method string, code codes.Code) bool {
+	switch code {
+	case codes.ResourceExhausted:
+		// The request was rejected without being processed
+		return true
+	case codes.Unavailable, codes.DeadlineExceeded:
+		return isIdempotent(method)
+	}
+	return false
+}
+
+// callInterceptor applies per-attempt deadlines, rate limits, retries with backoff,
+// and attaches the tracking id to errors
+func (c *Client) callInterceptor(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn,
+	invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+	service := serviceName(method)
+	var err error
+	var trackingId string
+	for attempt := 0; attempt < c.callConfig.MaxAttempts; attempt++ {
+		if attempt > 0 {
+			delay := c.callConfig.Backoff.Duration(attempt - 1)
+			select {
+			case <-time.After(delay):
+			case <-ctx.Done():
+				return &Error{Method: shortMethodName(method), TrackingId: trackingId, Err: ctx.Err()}
+			}
+		}
+		if waitErr := c.rateLimiter.wait(ctx, service); waitErr != nil {
+			return &Error{Method: shortMethodName(method), TrackingId: trackingId, Err: waitErr}
+		}
+
+		var header, trailer metadata.MD
+		attemptCtx, cancel := context.WithTimeout(ctx, c.callConfig.Timeout)
+		err = invoker(attemptCtx, method, req, reply, cc, append(opts, grpc.Header(&header), grpc.Trailer(&trailer))...)
+		cancel()
+		c.rateLimiter.update(service, header, trailer)
+		trackingId = firstString([]metadata.MD{header, trailer}, "x-tracking-id")
+		if err == nil {
+			return nil
+		}
+
+		code := status.Code(err)
+		if code == codes.ResourceExhausted {
+			c.rateLimiter.exhaust(service, c.callConfig.RateLimitFallbackReset)
+		}
+		if !isRetryable(method, code) || ctx.Err() != nil {
+			break
+		}
+		if attempt+1 < c.callConfig.MaxAttempts {
+			log.Printf("%v failed with %v (tracking id: %v), retrying...", shortMethodName(method), code, trackingId)
+		}
+	}
+	return &Error{Method: shortMethodName(method), TrackingId: trackingId, Err: err}
+}
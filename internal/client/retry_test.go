@@ -0,0 +1,66 @@
+package client
+
+import (
+	"google.golang.org/grpc/codes"
+	"testing"
+)
+
+func Test_isRetryable(t *testing.T) {
+	type args struct {
+		method string
+		code   codes.Code
+	}
+	tests := []struct {
+		name string
+		args args
+		want bool
+	}{
+		{
+			name: "test1",
+			args: args{
+				method: "/tinkoff.public.invest.api.contract.v1.MarketDataService/GetCandles",
+				code:   codes.Unavailable,
+			},
+			want: true,
+		},
+		{
+			name: "test2",
+			args: args{
+				method: "/tinkoff.public.invest.api.contract.v1.SandboxService/SandboxPayIn",
+				code:   codes.Unavailable,
+			},
+			want: false,
+		},
+		{
+			name: "test3",
+			args: args{
+				method: "/tinkoff.public.invest.api.contract.v1.SandboxService/OpenSandboxAccount",
+				code:   codes.ResourceExhausted,
+			},
+			want: true,
+		},
+		{
+			name: "test4",
+			args: args{
+				method: "/tinkoff.public.invest.api.contract.v1.OrdersService/PostOrder",
+				code:   codes.DeadlineExceeded,
+			},
+			want: true,
+		},
+		{
+			name: "test5",
+			args: args{
+				method: "/tinkoff.public.invest.api.contract.v1.InstrumentsService/ShareBy",
+				code:   codes.InvalidArgument,
+			},
+			want: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isRetryable(tt.args.method, tt.args.code); got != tt.want {
+				t.Errorf("isRetryable() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,219 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sync/atomic"
+	"time"
+	"tinkoff-invest-contest/internal/client/investapi"
+	"tinkoff-invest-contest/internal/metrics"
+	"tinkoff-invest-contest/internal/utils"
+)
+
+type StreamState int
+
+const (
+	StreamConnected StreamState = iota
+	StreamDisconnected
+)
+
+func StreamStateToString(state StreamState) string {
+	switch state {
+	case StreamConnected:
+		return "connected"
+	case StreamDisconnected:
+		return "disconnected"
+	}
+	return ""
+}
+
+// InitMarketDataStream (re)opens a market data stream, closing the previous one.
+// Subscriptions of the previous stream are not carried over
+func (c *Client) InitMarketDataStream() error {
+	if err := c.ensureAvailable(); err != nil {
+		return err
+	}
+	ctx, cancel := context.WithCancel(newContextWithBearerToken(c.token))
+	stream, err := c.MarketDataStreamService.MarketDataStream(ctx)
+	if err != nil {
+		cancel()
+		return err
+	}
+	c.marketDataStreamMu.Lock()
+	if c.closeMarketDataStream != nil {
+		c.closeMarketDataStream()
+	}
+	c.marketDataStream, c.closeMarketDataStream = stream, cancel
+	c.marketDataStreamMu.Unlock()
+	return nil
+}
+
+func (c *Client) sendMarketDataRequest(req *investapi.MarketDataRequest) error {
+	if err := c.ensureAvailable(); err != nil {
+		return err
+	}
+	c.marketDataStreamMu.Lock()
+	defer c.marketDataStreamMu.Unlock()
+	if c.marketDataStream == nil {
+		return &UnavailableError{State: c.conn.GetState(), Cause: errors.New("market data stream is not open")}
+	}
+	return c.marketDataStream.Send(req)
+}
+
+func (c *Client) getMarketDataStream() investapi.MarketDataStreamService_MarketDataStreamClient {
+	c.marketDataStreamMu.Lock()
+	defer c.marketDataStreamMu.Unlock()
+	return c.marketDataStream
+}
+
+// RunMarketDataStreamLoop receives market data stream messages and passes them to handleResponse in order.
+// When the stream breaks, it is reopened with backoff and resubscribe is called to replay subscriptions.
+// handleState is notified when the stream goes down and when it is back. The loop returns once ctx is done
+func (c *Client) RunMarketDataStreamLoop(ctx context.Context, handleResponse func(marketDataResp *investapi.MarketDataResponse),
+	resubscribe func() error, handleState func(state StreamState)) {
+	go func() {
+		<-ctx.Done()
+		c.marketDataStreamMu.Lock()
+		if c.closeMarketDataStream != nil {
+			c.closeMarketDataStream()
+		}
+		c.marketDataStreamMu.Unlock()
+	}()
+	_ = c.WaitUntilAvailable(ctx)
+	for ctx.Err() == nil {
+		stream := c.getMarketDataStream()
+		if stream != nil {
+			resp, err := stream.Recv()
+			if err == nil {
+				atomic.StoreInt64(&c.lastMarketDataMessageTS, time.Now().UnixNano())
+				handleResponse(resp)
+				continue
+			}
+			if ctx.Err() != nil {
+				return
+			}
+			log.Println("error: market data stream has collapsed:", err)
+		}
+		handleState(StreamDisconnected)
+		if c.reopenMarketDataStream(ctx, resubscribe) != nil {
+			return
+		}
+		log.Println("market data stream has been reopened")
+		handleState(StreamConnected)
+	}
+}
+
+// reopenMarketDataStream blocks until the stream is reopened and all subscriptions are replayed, or ctx is done
+func (c *Client) reopenMarketDataStream(ctx context.Context, resubscribe func() error) error {
+	for attempt := 0; ; attempt++ {
+		if err := utils.Sleep(ctx, c.streamBackoff.Duration(attempt)); err != nil {
+			return err
+		}
+		if err := c.WaitUntilAvailable(ctx); err != nil {
+			return err
+		}
+		metrics.StreamReconnects.WithLabelValues("market_data").Inc()
+		err := c.InitMarketDataStream()
+		if err == nil {
+			err = resubscribe()
+		}
+		if err == nil {
+			return nil
+		}
+		log.Println("error: can't reopen market data stream, retrying...", err)
+	}
+}
+
+// InitTradesStream (re)opens a trades stream for the given accounts, closing the previous one
+func (c *Client) InitTradesStream(accountIds []string) error {
+	c.tradesStreamMu.Lock()
+	c.tradesStreamAccounts = accountIds
+	c.tradesStreamMu.Unlock()
+	return c.reinitTradesStream()
+}
+
+func (c *Client) reinitTradesStream() error {
+	if err := c.ensureAvailable(); err != nil {
+		return err
+	}
+	c.tradesStreamMu.Lock()
+	accountIds := c.tradesStreamAccounts
+	c.tradesStreamMu.Unlock()
+	ctx, cancel := context.WithCancel(newContextWithBearerToken(c.token))
+	stream, err := c.OrdersStreamService.TradesStream(ctx, &investapi.TradesStreamRequest{Accounts: accountIds})
+	if err != nil {
+		cancel()
+		return err
+	}
+	c.tradesStreamMu.Lock()
+	if c.closeTradesStream != nil {
+		c.closeTradesStream()
+	}
+	c.tradesStream, c.closeTradesStream = stream, cancel
+	c.tradesStreamMu.Unlock()
+	atomic.StoreInt32(&c.tradesStreamAlive, 1)
+	return nil
+}
+
+func (c *Client) getTradesStream() investapi.OrdersStreamService_TradesStreamClient {
+	c.tradesStreamMu.Lock()
+	defer c.tradesStreamMu.Unlock()
+	return c.tradesStream
+}
+
+// RunTradesStreamLoop receives trades stream messages and passes them to handleResponse in order.
+// When the stream breaks, it is reopened with backoff. handleState is notified when the stream goes down
+// and when it is back, so that the fills that happened in between can be reconciled. The loop returns once ctx is done
+func (c *Client) RunTradesStreamLoop(ctx context.Context, handleResponse func(tradesResp *investapi.TradesStreamResponse),
+	handleState func(state StreamState)) {
+	go func() {
+		<-ctx.Done()
+		c.tradesStreamMu.Lock()
+		if c.closeTradesStream != nil {
+			c.closeTradesStream()
+		}
+		c.tradesStreamMu.Unlock()
+	}()
+	_ = c.WaitUntilAvailable(ctx)
+	for ctx.Err() == nil {
+		stream := c.getTradesStream()
+		if stream != nil {
+			resp, err := stream.Recv()
+			if err == nil {
+				atomic.StoreInt64(&c.lastTradesMessageTS, time.Now().UnixNano())
+				handleResponse(resp)
+				continue
+			}
+			if ctx.Err() != nil {
+				return
+			}
+			log.Println("error: trades stream has collapsed:", err)
+		}
+		atomic.StoreInt32(&c.tradesStreamAlive, 0)
+		handleState(StreamDisconnected)
+		if c.reopenTradesStream(ctx) != nil {
+			return
+		}
+		log.Println("trades stream has been reopened")
+		handleState(StreamConnected)
+	}
+}
+
+// reopenTradesStream blocks until the stream is reopened, or ctx is done
+func (c *Client) reopenTradesStream(ctx context.Context) error {
+	for attempt := 0; ; attempt++ {
+		if err := utils.Sleep(ctx, c.streamBackoff.Duration(attempt)); err != nil {
+			return err
+		}
+		if err := c.WaitUntilAvailable(ctx); err != nil {
+			return err
+		}
+		metrics.StreamReconnects.WithLabelValues("trades").Inc()
+		err := c.reinitTradesStream()
+		if err == nil {
+			return nil
+		}
+		log.Println("error: can't reopen trades stream, retrying...", err)
+	}
+}
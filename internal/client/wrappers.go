@@ -44,6 +44,16 @@ func (c *Client) WrapPostOrder(isSandbox bool, figi string, quantity int64, pric
 	return order, nil
 }
 
+func (c *Client) WrapCancelOrder(isSandbox bool, accountId string, orderId string) error {
+	var err error
+	if isSandbox {
+		_, err = c.CancelSandboxOrder(accountId, orderId)
+	} else {
+		_, err = c.CancelOrder(accountId, orderId)
+	}
+	return err
+}
+
 func (c *Client) WrapGetOrderState(isSandbox bool, accountId string, orderId string) (*investapi.OrderState, error) {
 	var state *investapi.OrderState
 	var err error
@@ -61,6 +71,20 @@ func (c *Client) WrapGetOrderState(isSandbox bool, accountId string, orderId str
 	return state, nil
 }
 
+func (c *Client) WrapGetOrders(isSandbox bool, accountId string) ([]*investapi.OrderState, error) {
+	var orders []*investapi.OrderState
+	var err error
+	if isSandbox {
+		orders, err = c.GetSandboxOrders(accountId)
+	} else {
+		orders, err = c.GetOrders(accountId)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return orders, nil
+}
+
 func (c *Client) WrapGetPortfolio(isSandbox bool, accountId string) (*investapi.PortfolioResponse, error) {
 	var portfolio *investapi.PortfolioResponse
 	var err error
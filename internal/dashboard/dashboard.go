@@ -2,29 +2,39 @@ package dashboard
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	grafana "github.com/grafana/grafana-api-golang-client"
 	"log"
+	"net/http"
 	"os"
 	"strconv"
 	"strings"
+	"time"
 	"tinkoff-invest-contest/internal/client/investapi"
+	"tinkoff-invest-contest/internal/health"
 	"tinkoff-invest-contest/internal/utils"
 )
 
+const grafanaURL = "http://grafana:3000"
+
 var client *grafana.Client
 var botsFolder grafana.Folder
 var botDashboardTemplate []byte
 var botDashboards map[int]int64
 
 func init() {
+	health.RegisterReadiness("grafana", checkGrafanaHealth)
+
 	var err error
-	client, err = grafana.New("http://grafana:3000", grafana.Config{
+	client, err = grafana.New(grafanaURL, grafana.Config{
 		APIKey:     utils.GetGrafanaToken(),
 		NumRetries: 1,
 	})
 	if err != nil {
-		log.Fatalf("error creating Grafana API client: %v", err)
+		log.Printf("error creating Grafana API client: %v", err)
+		client = nil
+		return
 	}
 
 	folders, _ := client.Folders()
@@ -33,8 +43,15 @@ func init() {
 	}
 	botsFolder, _ = client.NewFolder("Bots")
 
-	addUtilityDashboard("internal/dashboard/templates/manage_bots.json")
-	addUtilityDashboard("internal/dashboard/templates/manage_accounts.json")
+	for _, templatePath := range []string{
+		"internal/dashboard/templates/manage_bots.json",
+		"internal/dashboard/templates/manage_accounts.json",
+	} {
+		err = addUtilityDashboard(templatePath)
+		if err != nil {
+			log.Printf("error adding Grafana dashboard %v: %v", templatePath, err)
+		}
+	}
 
 	_, err = client.NewDataSource(&grafana.DataSource{
 		Type:      "influxdb",
@@ -61,7 +78,23 @@ func init() {
 	botDashboards = make(map[int]int64)
 }
 
-func addUtilityDashboard(templatePath string) {
+func checkGrafanaHealth() error {
+	if !IsGrafanaInitialized() {
+		return errors.New("Grafana API client was not initialized")
+	}
+	httpClient := http.Client{Timeout: 5 * time.Second}
+	resp, err := httpClient.Get(grafanaURL + "/api/health")
+	if err != nil {
+		return err
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("Grafana health endpoint responded with %v", resp.Status)
+	}
+	return nil
+}
+
+func addUtilityDashboard(templatePath string) error {
 	template, _ := os.ReadFile(templatePath)
 	modelStr := string(template)
 	modelStr = strings.ReplaceAll(modelStr, "<host>", os.Getenv("HOST"))
@@ -74,7 +107,7 @@ func addUtilityDashboard(templatePath string) {
 		Overwrite: true,
 	}
 	_, err := client.NewDashboard(dashboard)
-	utils.MaybeCrash(err)
+	return err
 }
 
 func IsGrafanaInitialized() bool {
@@ -85,9 +118,9 @@ func IsGrafanaInitialized() bool {
 	return true
 }
 
-func AddBotDashboard(botId int, botName string) {
+func AddBotDashboard(botId int, botName string) error {
 	if !IsGrafanaInitialized() {
-		return
+		return nil
 	}
 	modelStr := string(botDashboardTemplate)
 	modelStr = strings.ReplaceAll(modelStr, "<bot_id>", fmt.Sprint(botId))
@@ -102,8 +135,11 @@ func AddBotDashboard(botId int, botName string) {
 		Overwrite: true,
 	}
 	resp, err := client.NewDashboard(dashboard)
-	utils.MaybeCrash(err)
+	if err != nil {
+		return err
+	}
 	botDashboards[botId] = resp.ID
+	return nil
 }
 
 func RemoveBotDashboards() {
@@ -2,19 +2,33 @@ package db
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	influxdb2 "github.com/influxdata/influxdb-client-go/v2"
 	"github.com/influxdata/influxdb-client-go/v2/api"
 	"github.com/influxdata/influxdb-client-go/v2/api/write"
 	"log"
+	"sync"
 	"time"
 	"tinkoff-invest-contest/internal/client/investapi"
+	"tinkoff-invest-contest/internal/execution"
+	"tinkoff-invest-contest/internal/health"
+	"tinkoff-invest-contest/internal/tape"
 	"tinkoff-invest-contest/internal/utils"
 )
 
 //var queryAPI api.QueryAPI
 var writeAPI api.WriteAPI
 
+var lastWriteError = struct {
+	mu  sync.Mutex
+	err error
+	ts  time.Time
+}{}
+
+// Writes are considered broken if an error occurred within this period
+const writeErrorTTL = time.Minute
+
 func init() {
 	url := "http://influxdb:8086"
 	token := utils.GetInfluxDBToken()
@@ -24,11 +38,37 @@ func init() {
 	client := influxdb2.NewClient(url, token)
 	//queryAPI = client.QueryAPI(org)
 	writeAPI = client.WriteAPI(org, bucket)
+	go collectWriteErrors(writeAPI.Errors())
 
 	err := client.DeleteAPI().DeleteWithName(context.Background(), org, bucket, time.Unix(0, 0), time.Now(), "")
 	if err != nil {
 		log.Fatalf("error: cannot empty the InfluxDB bucket (%v)", err.Error())
 	}
+
+	health.RegisterReadiness("influxdb", func() error {
+		ok, err := client.Ping(context.Background())
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return errors.New("InfluxDB is not reachable")
+		}
+		lastWriteError.mu.Lock()
+		defer lastWriteError.mu.Unlock()
+		if lastWriteError.err != nil && time.Since(lastWriteError.ts) < writeErrorTTL {
+			return fmt.Errorf("write failed %v ago: %v", time.Since(lastWriteError.ts).Round(time.Second), lastWriteError.err)
+		}
+		return nil
+	})
+}
+
+func collectWriteErrors(errs <-chan error) {
+	for err := range errs {
+		log.Printf("error: InfluxDB write failed (%v)", err)
+		lastWriteError.mu.Lock()
+		lastWriteError.err, lastWriteError.ts = err, time.Now()
+		lastWriteError.mu.Unlock()
+	}
 }
 
 func WriteStrategyOutput(botId int, strategyOutput map[string]any, ts time.Time) {
@@ -40,6 +80,30 @@ func WriteStrategyOutput(botId int, strategyOutput map[string]any, ts time.Time)
 	))
 }
 
+func WriteExecutionQuality(botId int, fill execution.FillQuality) {
+	writeAPI.WritePoint(write.NewPoint(
+		fmt.Sprintf("bot_%v_execution", botId),
+		map[string]string{
+			"figi":       fill.Figi,
+			"order_type": fill.OrderType,
+			"algorithm":  fill.Algorithm,
+		},
+		map[string]any{
+			"signal_price":          fill.SignalPrice,
+			"mid_price":             fill.MidPrice,
+			"avg_price":             fill.AvgPrice,
+			"signal_slippage_ticks": fill.SignalSlippageTicks,
+			"signal_slippage_bps":   fill.SignalSlippageBps,
+			"mid_slippage_ticks":    fill.MidSlippageTicks,
+			"mid_slippage_bps":      fill.MidSlippageBps,
+			"signal_to_fill_ms":     fill.SignalToFill.Milliseconds(),
+			"fill_ratio":            fill.FillRatio,
+			"lots_executed":         fill.LotsExecuted,
+		},
+		fill.Time,
+	))
+}
+
 func WriteHistoricCandles(botId int, candles []*investapi.HistoricCandle) {
 	for _, candle := range candles {
 		writeAPI.WritePoint(write.NewPoint(
@@ -60,6 +124,20 @@ func WriteLastCandle(botId int, candle *investapi.Candle) {
 	))
 }
 
+// WriteTradeFlow writes the lots bought and sold by aggressors within the candle starting at flow.Start
+func WriteTradeFlow(botId int, flow tape.Flow) {
+	writeAPI.WritePoint(write.NewPoint(
+		fmt.Sprintf("bot_%v_trade_flow", botId),
+		map[string]string{},
+		map[string]any{
+			"buy_volume":  flow.Buy,
+			"sell_volume": flow.Sell,
+			"delta":       flow.Delta(),
+		},
+		flow.Start,
+	))
+}
+
 func marshalHistoricCandle(candle *investapi.HistoricCandle) map[string]any {
 	return map[string]any{
 		"open":   utils.QuotationToFloat(candle.Open),
@@ -0,0 +1,331 @@
+package execution
+
+import (
+	"context"
+	"errors"
+	"time"
+	"tinkoff-invest-contest/internal/client/investapi"
+	"tinkoff-invest-contest/internal/utils"
+)
+
+// Algorithm tells how a parent order is split into child orders
+type Algorithm int
+
+const (
+	// AlgorithmDirect sends the parent order as is
+	AlgorithmDirect Algorithm = iota
+	// AlgorithmTWAP splits the order into equal child orders of the parent's type spread evenly over time
+	AlgorithmTWAP
+	// AlgorithmIceberg sends limit orders at the best opposite price, each bounded by a share of the quantity at that price
+	AlgorithmIceberg
+	// AlgorithmPassiveLimit keeps a limit order at the best price of its own side, re-pricing it periodically
+	AlgorithmPassiveLimit
+)
+
+func StringToAlgorithm(s string) (Algorithm, error) {
+	switch s {
+	case "", "direct":
+		return AlgorithmDirect, nil
+	case "twap":
+		return AlgorithmTWAP, nil
+	case "iceberg":
+		return AlgorithmIceberg, nil
+	case "passive":
+		return AlgorithmPassiveLimit, nil
+	}
+	return 0, errors.New("unknown execution algorithm: " + s)
+}
+
+func AlgorithmToString(algorithm Algorithm) string {
+	switch algorithm {
+	case AlgorithmDirect:
+		return "direct"
+	case AlgorithmTWAP:
+		return "twap"
+	case AlgorithmIceberg:
+		return "iceberg"
+	case AlgorithmPassiveLimit:
+		return "passive"
+	}
+	return ""
+}
+
+type Config struct {
+	Algorithm Algorithm
+	// TWAP: number of child orders and the time they're spread over
+	TWAPSlices   int
+	TWAPDuration time.Duration
+	// Iceberg: max share of the quantity at the best opposite price a child order may take
+	IcebergDepthShare float64
+	// Iceberg and passive limit: how long a child order waits to be filled before it's cancelled
+	RepriceInterval time.Duration
+	// Passive limit: how far the price may go against the order, as a ratio of the signal price
+	MaxSlippage float64
+}
+
+func (config Config) Validate() error {
+	switch config.Algorithm {
+	case AlgorithmTWAP:
+		if config.TWAPSlices < 1 || config.TWAPDuration < 0 {
+			return errors.New("TWAP needs at least one slice and a non-negative duration")
+		}
+	case AlgorithmIceberg:
+		if config.IcebergDepthShare <= 0 || config.IcebergDepthShare > 1 || config.RepriceInterval <= 0 {
+			return errors.New("iceberg needs a depth share in (0, 1] and a positive re-price interval")
+		}
+	case AlgorithmPassiveLimit:
+		if config.MaxSlippage < 0 || config.RepriceInterval <= 0 {
+			return errors.New("passive limit needs a non-negative max slippage and a positive re-price interval")
+		}
+	}
+	return nil
+}
+
+// Venue executes child orders, see tradeenv.TradeEnv
+type Venue interface {
+	DoOrder(ctx context.Context, figi string, quantity int64, price *investapi.Quotation, direction investapi.OrderDirection,
+		accountId string, orderType investapi.OrderType) (avgPositionPrice float64, err error)
+	DoOrderFor(ctx context.Context, timeout time.Duration, figi string, quantity int64, price *investapi.Quotation,
+		direction investapi.OrderDirection, accountId string, orderType investapi.OrderType) (lotsExecuted int64, avgPositionPrice float64, err error)
+}
+
+// Order is a parent order, Price is the signal price
+type Order struct {
+	Figi              string
+	Lots              int64
+	Price             *investapi.Quotation
+	Direction         investapi.OrderDirection
+	AccountId         string
+	Type              investapi.OrderType
+	MinPriceIncrement *investapi.Quotation
+}
+
+// Report is the outcome of a parent order
+type Report struct {
+	Algorithm Algorithm
+	// Type of the child orders
+	OrderType     investapi.OrderType
+	LotsRequested int64
+	// Lots of all child orders, including the ones cancelled unfilled
+	LotsPosted   int64
+	LotsExecuted int64
+	AvgPrice     float64
+	SignalPrice  float64
+	// Relative difference between the average fill price and the signal price, positive when unfavorable
+	Slippage    float64
+	ChildOrders int
+
+	notional float64
+}
+
+func (r *Report) add(lots int64, avgPrice float64) {
+	if lots <= 0 {
+		return
+	}
+	r.LotsExecuted += lots
+	r.notional += float64(lots) * avgPrice
+	r.AvgPrice = r.notional / float64(r.LotsExecuted)
+}
+
+func (r *Report) finish(direction investapi.OrderDirection) {
+	if r.LotsExecuted == 0 || r.SignalPrice == 0 {
+		return
+	}
+	r.Slippage = (r.AvgPrice - r.SignalPrice) / r.SignalPrice
+	if direction == investapi.OrderDirection_ORDER_DIRECTION_SELL {
+		r.Slippage = -r.Slippage
+	}
+}
+
+// Executor executes parent orders with the configured algorithm.
+// orderBook returns the latest order book of the instrument, nil if there's none yet
+type Executor struct {
+	venue     Venue
+	config    Config
+	orderBook func() *investapi.OrderBook
+}
+
+func NewExecutor(venue Venue, config Config, orderBook func() *investapi.OrderBook) *Executor {
+	return &Executor{
+		venue:     venue,
+		config:    config,
+		orderBook: orderBook,
+	}
+}
+
+// SetConfig replaces executor's config, it must not be called while an order is being executed
+func (e *Executor) SetConfig(config Config) {
+	e.config = config
+}
+
+// Execute executes the parent order. The report is valid even if there is an error,
+// since the order could have been executed partially
+func (e *Executor) Execute(ctx context.Context, order Order) (Report, error) {
+	report := Report{
+		Algorithm:     e.config.Algorithm,
+		LotsRequested: order.Lots,
+		SignalPrice:   utils.QuotationToFloat(order.Price),
+	}
+	var err error
+	switch e.config.Algorithm {
+	case AlgorithmTWAP:
+		report.OrderType = order.Type
+		err = e.twap(ctx, order, &report)
+	case AlgorithmIceberg:
+		report.OrderType = investapi.OrderType_ORDER_TYPE_LIMIT
+		err = e.iceberg(ctx, order, &report)
+	case AlgorithmPassiveLimit:
+		report.OrderType = investapi.OrderType_ORDER_TYPE_LIMIT
+		err = e.passiveLimit(ctx, order, &report)
+	default:
+		report.OrderType = order.Type
+		var avgPrice float64
+		avgPrice, err = e.venue.DoOrder(ctx, order.Figi, order.Lots, order.Price, order.Direction, order.AccountId, order.Type)
+		report.ChildOrders++
+		report.LotsPosted += order.Lots
+		if err == nil {
+			report.add(order.Lots, avgPrice)
+		}
+	}
+	report.finish(order.Direction)
+	return report, err
+}
+
+// twap sends child orders of the parent's type. A limit child waits to be filled until the next one is due,
+// its unfilled lots are carried over to the next one, and the last one waits like a direct limit order
+func (e *Executor) twap(ctx context.Context, order Order, report *Report) error {
+	slices := int64(e.config.TWAPSlices)
+	if slices < 1 {
+		slices = 1
+	}
+	if slices > order.Lots {
+		slices = order.Lots
+	}
+	interval := e.config.TWAPDuration / time.Duration(slices)
+	limit := order.Type == investapi.OrderType_ORDER_TYPE_LIMIT
+	var unfilledLots int64
+	for i := int64(0); i < slices; i++ {
+		if i > 0 && !limit {
+			if err := utils.Sleep(ctx, interval); err != nil {
+				return err
+			}
+		}
+		lots := order.Lots / slices
+		if i < order.Lots%slices {
+			lots++
+		}
+		lots += unfilledLots
+		var lotsExecuted int64
+		var avgPrice float64
+		var err error
+		if limit && i < slices-1 {
+			lotsExecuted, avgPrice, err = e.venue.DoOrderFor(ctx, interval, order.Figi, lots, order.Price, order.Direction,
+				order.AccountId, order.Type)
+		} else {
+			avgPrice, err = e.venue.DoOrder(ctx, order.Figi, lots, order.Price, order.Direction, order.AccountId,
+				report.OrderType)
+			if err == nil {
+				lotsExecuted = lots
+			}
+		}
+		report.ChildOrders++
+		report.LotsPosted += lots
+		report.add(lotsExecuted, avgPrice)
+		if err != nil {
+			return err
+		}
+		unfilledLots = lots - lotsExecuted
+	}
+	return nil
+}
+
+func (e *Executor) iceberg(ctx context.Context, order Order, report *Report) error {
+	for report.LotsExecuted < order.Lots {
+		levels := e.orderBookSide(order.Direction, true)
+		if len(levels) == 0 {
+			if err := utils.Sleep(ctx, e.config.RepriceInterval); err != nil {
+				return err
+			}
+			continue
+		}
+		// The slice is priced at the best level, so it's bounded by the quantity there
+		lots := IcebergSliceLots(levels[0].Quantity, e.config.IcebergDepthShare, order.Lots-report.LotsExecuted)
+		lotsExecuted, avgPrice, err := e.venue.DoOrderFor(ctx, e.config.RepriceInterval, order.Figi, lots, levels[0].Price,
+			order.Direction, order.AccountId, investapi.OrderType_ORDER_TYPE_LIMIT)
+		report.ChildOrders++
+		report.LotsPosted += lots
+		report.add(lotsExecuted, avgPrice)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (e *Executor) passiveLimit(ctx context.Context, order Order, report *Report) error {
+	for report.LotsExecuted < order.Lots {
+		levels := e.orderBookSide(order.Direction, false)
+		if len(levels) == 0 {
+			if err := utils.Sleep(ctx, e.config.RepriceInterval); err != nil {
+				return err
+			}
+			continue
+		}
+		price := PassivePrice(levels[0].Price, order.Price, order.Direction, e.config.MaxSlippage, order.MinPriceIncrement)
+		lots := order.Lots - report.LotsExecuted
+		lotsExecuted, avgPrice, err := e.venue.DoOrderFor(ctx, e.config.RepriceInterval, order.Figi, lots,
+			price, order.Direction, order.AccountId, investapi.OrderType_ORDER_TYPE_LIMIT)
+		report.ChildOrders++
+		report.LotsPosted += lots
+		report.add(lotsExecuted, avgPrice)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// orderBookSide returns price levels the order would take liquidity from (opposite side),
+// or the levels it would join otherwise
+func (e *Executor) orderBookSide(direction investapi.OrderDirection, opposite bool) []*investapi.Order {
+	orderBook := e.orderBook()
+	if orderBook == nil {
+		return nil
+	}
+	if (direction == investapi.OrderDirection_ORDER_DIRECTION_BUY) == opposite {
+		return orderBook.Asks
+	}
+	return orderBook.Bids
+}
+
+// IcebergSliceLots returns the size of the next iceberg child order: the given share of the depth at the best price,
+// at least one lot and no more than what's left
+func IcebergSliceLots(depth int64, depthShare float64, remainingLots int64) int64 {
+	lots := int64(float64(depth) * depthShare)
+	if lots < 1 {
+		lots = 1
+	}
+	if lots > remainingLots {
+		lots = remainingLots
+	}
+	return lots
+}
+
+// PassivePrice returns the price of a passive limit order: the best price of its own side,
+// but not further from the signal price than maxSlippage allows
+func PassivePrice(bestPrice *investapi.Quotation, signalPrice *investapi.Quotation, direction investapi.OrderDirection,
+	maxSlippage float64, minPriceIncrement *investapi.Quotation) *investapi.Quotation {
+	price := utils.QuotationToFloat(bestPrice)
+	if direction == investapi.OrderDirection_ORDER_DIRECTION_BUY {
+		limit := utils.QuotationToFloat(signalPrice) * (1 + maxSlippage)
+		if price <= limit {
+			return bestPrice
+		}
+		return utils.RoundQuotation(utils.FloatToQuotation(limit), minPriceIncrement)
+	}
+	limit := utils.QuotationToFloat(signalPrice) * (1 - maxSlippage)
+	if price >= limit {
+		return bestPrice
+	}
+	return utils.RoundQuotation(utils.FloatToQuotation(limit), minPriceIncrement)
+}
@@ -0,0 +1,169 @@
+package execution
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+	"tinkoff-invest-contest/internal/client/investapi"
+	"tinkoff-invest-contest/internal/utils"
+)
+
+// fakeVenue fills child orders at a fixed price and records their sizes and types.
+// Orders waited for a limited time are filled by at most forLots lots, if set, the others in full
+type fakeVenue struct {
+	price   float64
+	forLots int64
+	lots    []int64
+	types   []investapi.OrderType
+}
+
+func (v *fakeVenue) DoOrder(_ context.Context, _ string, quantity int64, _ *investapi.Quotation, _ investapi.OrderDirection,
+	_ string, orderType investapi.OrderType) (float64, error) {
+	v.lots = append(v.lots, quantity)
+	v.types = append(v.types, orderType)
+	return v.price, nil
+}
+
+func (v *fakeVenue) DoOrderFor(_ context.Context, _ time.Duration, _ string, quantity int64, _ *investapi.Quotation,
+	_ investapi.OrderDirection, _ string, orderType investapi.OrderType) (int64, float64, error) {
+	v.lots = append(v.lots, quantity)
+	v.types = append(v.types, orderType)
+	if v.forLots > 0 && quantity > v.forLots {
+		return v.forLots, v.price, nil
+	}
+	return quantity, v.price, nil
+}
+
+func TestExecutor_Execute(t *testing.T) {
+	orderBook := &investapi.OrderBook{
+		Bids: []*investapi.Order{{Price: utils.FloatToQuotation(99.9), Quantity: 5}},
+		Asks: []*investapi.Order{{Price: utils.FloatToQuotation(100.1), Quantity: 4}, {Price: utils.FloatToQuotation(100.2), Quantity: 6}},
+	}
+	tests := []struct {
+		name         string
+		config       Config
+		orderType    investapi.OrderType
+		forLots      int64
+		direction    investapi.OrderDirection
+		lots         int64
+		wantLots     []int64
+		wantTypes    []investapi.OrderType
+		wantSlippage float64
+	}{
+		{
+			name:         "test1",
+			config:       Config{Algorithm: AlgorithmDirect},
+			direction:    investapi.OrderDirection_ORDER_DIRECTION_BUY,
+			lots:         10,
+			wantLots:     []int64{10},
+			wantSlippage: 0.01,
+		},
+		{
+			name:         "test2",
+			config:       Config{Algorithm: AlgorithmTWAP, TWAPSlices: 3},
+			direction:    investapi.OrderDirection_ORDER_DIRECTION_BUY,
+			lots:         10,
+			wantLots:     []int64{4, 3, 3},
+			wantSlippage: 0.01,
+		},
+		{
+			name:         "test3",
+			config:       Config{Algorithm: AlgorithmIceberg, IcebergDepthShare: 0.5, RepriceInterval: time.Second},
+			direction:    investapi.OrderDirection_ORDER_DIRECTION_BUY,
+			lots:         7,
+			wantLots:     []int64{2, 2, 2, 1},
+			wantSlippage: 0.01,
+		},
+		{
+			name:         "test4",
+			config:       Config{Algorithm: AlgorithmPassiveLimit, RepriceInterval: time.Second},
+			direction:    investapi.OrderDirection_ORDER_DIRECTION_SELL,
+			lots:         2,
+			wantLots:     []int64{2},
+			wantSlippage: -0.01,
+		},
+		{
+			name:         "test5",
+			config:       Config{Algorithm: AlgorithmTWAP, TWAPSlices: 3},
+			orderType:    investapi.OrderType_ORDER_TYPE_LIMIT,
+			forLots:      2,
+			direction:    investapi.OrderDirection_ORDER_DIRECTION_BUY,
+			lots:         10,
+			wantLots:     []int64{4, 5, 6},
+			wantTypes:    []investapi.OrderType{investapi.OrderType_ORDER_TYPE_LIMIT, investapi.OrderType_ORDER_TYPE_LIMIT, investapi.OrderType_ORDER_TYPE_LIMIT},
+			wantSlippage: 0.01,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			venue := &fakeVenue{price: 101, forLots: tt.forLots}
+			orderType := tt.orderType
+			if orderType == investapi.OrderType_ORDER_TYPE_UNSPECIFIED {
+				orderType = investapi.OrderType_ORDER_TYPE_MARKET
+			}
+			executor := NewExecutor(venue, tt.config, func() *investapi.OrderBook { return orderBook })
+			report, err := executor.Execute(context.Background(), Order{
+				Lots:              tt.lots,
+				Price:             utils.FloatToQuotation(100),
+				Direction:         tt.direction,
+				Type:              orderType,
+				MinPriceIncrement: utils.FloatToQuotation(0.01),
+			})
+			if err != nil {
+				t.Fatalf("Execute() error = %v", err)
+			}
+			if !reflect.DeepEqual(venue.lots, tt.wantLots) {
+				t.Errorf("Execute() child orders = %v, want %v", venue.lots, tt.wantLots)
+			}
+			if tt.wantTypes != nil && !reflect.DeepEqual(venue.types, tt.wantTypes) {
+				t.Errorf("Execute() child order types = %v, want %v", venue.types, tt.wantTypes)
+			}
+			if report.LotsExecuted != tt.lots || report.AvgPrice != 101 {
+				t.Errorf("Execute() executed %v lots at %v, want %v lots at 101", report.LotsExecuted, report.AvgPrice, tt.lots)
+			}
+			if diff := report.Slippage - tt.wantSlippage; diff > 1e-9 || diff < -1e-9 {
+				t.Errorf("Execute() slippage = %v, want %v", report.Slippage, tt.wantSlippage)
+			}
+		})
+	}
+}
+
+func TestPassivePrice(t *testing.T) {
+	type args struct {
+		bestPrice   float64
+		signalPrice float64
+		direction   investapi.OrderDirection
+		maxSlippage float64
+	}
+	tests := []struct {
+		name string
+		args args
+		want float64
+	}{
+		{
+			name: "test1",
+			args: args{bestPrice: 100.05, signalPrice: 100, direction: investapi.OrderDirection_ORDER_DIRECTION_BUY, maxSlippage: 0.001},
+			want: 100.05,
+		},
+		{
+			name: "test2",
+			args: args{bestPrice: 100.5, signalPrice: 100, direction: investapi.OrderDirection_ORDER_DIRECTION_BUY, maxSlippage: 0.001},
+			want: 100.1,
+		},
+		{
+			name: "test3",
+			args: args{bestPrice: 99.5, signalPrice: 100, direction: investapi.OrderDirection_ORDER_DIRECTION_SELL, maxSlippage: 0.002},
+			want: 99.8,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := PassivePrice(utils.FloatToQuotation(tt.args.bestPrice), utils.FloatToQuotation(tt.args.signalPrice),
+				tt.args.direction, tt.args.maxSlippage, utils.FloatToQuotation(0.01))
+			if utils.QuotationToFloat(got) != tt.want {
+				t.Errorf("PassivePrice() = %v, want %v", utils.QuotationToFloat(got), tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,199 @@
+package execution
+
+import (
+	"sync"
+	"time"
+	"tinkoff-invest-contest/internal/client/investapi"
+	"tinkoff-invest-contest/internal/utils"
+)
+
+// FillQuality describes how well a parent order was executed.
+// Slippage is positive when the fill is worse than the reference price
+type FillQuality struct {
+	Time      time.Time `json:"time"`
+	Bot       string    `json:"bot"`
+	Figi      string    `json:"figi"`
+	OrderType string    `json:"orderType"`
+	Algorithm string    `json:"algorithm"`
+
+	SignalPrice float64 `json:"signalPrice"`
+	MidPrice    float64 `json:"midPrice"`
+	AvgPrice    float64 `json:"avgPrice"`
+
+	SignalSlippageTicks float64 `json:"signalSlippageTicks"`
+	SignalSlippageBps   float64 `json:"signalSlippageBps"`
+	MidSlippageTicks    float64 `json:"midSlippageTicks"`
+	MidSlippageBps      float64 `json:"midSlippageBps"`
+
+	SignalToFill time.Duration `json:"signalToFill"`
+	LotsExecuted int64         `json:"lotsExecuted"`
+	// Executed lots to lots posted in child orders, below 1 when limit orders are cancelled unfilled
+	FillRatio float64 `json:"fillRatio"`
+}
+
+// NewFillQuality measures the report against the signal price and the mid price of the order book
+// at the time of the signal. The order book may be nil
+func NewFillQuality(bot string, figi string, report Report, direction investapi.OrderDirection,
+	orderBook *investapi.OrderBook, minPriceIncrement *investapi.Quotation, signalTime time.Time, fillTime time.Time) FillQuality {
+	fill := FillQuality{
+		Time:         fillTime,
+		Bot:          bot,
+		Figi:         figi,
+		OrderType:    orderTypeToString(report.OrderType),
+		Algorithm:    AlgorithmToString(report.Algorithm),
+		SignalPrice:  report.SignalPrice,
+		MidPrice:     MidPrice(orderBook),
+		AvgPrice:     report.AvgPrice,
+		SignalToFill: fillTime.Sub(signalTime),
+		LotsExecuted: report.LotsExecuted,
+	}
+	if report.LotsPosted > 0 {
+		fill.FillRatio = float64(report.LotsExecuted) / float64(report.LotsPosted)
+	}
+	tick := utils.QuotationToFloat(minPriceIncrement)
+	fill.SignalSlippageTicks, fill.SignalSlippageBps = slippage(fill.AvgPrice, fill.SignalPrice, direction, tick)
+	fill.MidSlippageTicks, fill.MidSlippageBps = slippage(fill.AvgPrice, fill.MidPrice, direction, tick)
+	return fill
+}
+
+// MidPrice returns the middle between the best bid and ask, 0 if either side is empty
+func MidPrice(orderBook *investapi.OrderBook) float64 {
+	if orderBook == nil || len(orderBook.Bids) == 0 || len(orderBook.Asks) == 0 {
+		return 0
+	}
+	return (utils.QuotationToFloat(orderBook.Bids[0].Price) + utils.QuotationToFloat(orderBook.Asks[0].Price)) / 2
+}
+
+func slippage(price float64, reference float64, direction investapi.OrderDirection, tick float64) (ticks float64, bps float64) {
+	if price == 0 || reference == 0 {
+		return 0, 0
+	}
+	diff := price - reference
+	if direction == investapi.OrderDirection_ORDER_DIRECTION_SELL {
+		diff = -diff
+	}
+	if tick > 0 {
+		ticks = diff / tick
+	}
+	return ticks, diff / reference * 10000
+}
+
+func orderTypeToString(orderType investapi.OrderType) string {
+	switch orderType {
+	case investapi.OrderType_ORDER_TYPE_MARKET:
+		return "market"
+	case investapi.OrderType_ORDER_TYPE_LIMIT:
+		return "limit"
+	}
+	return "unspecified"
+}
+
+// QualityStats aggregates fills, slippage is averaged over filled orders only
+// and fill ratio over all limit orders, including the ones cancelled unfilled
+type QualityStats struct {
+	Fills                  int           `json:"fills"`
+	AvgSignalSlippageTicks float64       `json:"avgSignalSlippageTicks"`
+	AvgSignalSlippageBps   float64       `json:"avgSignalSlippageBps"`
+	AvgMidSlippageTicks    float64       `json:"avgMidSlippageTicks"`
+	AvgMidSlippageBps      float64       `json:"avgMidSlippageBps"`
+	AvgSignalToFill        time.Duration `json:"avgSignalToFill"`
+	LimitOrders            int           `json:"limitOrders"`
+	AvgLimitFillRatio      float64       `json:"avgLimitFillRatio"`
+
+	// Fills without an order book don't count in mid slippage
+	midFills int
+}
+
+func (s *QualityStats) add(fill FillQuality) {
+	if fill.OrderType == "limit" {
+		s.LimitOrders++
+		s.AvgLimitFillRatio += (fill.FillRatio - s.AvgLimitFillRatio) / float64(s.LimitOrders)
+	}
+	if fill.LotsExecuted == 0 {
+		return
+	}
+	s.Fills++
+	n := float64(s.Fills)
+	s.AvgSignalSlippageTicks += (fill.SignalSlippageTicks - s.AvgSignalSlippageTicks) / n
+	s.AvgSignalSlippageBps += (fill.SignalSlippageBps - s.AvgSignalSlippageBps) / n
+	s.AvgSignalToFill += (fill.SignalToFill - s.AvgSignalToFill) / time.Duration(s.Fills)
+	if fill.MidPrice > 0 {
+		s.midFills++
+		n = float64(s.midFills)
+		s.AvgMidSlippageTicks += (fill.MidSlippageTicks - s.AvgMidSlippageTicks) / n
+		s.AvgMidSlippageBps += (fill.MidSlippageBps - s.AvgMidSlippageBps) / n
+	}
+}
+
+type QualitySummary struct {
+	ByBot        map[string]*QualityStats `json:"byBot"`
+	ByInstrument map[string]*QualityStats `json:"byInstrument"`
+	ByOrderType  map[string]*QualityStats `json:"byOrderType"`
+	// The most recent fills, the latest goes last
+	Recent []FillQuality `json:"recent"`
+}
+
+// QualityTracker aggregates fills per bot, per instrument and per order type
+type QualityTracker struct {
+	mu           sync.Mutex
+	maxRecent    int
+	byBot        map[string]*QualityStats
+	byInstrument map[string]*QualityStats
+	byOrderType  map[string]*QualityStats
+	recent       []FillQuality
+}
+
+func NewQualityTracker(maxRecent int) *QualityTracker {
+	return &QualityTracker{
+		maxRecent:    maxRecent,
+		byBot:        make(map[string]*QualityStats),
+		byInstrument: make(map[string]*QualityStats),
+		byOrderType:  make(map[string]*QualityStats),
+	}
+}
+
+func (t *QualityTracker) Record(fill FillQuality) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for _, group := range []struct {
+		stats map[string]*QualityStats
+		key   string
+	}{
+		{t.byBot, fill.Bot},
+		{t.byInstrument, fill.Figi},
+		{t.byOrderType, fill.OrderType},
+	} {
+		if _, ok := group.stats[group.key]; !ok {
+			group.stats[group.key] = &QualityStats{}
+		}
+		group.stats[group.key].add(fill)
+	}
+	t.recent = append(t.recent, fill)
+	if len(t.recent) > t.maxRecent {
+		t.recent = t.recent[len(t.recent)-t.maxRecent:]
+	}
+}
+
+// Summary returns a copy of the aggregated stats
+func (t *QualityTracker) Summary() QualitySummary {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return QualitySummary{
+		ByBot:        copyStats(t.byBot),
+		ByInstrument: copyStats(t.byInstrument),
+		ByOrderType:  copyStats(t.byOrderType),
+		Recent:       append([]FillQuality(nil), t.recent...),
+	}
+}
+
+func copyStats(stats map[string]*QualityStats) map[string]*QualityStats {
+	statsCopy := make(map[string]*QualityStats, len(stats))
+	for key, s := range stats {
+		sCopy := *s
+		statsCopy[key] = &sCopy
+	}
+	return statsCopy
+}
+
+// Quality collects execution quality of all bots
+var Quality = NewQualityTracker(100)
@@ -0,0 +1,96 @@
+package execution
+
+import (
+	"math"
+	"testing"
+	"time"
+	"tinkoff-invest-contest/internal/client/investapi"
+	"tinkoff-invest-contest/internal/utils"
+)
+
+func TestNewFillQuality(t *testing.T) {
+	orderBook := &investapi.OrderBook{
+		Bids: []*investapi.Order{{Price: utils.FloatToQuotation(99.9), Quantity: 1}},
+		Asks: []*investapi.Order{{Price: utils.FloatToQuotation(100.1), Quantity: 1}},
+	}
+	tests := []struct {
+		name                    string
+		report                  Report
+		direction               investapi.OrderDirection
+		orderBook               *investapi.OrderBook
+		wantSignalSlippageTicks float64
+		wantSignalSlippageBps   float64
+		wantMidSlippageTicks    float64
+		wantFillRatio           float64
+	}{
+		{
+			name:                    "test1",
+			report:                  Report{SignalPrice: 100, AvgPrice: 100.2, LotsPosted: 10, LotsExecuted: 10},
+			direction:               investapi.OrderDirection_ORDER_DIRECTION_BUY,
+			orderBook:               orderBook,
+			wantSignalSlippageTicks: 20,
+			wantSignalSlippageBps:   20,
+			wantMidSlippageTicks:    20,
+			wantFillRatio:           1,
+		},
+		{
+			name:                    "test2",
+			report:                  Report{SignalPrice: 100, AvgPrice: 100.2, LotsPosted: 8, LotsExecuted: 2},
+			direction:               investapi.OrderDirection_ORDER_DIRECTION_SELL,
+			orderBook:               nil,
+			wantSignalSlippageTicks: -20,
+			wantSignalSlippageBps:   -20,
+			wantMidSlippageTicks:    0,
+			wantFillRatio:           0.25,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			signalTime := time.Now()
+			got := NewFillQuality("0", "figi", tt.report, tt.direction, tt.orderBook, utils.FloatToQuotation(0.01),
+				signalTime, signalTime.Add(time.Second))
+			if math.Abs(got.SignalSlippageTicks-tt.wantSignalSlippageTicks) > 1e-6 {
+				t.Errorf("NewFillQuality() SignalSlippageTicks = %v, want %v", got.SignalSlippageTicks, tt.wantSignalSlippageTicks)
+			}
+			if math.Abs(got.SignalSlippageBps-tt.wantSignalSlippageBps) > 1e-6 {
+				t.Errorf("NewFillQuality() SignalSlippageBps = %v, want %v", got.SignalSlippageBps, tt.wantSignalSlippageBps)
+			}
+			if math.Abs(got.MidSlippageTicks-tt.wantMidSlippageTicks) > 1e-6 {
+				t.Errorf("NewFillQuality() MidSlippageTicks = %v, want %v", got.MidSlippageTicks, tt.wantMidSlippageTicks)
+			}
+			if got.FillRatio != tt.wantFillRatio {
+				t.Errorf("NewFillQuality() FillRatio = %v, want %v", got.FillRatio, tt.wantFillRatio)
+			}
+			if got.SignalToFill != time.Second {
+				t.Errorf("NewFillQuality() SignalToFill = %v, want %v", got.SignalToFill, time.Second)
+			}
+		})
+	}
+}
+
+func TestQualityTracker_Record(t *testing.T) {
+	tracker := NewQualityTracker(2)
+	fills := []FillQuality{
+		{Bot: "0", Figi: "A", OrderType: "market", SignalSlippageBps: 10, MidPrice: 100, MidSlippageBps: 4, SignalToFill: time.Second, LotsExecuted: 1},
+		{Bot: "0", Figi: "B", OrderType: "limit", SignalSlippageBps: -2, FillRatio: 0.5, SignalToFill: 3 * time.Second, LotsExecuted: 1},
+		{Bot: "0", Figi: "B", OrderType: "limit", MidPrice: 100, SignalToFill: 5 * time.Second},
+		{Bot: "1", Figi: "A", OrderType: "limit", SignalSlippageBps: 4, MidPrice: 100, MidSlippageBps: 2, FillRatio: 1, LotsExecuted: 2},
+	}
+	for _, fill := range fills {
+		tracker.Record(fill)
+	}
+	summary := tracker.Summary()
+	if bot := summary.ByBot["0"]; bot.Fills != 2 || bot.AvgSignalSlippageBps != 4 || bot.AvgSignalToFill != 2*time.Second ||
+		bot.AvgMidSlippageBps != 4 {
+		t.Errorf("Summary() by bot 0 = %+v", bot)
+	}
+	if instrument := summary.ByInstrument["A"]; instrument.Fills != 2 || instrument.AvgMidSlippageBps != 3 {
+		t.Errorf("Summary() by instrument A = %+v", instrument)
+	}
+	if limit := summary.ByOrderType["limit"]; limit.LimitOrders != 3 || limit.Fills != 2 || limit.AvgLimitFillRatio != 0.5 {
+		t.Errorf("Summary() by limit order type = %+v", limit)
+	}
+	if len(summary.Recent) != 2 || summary.Recent[1].Bot != "1" {
+		t.Errorf("Summary() recent fills = %+v", summary.Recent)
+	}
+}
@@ -0,0 +1,23 @@
+package health
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+var errTimeout = errors.New("check timed out")
+
+// MaxAge returns a check that fails if the last event happened longer than maxAge ago (or never)
+func MaxAge(what string, last func() time.Time, maxAge time.Duration) Check {
+	return func() error {
+		ts := last()
+		if ts.IsZero() {
+			return fmt.Errorf("no %v received yet", what)
+		}
+		if age := time.Since(ts); age > maxAge {
+			return fmt.Errorf("last %v was received %v ago", what, age.Round(time.Second))
+		}
+		return nil
+	}
+}
@@ -0,0 +1,51 @@
+package health
+
+import (
+	"testing"
+	"time"
+)
+
+func TestMaxAge(t *testing.T) {
+	type args struct {
+		last   time.Time
+		maxAge time.Duration
+	}
+	tests := []struct {
+		name    string
+		args    args
+		wantErr bool
+	}{
+		{
+			name: "test1",
+			args: args{
+				last:   time.Time{},
+				maxAge: time.Minute,
+			},
+			wantErr: true,
+		},
+		{
+			name: "test2",
+			args: args{
+				last:   time.Now().Add(-10 * time.Second),
+				maxAge: time.Minute,
+			},
+			wantErr: false,
+		},
+		{
+			name: "test3",
+			args: args{
+				last:   time.Now().Add(-2 * time.Minute),
+				maxAge: time.Minute,
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			check := MaxAge("message", func() time.Time { return tt.args.last }, tt.args.maxAge)
+			if err := check(); (err != nil) != tt.wantErr {
+				t.Errorf("MaxAge() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
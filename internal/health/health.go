@@ -0,0 +1,136 @@
+package health
+
+import (
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Check returns nil if a component is healthy, or an error describing what is broken
+type Check func() error
+
+type checkEntry struct {
+	name     string
+	check    Check
+	liveness bool
+}
+
+type checkResult struct {
+	Status     string  `json:"status"`
+	Error      string  `json:"error,omitempty"`
+	DurationMs float64 `json:"durationMs"`
+}
+
+type report struct {
+	Status string                 `json:"status"`
+	Checks map[string]checkResult `json:"checks"`
+}
+
+const checkTimeout = 5 * time.Second
+
+var registry = struct {
+	mu     sync.RWMutex
+	checks []checkEntry
+}{}
+
+// RegisterLiveness adds a check whose failure means the process should be restarted.
+// Liveness checks are also a part of readiness
+func RegisterLiveness(name string, check Check) {
+	register(name, check, true)
+}
+
+// RegisterReadiness adds a check whose failure means the process can't do its job at the moment
+func RegisterReadiness(name string, check Check) {
+	register(name, check, false)
+}
+
+func register(name string, check Check, liveness bool) {
+	registry.mu.Lock()
+	registry.checks = append(registry.checks, checkEntry{
+		name:     name,
+		check:    check,
+		liveness: liveness,
+	})
+	sort.Slice(registry.checks, func(i, j int) bool {
+		return registry.checks[i].name < registry.checks[j].name
+	})
+	registry.mu.Unlock()
+}
+
+// Healthz responds with results of liveness checks
+func Healthz(c *gin.Context) {
+	respond(c, true)
+}
+
+// Readyz responds with results of all checks
+func Readyz(c *gin.Context) {
+	respond(c, false)
+}
+
+func respond(c *gin.Context, livenessOnly bool) {
+	registry.mu.RLock()
+	checks := make([]checkEntry, 0, len(registry.checks))
+	for _, entry := range registry.checks {
+		if entry.liveness || !livenessOnly {
+			checks = append(checks, entry)
+		}
+	}
+	registry.mu.RUnlock()
+
+	r := run(checks)
+	status := http.StatusOK
+	if r.Status != "ok" {
+		status = http.StatusServiceUnavailable
+	}
+	c.JSON(status, r)
+}
+
+func run(checks []checkEntry) report {
+	r := report{
+		Status: "ok",
+		Checks: make(map[string]checkResult),
+	}
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for _, entry := range checks {
+		wg.Add(1)
+		go func(entry checkEntry) {
+			defer wg.Done()
+			result := runOne(entry.check)
+			mu.Lock()
+			r.Checks[entry.name] = result
+			if result.Status != "ok" {
+				r.Status = "fail"
+			}
+			mu.Unlock()
+		}(entry)
+	}
+	wg.Wait()
+	return r
+}
+
+// runOne runs a check with a timeout, so that a hanging dependency doesn't hang the probe
+func runOne(check Check) checkResult {
+	start := time.Now()
+	done := make(chan error, 1)
+	go func() {
+		done <- check()
+	}()
+	var err error
+	select {
+	case err = <-done:
+	case <-time.After(checkTimeout):
+		err = errTimeout
+	}
+	result := checkResult{
+		Status:     "ok",
+		DurationMs: float64(time.Since(start).Microseconds()) / 1000,
+	}
+	if err != nil {
+		result.Status = "fail"
+		result.Error = err.Error()
+	}
+	return result
+}
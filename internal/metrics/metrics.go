@@ -0,0 +1,136 @@
+package metrics
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"sync"
+)
+
+const namespace = "trade"
+
+var (
+	MarketDataEvents = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "market_data_events_total",
+		Help:      "Market data stream events received, by event type.",
+	}, []string{"type"})
+
+	MarketDataDropped = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "market_data_dropped_total",
+		Help:      "Market data events dropped because a bot's channel was full, by bot and channel.",
+	}, []string{"bot", "channel"})
+
+	StreamReconnects = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "stream_reconnects_total",
+		Help:      "Stream recovery attempts, by stream.",
+	}, []string{"stream"})
+
+	GRPCCallDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "grpc_call_duration_seconds",
+		Help:      "Invest API unary call latency, by method.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method"})
+
+	GRPCCalls = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "grpc_calls_total",
+		Help:      "Invest API unary calls, by method and status code.",
+	}, []string{"method", "code"})
+
+	Orders = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "orders_total",
+		Help:      "Orders by bot and outcome (placed, filled, rejected).",
+	}, []string{"bot", "status"})
+
+	DoOrderDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "do_order_duration_seconds",
+		Help:      "Time spent placing an order and waiting for it to be filled, by environment.",
+		Buckets:   []float64{0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60, 300},
+	}, []string{"env"})
+
+	ExecutionSlippage = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "execution_slippage_ratio",
+		Help:      "Relative difference between parent orders' average fill price and signal price, positive when unfavorable, by bot and execution algorithm.",
+		Buckets:   []float64{-0.01, -0.005, -0.001, 0, 0.001, 0.005, 0.01, 0.02},
+	}, []string{"bot", "algorithm"})
+
+	ReconcileDiscrepancies = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "reconcile_discrepancies_total",
+		Help:      "Mismatches between bots' and broker's positions, by bot and applied policy.",
+	}, []string{"bot", "policy"})
+
+	BotPosition = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "bot_position",
+		Help:      "Bot's position in instrument units, negative for short.",
+	}, []string{"bot", "figi"})
+
+	BotRealizedPnL = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "bot_realized_pnl",
+		Help:      "Bot's realized profit and loss in instrument currency.",
+	}, []string{"bot", "figi", "currency"})
+
+	BotUnrealizedPnL = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "bot_unrealized_pnl",
+		Help:      "Bot's unrealized profit and loss of the open position in instrument currency.",
+	}, []string{"bot", "figi", "currency"})
+)
+
+var channelBacklogs = struct {
+	mu sync.Mutex
+	// Registered gauges, keyed by bot and channel
+	gauges map[string]map[string]prometheus.Collector
+}{
+	gauges: make(map[string]map[string]prometheus.Collector),
+}
+
+// RegisterChannelBacklog exposes the number of queued elements of a channel,
+// replacing the gauge previously registered for the same bot and channel
+func RegisterChannelBacklog(bot string, channel string, backlog func() int) {
+	gauge := prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "market_data_channel_backlog",
+		Help:      "Number of market data events waiting to be consumed by a bot, by channel.",
+		ConstLabels: prometheus.Labels{
+			"bot":     bot,
+			"channel": channel,
+		},
+	}, func() float64 {
+		return float64(backlog())
+	})
+	channelBacklogs.mu.Lock()
+	defer channelBacklogs.mu.Unlock()
+	if channelBacklogs.gauges[bot] == nil {
+		channelBacklogs.gauges[bot] = make(map[string]prometheus.Collector)
+	}
+	if previous, ok := channelBacklogs.gauges[bot][channel]; ok {
+		prometheus.Unregister(previous)
+	}
+	prometheus.MustRegister(gauge)
+	channelBacklogs.gauges[bot][channel] = gauge
+}
+
+// UnregisterChannelBacklogs removes the backlog gauges of all bot's channels
+func UnregisterChannelBacklogs(bot string) {
+	channelBacklogs.mu.Lock()
+	defer channelBacklogs.mu.Unlock()
+	for _, gauge := range channelBacklogs.gauges[bot] {
+		prometheus.Unregister(gauge)
+	}
+	delete(channelBacklogs.gauges, bot)
+}
+
+func Handler() gin.HandlerFunc {
+	return gin.WrapH(promhttp.Handler())
+}
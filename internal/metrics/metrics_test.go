@@ -0,0 +1,45 @@
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"testing"
+)
+
+// countBacklogs returns the number of exposed backlog gauges of the bot
+func countBacklogs(t *testing.T, bot string) int {
+	families, err := prometheus.DefaultGatherer.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	count := 0
+	for _, family := range families {
+		if family.GetName() != namespace+"_market_data_channel_backlog" {
+			continue
+		}
+		for _, metric := range family.GetMetric() {
+			for _, label := range metric.GetLabel() {
+				if label.GetName() == "bot" && label.GetValue() == bot {
+					count++
+				}
+			}
+		}
+	}
+	return count
+}
+
+func TestRegisterChannelBacklog(t *testing.T) {
+	RegisterChannelBacklog("1", "candle", func() int { return 1 })
+	RegisterChannelBacklog("1", "candle", func() int { return 2 })
+	RegisterChannelBacklog("1", "trade", func() int { return 3 })
+	RegisterChannelBacklog("2", "candle", func() int { return 4 })
+	if got := countBacklogs(t, "1"); got != 2 {
+		t.Errorf("bot 1 has %v backlog gauges, want 2", got)
+	}
+	UnregisterChannelBacklogs("1")
+	if got := countBacklogs(t, "1"); got != 0 {
+		t.Errorf("bot 1 has %v backlog gauges after unregistering, want 0", got)
+	}
+	if got := countBacklogs(t, "2"); got != 1 {
+		t.Errorf("bot 2 has %v backlog gauges, want 1", got)
+	}
+}
@@ -0,0 +1,539 @@
+package paper
+
+import (
+	"context"
+	"fmt"
+	"github.com/google/uuid"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"math"
+	"sort"
+	"sync"
+	"time"
+	"tinkoff-invest-contest/internal/client/investapi"
+	"tinkoff-invest-contest/internal/utils"
+)
+
+// Venue is a local execution venue which fills orders against the latest order books of their instruments,
+// the way an exchange would fill them if the order book didn't change because of them:
+// market orders walk the book, limit orders take the levels they cross and rest until the price crosses them.
+// Whatever the book lacks is filled on the following order books, so orders may be filled partially.
+// Commission is charged on every fill
+type Venue struct {
+	fee float64
+
+	mu sync.Mutex
+	// Instruments orders can be placed for, by FIGI, for their lot size and currency
+	instruments map[string]utils.InstrumentInterface
+	orderBooks  map[string]*investapi.OrderBook
+	accounts    map[string]*account
+	orders      map[string]*order
+	// Number of the latest order, earlier orders are filled first
+	seq int64
+}
+
+type account struct {
+	money     map[string]float64
+	positions map[string]*position
+}
+
+type position struct {
+	lot int32
+	// In instrument units, negative for short position
+	balance  int64
+	avgPrice float64
+	currency string
+}
+
+type order struct {
+	id        string
+	accountId string
+	seq       int64
+	figi      string
+	lot       int32
+	currency  string
+	direction investapi.OrderDirection
+	orderType investapi.OrderType
+	// Limit price, zero for market orders
+	price float64
+	// Price money is reserved at for a buy order, the limit price or the best ask when a market order is placed
+	reservePrice float64
+	placedAt     time.Time
+	status       investapi.OrderExecutionReportStatus
+	message      string
+	lots         int64
+	executed     int64
+	notional     float64
+	commission   float64
+	// Commission of the whole order at its limit price
+	initialCommission float64
+	stages            []*investapi.OrderStage
+	done              chan struct{}
+}
+
+func NewVenue(fee float64) *Venue {
+	return &Venue{
+		fee:         fee,
+		instruments: make(map[string]utils.InstrumentInterface),
+		orderBooks:  make(map[string]*investapi.OrderBook),
+		accounts:    make(map[string]*account),
+		orders:      make(map[string]*order),
+	}
+}
+
+// AddInstrument lets orders be placed for the instrument
+func (v *Venue) AddInstrument(instrument utils.InstrumentInterface) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.instruments[instrument.GetFigi()] = instrument
+}
+
+// Fee returns the commission rate charged on fills
+func (v *Venue) Fee() float64 {
+	return v.fee
+}
+
+// OpenAccount opens an account with the given money by currency
+func (v *Venue) OpenAccount(money map[string]float64) string {
+	id := uuid.New().String()
+	a := &account{
+		money:     make(map[string]float64),
+		positions: make(map[string]*position),
+	}
+	for currency, amount := range money {
+		a.money[currency] = amount
+	}
+	v.mu.Lock()
+	v.accounts[id] = a
+	v.mu.Unlock()
+	return id
+}
+
+// CloseAccount closes the account and forgets its orders
+func (v *Venue) CloseAccount(accountId string) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	for id, o := range v.orders {
+		if o.accountId == accountId {
+			if o.isActive() {
+				o.finish(investapi.OrderExecutionReportStatus_EXECUTION_REPORT_STATUS_CANCELLED, "account is closed")
+			}
+			delete(v.orders, id)
+		}
+	}
+	delete(v.accounts, accountId)
+}
+
+// UpdateOrderBook makes the order book the latest one of its instrument and fills the orders it crosses.
+// Inconsistent and one-sided order books are ignored
+func (v *Venue) UpdateOrderBook(orderBook *investapi.OrderBook) {
+	if !orderBook.IsConsistent || len(orderBook.Bids) == 0 || len(orderBook.Asks) == 0 {
+		return
+	}
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.orderBooks[orderBook.Figi] = orderBook
+	var active []*order
+	for _, o := range v.orders {
+		if o.figi == orderBook.Figi && o.isActive() {
+			active = append(active, o)
+		}
+	}
+	sort.Slice(active, func(i, j int) bool {
+		return active[i].seq < active[j].seq
+	})
+	// Orders share the liquidity of the book
+	asks, bids := newLevels(orderBook.Asks), newLevels(orderBook.Bids)
+	for _, o := range active {
+		if o.direction == investapi.OrderDirection_ORDER_DIRECTION_BUY {
+			v.match(o, asks, true)
+		} else {
+			v.match(o, bids, true)
+		}
+	}
+}
+
+// PostOrder places an order, which is matched against the latest order book of the instrument right away
+func (v *Venue) PostOrder(figi string, quantity int64, price *investapi.Quotation, direction investapi.OrderDirection,
+	accountId string, orderType investapi.OrderType, orderId string) (*investapi.PostOrderResponse, error) {
+	if quantity <= 0 {
+		return nil, fmt.Errorf("invalid quantity: %v", quantity)
+	}
+	if orderType != investapi.OrderType_ORDER_TYPE_MARKET && orderType != investapi.OrderType_ORDER_TYPE_LIMIT {
+		return nil, fmt.Errorf("unsupported order type: %v", orderType)
+	}
+	var limitPrice float64
+	if orderType == investapi.OrderType_ORDER_TYPE_LIMIT {
+		if price != nil {
+			limitPrice = utils.QuotationToFloat(price)
+		}
+		if limitPrice <= 0 {
+			return nil, fmt.Errorf("invalid limit price: %v", limitPrice)
+		}
+	}
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	instrument, ok := v.instruments[figi]
+	if !ok {
+		return nil, fmt.Errorf("instrument %v is unknown to the venue", figi)
+	}
+	a, ok := v.accounts[accountId]
+	if !ok {
+		return nil, fmt.Errorf("account %v not found", accountId)
+	}
+	if _, ok = v.orders[orderId]; ok {
+		return nil, fmt.Errorf("order %v already exists", orderId)
+	}
+	v.seq++
+	o := &order{
+		id:        orderId,
+		accountId: accountId,
+		seq:       v.seq,
+		figi:      figi,
+		lot:       instrument.GetLot(),
+		currency:  instrument.GetCurrency(),
+		direction: direction,
+		orderType: orderType,
+		placedAt:  time.Now(),
+		status:    investapi.OrderExecutionReportStatus_EXECUTION_REPORT_STATUS_NEW,
+		lots:      quantity,
+		done:      make(chan struct{}),
+	}
+	if orderType == investapi.OrderType_ORDER_TYPE_LIMIT {
+		o.price = limitPrice
+		o.initialCommission = limitPrice * float64(quantity*int64(o.lot)) * v.fee
+	}
+	v.orders[orderId] = o
+
+	orderBook := v.orderBooks[figi]
+	if message := v.check(a, o, orderBook); message != "" {
+		o.finish(investapi.OrderExecutionReportStatus_EXECUTION_REPORT_STATUS_REJECTED, message)
+	} else if orderBook != nil {
+		if direction == investapi.OrderDirection_ORDER_DIRECTION_BUY {
+			v.match(o, newLevels(orderBook.Asks), false)
+		} else {
+			v.match(o, newLevels(orderBook.Bids), false)
+		}
+	}
+
+	state := o.state()
+	return &investapi.PostOrderResponse{
+		OrderId:               state.OrderId,
+		ExecutionReportStatus: state.ExecutionReportStatus,
+		LotsRequested:         state.LotsRequested,
+		LotsExecuted:          state.LotsExecuted,
+		InitialOrderPrice:     state.InitialOrderPrice,
+		ExecutedOrderPrice:    state.ExecutedOrderPrice,
+		TotalOrderAmount:      state.TotalOrderAmount,
+		InitialCommission:     state.InitialCommission,
+		ExecutedCommission:    state.ExecutedCommission,
+		Figi:                  state.Figi,
+		Direction:             state.Direction,
+		InitialSecurityPrice:  state.InitialSecurityPrice,
+		OrderType:             state.OrderType,
+		Message:               o.message,
+	}, nil
+}
+
+// check returns why the account can't afford the order, an empty string if it can.
+// Buying needs money for the order at its limit price or the best ask, selling needs the securities.
+// Money and securities reserved by the account's other active orders aren't available
+func (v *Venue) check(a *account, o *order, orderBook *investapi.OrderBook) string {
+	units := o.lots * int64(o.lot)
+	reservedMoney, reservedUnits := v.reserved(o)
+	if o.direction == investapi.OrderDirection_ORDER_DIRECTION_SELL {
+		if p, ok := a.positions[o.figi]; !ok || p.balance-reservedUnits < units {
+			return "not enough securities"
+		}
+		return ""
+	}
+	o.reservePrice = o.price
+	if o.reservePrice == 0 {
+		if orderBook == nil {
+			return "no order book to estimate market order price"
+		}
+		o.reservePrice = utils.QuotationToFloat(orderBook.Asks[0].Price)
+	}
+	if a.money[o.currency]-reservedMoney < o.reservePrice*float64(units)*(1+v.fee) {
+		return "not enough money"
+	}
+	return ""
+}
+
+// reserved returns the money in the order's currency and the units of its instrument that the other active orders
+// of its account hold for the lots they haven't executed yet. They're released as the orders are filled or cancelled
+func (v *Venue) reserved(o *order) (money float64, units int64) {
+	for _, other := range v.orders {
+		if other == o || other.accountId != o.accountId || !other.isActive() {
+			continue
+		}
+		remainingUnits := (other.lots - other.executed) * int64(other.lot)
+		if other.direction == investapi.OrderDirection_ORDER_DIRECTION_BUY && other.currency == o.currency {
+			money += other.reservePrice * float64(remainingUnits) * (1 + v.fee)
+		} else if other.direction == investapi.OrderDirection_ORDER_DIRECTION_SELL && other.figi == o.figi {
+			units += remainingUnits
+		}
+	}
+	return money, units
+}
+
+// level is a price level of an order book, quantity is what's left of it
+type level struct {
+	price    float64
+	quantity int64
+}
+
+func newLevels(orders []*investapi.Order) []*level {
+	levels := make([]*level, 0, len(orders))
+	for _, o := range orders {
+		levels = append(levels, &level{utils.QuotationToFloat(o.Price), o.Quantity})
+	}
+	return levels
+}
+
+// match fills the order from the opposite side levels, best first. A resting limit order gets its own price,
+// other orders get the prices of the levels
+func (v *Venue) match(o *order, levels []*level, resting bool) {
+	for _, l := range levels {
+		if o.executed == o.lots {
+			break
+		}
+		if l.quantity <= 0 {
+			continue
+		}
+		if o.price != 0 && (o.direction == investapi.OrderDirection_ORDER_DIRECTION_BUY && l.price > o.price ||
+			o.direction == investapi.OrderDirection_ORDER_DIRECTION_SELL && l.price < o.price) {
+			break
+		}
+		lots := o.lots - o.executed
+		if l.quantity < lots {
+			lots = l.quantity
+		}
+		l.quantity -= lots
+		price := l.price
+		if resting && o.price != 0 {
+			price = o.price
+		}
+		v.fill(o, lots, price)
+	}
+}
+
+// fill executes lots of the order at price, updating the account
+func (v *Venue) fill(o *order, lots int64, price float64) {
+	units := lots * int64(o.lot)
+	value := price * float64(units)
+	commission := value * v.fee
+	o.executed += lots
+	o.notional += price * float64(lots)
+	o.commission += commission
+	o.stages = append(o.stages, &investapi.OrderStage{
+		Price:    utils.FloatToMoneyValue(o.currency, price),
+		Quantity: lots,
+		TradeId:  uuid.New().String(),
+	})
+
+	a := v.accounts[o.accountId]
+	p, ok := a.positions[o.figi]
+	if !ok {
+		p = &position{lot: o.lot, currency: o.currency}
+		a.positions[o.figi] = p
+	}
+	if o.direction == investapi.OrderDirection_ORDER_DIRECTION_BUY {
+		a.money[o.currency] -= value + commission
+		p.add(units, price)
+	} else {
+		a.money[o.currency] += value - commission
+		p.add(-units, price)
+	}
+
+	if o.executed == o.lots {
+		o.finish(investapi.OrderExecutionReportStatus_EXECUTION_REPORT_STATUS_FILL, "")
+	} else {
+		o.status = investapi.OrderExecutionReportStatus_EXECUTION_REPORT_STATUS_PARTIALLYFILL
+	}
+}
+
+// add changes the balance by units bought (negative if sold) at price. The average price changes when the position
+// grows, and is the price itself when the position is reversed
+func (p *position) add(units int64, price float64) {
+	balance := p.balance + units
+	switch {
+	case balance == 0:
+		p.avgPrice = 0
+	case p.balance == 0 || (p.balance > 0) == (units > 0):
+		p.avgPrice = (p.avgPrice*math.Abs(float64(p.balance)) + price*math.Abs(float64(units))) /
+			math.Abs(float64(balance))
+	case (p.balance > 0) != (balance > 0):
+		p.avgPrice = price
+	}
+	p.balance = balance
+}
+
+func (o *order) isActive() bool {
+	return o.status == investapi.OrderExecutionReportStatus_EXECUTION_REPORT_STATUS_NEW ||
+		o.status == investapi.OrderExecutionReportStatus_EXECUTION_REPORT_STATUS_PARTIALLYFILL
+}
+
+func (o *order) finish(status investapi.OrderExecutionReportStatus, message string) {
+	o.status, o.message = status, message
+	close(o.done)
+}
+
+func (o *order) state() *investapi.OrderState {
+	state := &investapi.OrderState{
+		OrderId:               o.id,
+		ExecutionReportStatus: o.status,
+		LotsRequested:         o.lots,
+		LotsExecuted:          o.executed,
+		ExecutedOrderPrice:    utils.FloatToMoneyValue(o.currency, o.notional*float64(o.lot)),
+		TotalOrderAmount:      utils.FloatToMoneyValue(o.currency, o.notional*float64(o.lot)+o.commission),
+		AveragePositionPrice:  utils.FloatToMoneyValue(o.currency, 0),
+		ExecutedCommission:    utils.FloatToMoneyValue(o.currency, o.commission),
+		Figi:                  o.figi,
+		Direction:             o.direction,
+		InitialSecurityPrice:  utils.FloatToMoneyValue(o.currency, o.price),
+		Stages:                o.stages,
+		Currency:              o.currency,
+		OrderType:             o.orderType,
+		OrderDate:             timestamppb.New(o.placedAt),
+		InitialOrderPrice:     utils.FloatToMoneyValue(o.currency, o.price*float64(o.lots*int64(o.lot))),
+		InitialCommission:     utils.FloatToMoneyValue(o.currency, o.initialCommission),
+	}
+	if o.executed > 0 {
+		state.AveragePositionPrice = utils.FloatToMoneyValue(o.currency, o.notional/float64(o.executed))
+	}
+	return state
+}
+
+// CancelOrder cancels an active order, the executed part stays executed
+func (v *Venue) CancelOrder(accountId string, orderId string) error {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	o, err := v.getOrder(accountId, orderId)
+	if err != nil {
+		return err
+	}
+	if !o.isActive() {
+		return fmt.Errorf("order %v is %v and can't be cancelled", orderId, o.status)
+	}
+	o.finish(investapi.OrderExecutionReportStatus_EXECUTION_REPORT_STATUS_CANCELLED, "")
+	return nil
+}
+
+func (v *Venue) getOrder(accountId string, orderId string) (*order, error) {
+	o, ok := v.orders[orderId]
+	if !ok || o.accountId != accountId {
+		return nil, fmt.Errorf("order %v not found", orderId)
+	}
+	return o, nil
+}
+
+func (v *Venue) GetOrderState(accountId string, orderId string) (*investapi.OrderState, error) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	o, err := v.getOrder(accountId, orderId)
+	if err != nil {
+		return nil, err
+	}
+	return o.state(), nil
+}
+
+// WaitOrder waits for the order to be filled, rejected or cancelled and returns its final state
+func (v *Venue) WaitOrder(ctx context.Context, accountId string, orderId string) (*investapi.OrderState, error) {
+	v.mu.Lock()
+	o, err := v.getOrder(accountId, orderId)
+	v.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	select {
+	case <-o.done:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	return o.state(), nil
+}
+
+// GetOrders returns the account's active orders in the order they were placed
+func (v *Venue) GetOrders(accountId string) ([]*investapi.OrderState, error) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if _, ok := v.accounts[accountId]; !ok {
+		return nil, fmt.Errorf("account %v not found", accountId)
+	}
+	var active []*order
+	for _, o := range v.orders {
+		if o.accountId == accountId && o.isActive() {
+			active = append(active, o)
+		}
+	}
+	sort.Slice(active, func(i, j int) bool {
+		return active[i].seq < active[j].seq
+	})
+	orders := make([]*investapi.OrderState, 0, len(active))
+	for _, o := range active {
+		orders = append(orders, o.state())
+	}
+	return orders, nil
+}
+
+// GetPortfolio returns the account's securities positions
+func (v *Venue) GetPortfolio(accountId string) (*investapi.PortfolioResponse, error) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	a, ok := v.accounts[accountId]
+	if !ok {
+		return nil, fmt.Errorf("account %v not found", accountId)
+	}
+	portfolio := &investapi.PortfolioResponse{}
+	for _, figi := range a.figis() {
+		p := a.positions[figi]
+		portfolio.Positions = append(portfolio.Positions, &investapi.PortfolioPosition{
+			Figi:                 figi,
+			Quantity:             utils.FloatToQuotation(float64(p.balance)),
+			QuantityLots:         utils.FloatToQuotation(float64(p.balance / int64(p.lot))),
+			AveragePositionPrice: utils.FloatToMoneyValue(p.currency, p.avgPrice),
+		})
+	}
+	return portfolio, nil
+}
+
+// GetPositions returns the account's money and securities
+func (v *Venue) GetPositions(accountId string) (*investapi.PositionsResponse, error) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	a, ok := v.accounts[accountId]
+	if !ok {
+		return nil, fmt.Errorf("account %v not found", accountId)
+	}
+	positions := &investapi.PositionsResponse{}
+	currencies := make([]string, 0, len(a.money))
+	for currency := range a.money {
+		currencies = append(currencies, currency)
+	}
+	sort.Strings(currencies)
+	for _, currency := range currencies {
+		positions.Money = append(positions.Money, utils.FloatToMoneyValue(currency, a.money[currency]))
+	}
+	for _, figi := range a.figis() {
+		positions.Securities = append(positions.Securities, &investapi.PositionsSecurities{
+			Figi:    figi,
+			Balance: a.positions[figi].balance,
+		})
+	}
+	return positions, nil
+}
+
+// figis returns FIGIs of the account's non-zero positions, sorted
+func (a *account) figis() []string {
+	figis := make([]string, 0, len(a.positions))
+	for figi, p := range a.positions {
+		if p.balance != 0 {
+			figis = append(figis, figi)
+		}
+	}
+	sort.Strings(figis)
+	return figis
+}
@@ -0,0 +1,267 @@
+package paper
+
+import (
+	"context"
+	"math"
+	"testing"
+	"time"
+	"tinkoff-invest-contest/internal/client/investapi"
+	"tinkoff-invest-contest/internal/utils"
+)
+
+const figi = "BBG000000001"
+
+func newVenue(fee float64) *Venue {
+	venue := NewVenue(fee)
+	venue.AddInstrument(&investapi.Share{Figi: figi, Lot: 10, Currency: "rub"})
+	return venue
+}
+
+// newOrderBook makes an order book from price and quantity pairs of asks and bids, best first
+func newOrderBook(asks []float64, bids []float64) *investapi.OrderBook {
+	orderBook := &investapi.OrderBook{Figi: figi, IsConsistent: true}
+	for i := 0; i < len(asks); i += 2 {
+		orderBook.Asks = append(orderBook.Asks, &investapi.Order{Price: utils.FloatToQuotation(asks[i]), Quantity: int64(asks[i+1])})
+	}
+	for i := 0; i < len(bids); i += 2 {
+		orderBook.Bids = append(orderBook.Bids, &investapi.Order{Price: utils.FloatToQuotation(bids[i]), Quantity: int64(bids[i+1])})
+	}
+	return orderBook
+}
+
+func almostEqual(a float64, b float64) bool {
+	return math.Abs(a-b) < 1e-9
+}
+
+func TestVenue_PostOrder(t *testing.T) {
+	orderBook := newOrderBook([]float64{100.1, 3, 100.2, 4}, []float64{99.9, 5})
+	tests := []struct {
+		name         string
+		quantity     int64
+		price        float64
+		orderType    investapi.OrderType
+		wantStatus   investapi.OrderExecutionReportStatus
+		wantExecuted int64
+		wantAvgPrice float64
+	}{
+		{
+			name:         "test1",
+			quantity:     5,
+			orderType:    investapi.OrderType_ORDER_TYPE_MARKET,
+			wantStatus:   investapi.OrderExecutionReportStatus_EXECUTION_REPORT_STATUS_FILL,
+			wantExecuted: 5,
+			wantAvgPrice: (100.1*3 + 100.2*2) / 5,
+		},
+		{
+			name:         "test2",
+			quantity:     10,
+			orderType:    investapi.OrderType_ORDER_TYPE_MARKET,
+			wantStatus:   investapi.OrderExecutionReportStatus_EXECUTION_REPORT_STATUS_PARTIALLYFILL,
+			wantExecuted: 7,
+			wantAvgPrice: (100.1*3 + 100.2*4) / 7,
+		},
+		{
+			name:         "test3",
+			quantity:     5,
+			price:        100.1,
+			orderType:    investapi.OrderType_ORDER_TYPE_LIMIT,
+			wantStatus:   investapi.OrderExecutionReportStatus_EXECUTION_REPORT_STATUS_PARTIALLYFILL,
+			wantExecuted: 3,
+			wantAvgPrice: 100.1,
+		},
+		{
+			name:       "test4",
+			quantity:   5,
+			price:      100,
+			orderType:  investapi.OrderType_ORDER_TYPE_LIMIT,
+			wantStatus: investapi.OrderExecutionReportStatus_EXECUTION_REPORT_STATUS_NEW,
+		},
+		{
+			name:       "test5",
+			quantity:   1000,
+			orderType:  investapi.OrderType_ORDER_TYPE_MARKET,
+			wantStatus: investapi.OrderExecutionReportStatus_EXECUTION_REPORT_STATUS_REJECTED,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			venue := newVenue(0.001)
+			venue.UpdateOrderBook(orderBook)
+			accountId := venue.OpenAccount(map[string]float64{"rub": 100000})
+			order, err := venue.PostOrder(figi, tt.quantity, utils.FloatToQuotation(tt.price),
+				investapi.OrderDirection_ORDER_DIRECTION_BUY, accountId, tt.orderType, "1")
+			if err != nil {
+				t.Fatalf("PostOrder() error = %v", err)
+			}
+			if order.ExecutionReportStatus != tt.wantStatus || order.LotsExecuted != tt.wantExecuted {
+				t.Fatalf("PostOrder() = %v, %v lots, want %v, %v lots", order.ExecutionReportStatus, order.LotsExecuted,
+					tt.wantStatus, tt.wantExecuted)
+			}
+			state, err := venue.GetOrderState(accountId, "1")
+			if err != nil {
+				t.Fatalf("GetOrderState() error = %v", err)
+			}
+			if avgPrice := utils.MoneyValueToFloat(state.AveragePositionPrice); !almostEqual(avgPrice, tt.wantAvgPrice) {
+				t.Errorf("AveragePositionPrice = %v, want %v", avgPrice, tt.wantAvgPrice)
+			}
+			value := tt.wantAvgPrice * float64(tt.wantExecuted*10)
+			positions, _ := venue.GetPositions(accountId)
+			if money := utils.MoneyValueToFloat(positions.Money[0]); !almostEqual(money, 100000-value*1.001) {
+				t.Errorf("money = %v, want %v", money, 100000-value*1.001)
+			}
+		})
+	}
+}
+
+func TestVenue_UpdateOrderBook(t *testing.T) {
+	venue := newVenue(0)
+	venue.UpdateOrderBook(newOrderBook([]float64{100.1, 3}, []float64{99.9, 5}))
+	accountId := venue.OpenAccount(map[string]float64{"rub": 100000})
+	_, _ = venue.PostOrder(figi, 4, utils.FloatToQuotation(100), investapi.OrderDirection_ORDER_DIRECTION_BUY,
+		accountId, investapi.OrderType_ORDER_TYPE_LIMIT, "1")
+	_, _ = venue.PostOrder(figi, 2, utils.FloatToQuotation(100), investapi.OrderDirection_ORDER_DIRECTION_BUY,
+		accountId, investapi.OrderType_ORDER_TYPE_LIMIT, "2")
+
+	// The earlier order takes the liquidity first, at its own price
+	venue.UpdateOrderBook(newOrderBook([]float64{99.8, 5}, []float64{99.7, 5}))
+	first, _ := venue.GetOrderState(accountId, "1")
+	second, _ := venue.GetOrderState(accountId, "2")
+	if first.LotsExecuted != 4 || second.LotsExecuted != 1 {
+		t.Fatalf("executed %v and %v lots, want 4 and 1", first.LotsExecuted, second.LotsExecuted)
+	}
+	if avgPrice := utils.MoneyValueToFloat(first.AveragePositionPrice); avgPrice != 100 {
+		t.Errorf("AveragePositionPrice = %v, want 100", avgPrice)
+	}
+	orders, _ := venue.GetOrders(accountId)
+	if len(orders) != 1 || orders[0].OrderId != "2" {
+		t.Errorf("GetOrders() = %v, want order 2", orders)
+	}
+
+	err := venue.CancelOrder(accountId, "2")
+	if err != nil {
+		t.Fatalf("CancelOrder() error = %v", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	state, err := venue.WaitOrder(ctx, accountId, "2")
+	if err != nil || state.ExecutionReportStatus != investapi.OrderExecutionReportStatus_EXECUTION_REPORT_STATUS_CANCELLED {
+		t.Fatalf("WaitOrder() = %v, %v, want cancelled", state, err)
+	}
+
+	portfolio, _ := venue.GetPortfolio(accountId)
+	if len(portfolio.Positions) != 1 || utils.QuotationToFloat(portfolio.Positions[0].QuantityLots) != 5 {
+		t.Fatalf("GetPortfolio() = %v, want 5 lots", portfolio.Positions)
+	}
+	_, err = venue.PostOrder(figi, 6, nil, investapi.OrderDirection_ORDER_DIRECTION_SELL, accountId,
+		investapi.OrderType_ORDER_TYPE_MARKET, "3")
+	if err != nil {
+		t.Fatalf("PostOrder() error = %v", err)
+	}
+	state, _ = venue.GetOrderState(accountId, "3")
+	if state.ExecutionReportStatus != investapi.OrderExecutionReportStatus_EXECUTION_REPORT_STATUS_REJECTED {
+		t.Errorf("selling more than the position is %v, want rejected", state.ExecutionReportStatus)
+	}
+}
+
+func Test_position_add(t *testing.T) {
+	tests := []struct {
+		name         string
+		balance      int64
+		avgPrice     float64
+		units        int64
+		price        float64
+		wantBalance  int64
+		wantAvgPrice float64
+	}{
+		{
+			name:         "test1",
+			balance:      10,
+			avgPrice:     100,
+			units:        10,
+			price:        110,
+			wantBalance:  20,
+			wantAvgPrice: 105,
+		},
+		{
+			name:         "test2",
+			balance:      20,
+			avgPrice:     100,
+			units:        -10,
+			price:        110,
+			wantBalance:  10,
+			wantAvgPrice: 100,
+		},
+		{
+			name:         "test3",
+			balance:      10,
+			avgPrice:     100,
+			units:        -10,
+			price:        110,
+			wantBalance:  0,
+			wantAvgPrice: 0,
+		},
+		{
+			name:         "test4",
+			balance:      10,
+			avgPrice:     100,
+			units:        -30,
+			price:        110,
+			wantBalance:  -20,
+			wantAvgPrice: 110,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := &position{lot: 1, balance: tt.balance, avgPrice: tt.avgPrice}
+			p.add(tt.units, tt.price)
+			if p.balance != tt.wantBalance || !almostEqual(p.avgPrice, tt.wantAvgPrice) {
+				t.Errorf("add() = %v at %v, want %v at %v", p.balance, p.avgPrice, tt.wantBalance, tt.wantAvgPrice)
+			}
+		})
+	}
+}
+
+func TestVenue_PostOrder_Reserves(t *testing.T) {
+	venue := newVenue(0)
+	venue.UpdateOrderBook(newOrderBook([]float64{100.1, 10}, []float64{99.9, 10}))
+	accountId := venue.OpenAccount(map[string]float64{"rub": 10000})
+	post := func(orderId string, quantity int64, price float64, direction investapi.OrderDirection) investapi.OrderExecutionReportStatus {
+		t.Helper()
+		order, err := venue.PostOrder(figi, quantity, utils.FloatToQuotation(price), direction, accountId,
+			investapi.OrderType_ORDER_TYPE_LIMIT, orderId)
+		if err != nil {
+			t.Fatalf("PostOrder() error = %v", err)
+		}
+		return order.ExecutionReportStatus
+	}
+	buy, sell := investapi.OrderDirection_ORDER_DIRECTION_BUY, investapi.OrderDirection_ORDER_DIRECTION_SELL
+	rejected := investapi.OrderExecutionReportStatus_EXECUTION_REPORT_STATUS_REJECTED
+
+	// A resting buy order holds the money for its lots
+	if status := post("1", 6, 99, buy); status == rejected {
+		t.Fatalf("first buy order is rejected")
+	}
+	if status := post("2", 5, 99, buy); status != rejected {
+		t.Fatalf("buy order beyond the money left is %v, want rejected", status)
+	}
+	// Cancelling releases it
+	if err := venue.CancelOrder(accountId, "1"); err != nil {
+		t.Fatalf("CancelOrder() error = %v", err)
+	}
+	if status := post("3", 5, 100.1, buy); status != investapi.OrderExecutionReportStatus_EXECUTION_REPORT_STATUS_FILL {
+		t.Fatalf("buy order after cancel is %v, want filled", status)
+	}
+
+	// A resting sell order holds the securities
+	if status := post("4", 3, 101, sell); status == rejected {
+		t.Fatalf("first sell order is rejected")
+	}
+	if status := post("5", 3, 101, sell); status != rejected {
+		t.Fatalf("sell order beyond the securities left is %v, want rejected", status)
+	}
+	// Filling releases it
+	venue.UpdateOrderBook(newOrderBook([]float64{101.2, 10}, []float64{101.1, 10}))
+	if status := post("6", 2, 101, sell); status != investapi.OrderExecutionReportStatus_EXECUTION_REPORT_STATUS_FILL {
+		t.Fatalf("sell order after fill is %v, want filled", status)
+	}
+}
@@ -0,0 +1,116 @@
+package position
+
+import (
+	"math"
+	"tinkoff-invest-contest/internal/client/investapi"
+)
+
+// Position keeps track of holdings (in instrument units) and profit of a single instrument
+type Position struct {
+	quantity    int64 // negative for short position
+	avgPrice    float64
+	realizedPnL float64
+}
+
+// Apply accounts a fill of given quantity at given price, fee is charged into realized PnL
+func (p *Position) Apply(direction investapi.OrderDirection, quantity int64, price float64, fee float64) {
+	if quantity <= 0 {
+		return
+	}
+	signedQuantity := quantity
+	if direction == investapi.OrderDirection_ORDER_DIRECTION_SELL {
+		signedQuantity = -quantity
+	}
+	p.realizedPnL -= float64(quantity) * price * fee
+
+	if p.quantity == 0 || (p.quantity > 0) == (signedQuantity > 0) {
+		// Opening or increasing the position
+		held := math.Abs(float64(p.quantity))
+		p.avgPrice = (p.avgPrice*held + price*float64(quantity)) / (held + float64(quantity))
+		p.quantity += signedQuantity
+		return
+	}
+
+	// Reducing, closing or reversing the position
+	closed := quantity
+	if held := int64(math.Abs(float64(p.quantity))); held < closed {
+		closed = held
+	}
+	if p.quantity > 0 {
+		p.realizedPnL += float64(closed) * (price - p.avgPrice)
+	} else {
+		p.realizedPnL += float64(closed) * (p.avgPrice - price)
+	}
+	p.quantity += signedQuantity
+	switch {
+	case p.quantity == 0:
+		p.avgPrice = 0
+	case closed < quantity:
+		// Reversed, the remainder is opened at fill price
+		p.avgPrice = price
+	}
+}
+
+// UnrealizedPnL returns the profit of the open position if it was closed at given price
+func (p *Position) UnrealizedPnL(price float64) float64 {
+	return float64(p.quantity) * (price - p.avgPrice)
+}
+
+func (p *Position) GetQuantity() int64 {
+	return p.quantity
+}
+
+func (p *Position) GetAvgPrice() float64 {
+	return p.avgPrice
+}
+
+func (p *Position) GetRealizedPnL() float64 {
+	return p.realizedPnL
+}
+
+// Reset replaces the open position with the given one, e.g. to adopt the broker's state.
+// Realized PnL is kept
+func (p *Position) Reset(quantity int64, avgPrice float64) {
+	p.quantity = quantity
+	p.avgPrice = avgPrice
+	if quantity == 0 {
+		p.avgPrice = 0
+	}
+}
+
+// Snapshot is the state of a position valued at some price
+type Snapshot struct {
+	Quantity      int64   `json:"quantity"`
+	AvgPrice      float64 `json:"avgPrice"`
+	Price         float64 `json:"price"`
+	RealizedPnL   float64 `json:"realizedPnL"`
+	UnrealizedPnL float64 `json:"unrealizedPnL"`
+}
+
+// Snapshot returns the state of the position valued at given price
+func (p *Position) Snapshot(price float64) Snapshot {
+	return Snapshot{
+		Quantity:      p.quantity,
+		AvgPrice:      p.avgPrice,
+		Price:         price,
+		RealizedPnL:   p.realizedPnL,
+		UnrealizedPnL: p.UnrealizedPnL(price),
+	}
+}
+
+// Totals sums up profit of several positions in the same currency
+type Totals struct {
+	RealizedPnL   float64 `json:"realizedPnL"`
+	UnrealizedPnL float64 `json:"unrealizedPnL"`
+	PnL           float64 `json:"pnl"`
+	OpenPositions int     `json:"openPositions"`
+}
+
+func (t *Totals) Add(snapshot Snapshot) {
+	t.RealizedPnL += snapshot.RealizedPnL
+	t.UnrealizedPnL += snapshot.UnrealizedPnL
+	t.PnL = t.RealizedPnL + t.UnrealizedPnL
+	if snapshot.Quantity != 0 {
+		t.OpenPositions++
+	}
+}
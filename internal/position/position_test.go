@@ -0,0 +1,141 @@
+package position
+
+import (
+	"math"
+	"testing"
+	"tinkoff-invest-contest/internal/client/investapi"
+)
+
+func TestPosition_Apply(t *testing.T) {
+	type fill struct {
+		direction investapi.OrderDirection
+		quantity  int64
+		price     float64
+	}
+	tests := []struct {
+		name            string
+		fills           []fill
+		fee             float64
+		wantQuantity    int64
+		wantAvgPrice    float64
+		wantRealizedPnL float64
+	}{
+		{
+			name: "test1",
+			fills: []fill{
+				{investapi.OrderDirection_ORDER_DIRECTION_BUY, 10, 100},
+				{investapi.OrderDirection_ORDER_DIRECTION_BUY, 10, 110},
+			},
+			wantQuantity: 20,
+			wantAvgPrice: 105,
+		},
+		{
+			name: "test2",
+			fills: []fill{
+				{investapi.OrderDirection_ORDER_DIRECTION_BUY, 10, 100},
+				{investapi.OrderDirection_ORDER_DIRECTION_SELL, 10, 110},
+			},
+			fee:             0.001,
+			wantQuantity:    0,
+			wantAvgPrice:    0,
+			wantRealizedPnL: 100 - 1 - 1.1,
+		},
+		{
+			name: "test3",
+			fills: []fill{
+				{investapi.OrderDirection_ORDER_DIRECTION_SELL, 5, 200},
+				{investapi.OrderDirection_ORDER_DIRECTION_BUY, 8, 190},
+			},
+			wantQuantity:    3,
+			wantAvgPrice:    190,
+			wantRealizedPnL: 50,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := &Position{}
+			for _, f := range tt.fills {
+				p.Apply(f.direction, f.quantity, f.price, tt.fee)
+			}
+			if p.quantity != tt.wantQuantity {
+				t.Errorf("Apply() quantity = %v, want %v", p.quantity, tt.wantQuantity)
+			}
+			if math.Abs(p.avgPrice-tt.wantAvgPrice) > 1e-9 {
+				t.Errorf("Apply() avgPrice = %v, want %v", p.avgPrice, tt.wantAvgPrice)
+			}
+			if math.Abs(p.realizedPnL-tt.wantRealizedPnL) > 1e-9 {
+				t.Errorf("Apply() realizedPnL = %v, want %v", p.realizedPnL, tt.wantRealizedPnL)
+			}
+		})
+	}
+}
+
+func TestPosition_Reset(t *testing.T) {
+	type args struct {
+		quantity int64
+		avgPrice float64
+	}
+	tests := []struct {
+		name         string
+		args         args
+		wantAvgPrice float64
+	}{
+		{
+			name:         "test1",
+			args:         args{quantity: -4, avgPrice: 120},
+			wantAvgPrice: 120,
+		},
+		{
+			name:         "test2",
+			args:         args{quantity: 0, avgPrice: 120},
+			wantAvgPrice: 0,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := &Position{}
+			p.Apply(investapi.OrderDirection_ORDER_DIRECTION_BUY, 10, 100, 0)
+			p.Apply(investapi.OrderDirection_ORDER_DIRECTION_SELL, 5, 110, 0)
+			p.Reset(tt.args.quantity, tt.args.avgPrice)
+			if p.quantity != tt.args.quantity || p.avgPrice != tt.wantAvgPrice {
+				t.Errorf("Reset() = %v @ %v, want %v @ %v", p.quantity, p.avgPrice, tt.args.quantity, tt.wantAvgPrice)
+			}
+			if p.realizedPnL != 50 {
+				t.Errorf("Reset() realizedPnL = %v, want 50", p.realizedPnL)
+			}
+		})
+	}
+}
+
+func TestTotals_Add(t *testing.T) {
+	tests := []struct {
+		name      string
+		snapshots []Snapshot
+		want      Totals
+	}{
+		{
+			name: "test1",
+			snapshots: []Snapshot{
+				{Quantity: 10, RealizedPnL: 5, UnrealizedPnL: -2},
+				{Quantity: 0, RealizedPnL: -1},
+				{Quantity: -3, UnrealizedPnL: 4},
+			},
+			want: Totals{RealizedPnL: 4, UnrealizedPnL: 2, PnL: 6, OpenPositions: 2},
+		},
+		{
+			name: "test2",
+			want: Totals{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got Totals
+			for _, snapshot := range tt.snapshots {
+				got.Add(snapshot)
+			}
+			if got != tt.want {
+				t.Errorf("Add() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,307 @@
+package recording
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"google.golang.org/protobuf/proto"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"tinkoff-invest-contest/internal/client/investapi"
+)
+
+const (
+	fileExtension = ".mdr.gz"
+	// Each hour of the stream goes to its own file
+	filePeriod    = time.Hour
+	flushInterval = 10 * time.Second
+)
+
+// Event is a market data stream event and the time it was received at
+type Event struct {
+	ReceivedAt time.Time
+	Data       *investapi.MarketDataResponse
+}
+
+// IsRecorded tells whether the event carries market data: a candle, an order book, a trade or a trading status
+func IsRecorded(event *investapi.MarketDataResponse) bool {
+	switch event.GetPayload().(type) {
+	case *investapi.MarketDataResponse_Candle, *investapi.MarketDataResponse_Orderbook,
+		*investapi.MarketDataResponse_Trade, *investapi.MarketDataResponse_TradingStatus:
+		return true
+	}
+	return false
+}
+
+// Recorder writes market data events to gzip-compressed files in dir, a file per hour.
+// A record is the receive time in Unix nanoseconds and the length of the event, both as uvarints, and the event
+// in protobuf wire format
+type Recorder struct {
+	dir string
+
+	mu        sync.Mutex
+	period    time.Time
+	file      *os.File
+	gzip      *gzip.Writer
+	buf       *bufio.Writer
+	lastFlush time.Time
+	closed    bool
+}
+
+func NewRecorder(dir string) (*Recorder, error) {
+	err := os.MkdirAll(dir, 0o755)
+	if err != nil {
+		return nil, err
+	}
+	return &Recorder{dir: dir}, nil
+}
+
+// Record writes the event if it carries market data
+func (r *Recorder) Record(receivedAt time.Time, event *investapi.MarketDataResponse) error {
+	if !IsRecorded(event) {
+		return nil
+	}
+	data, err := proto.Marshal(event)
+	if err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.closed {
+		return errors.New("recorder is closed")
+	}
+	err = r.rotate(receivedAt)
+	if err != nil {
+		return err
+	}
+	header := make([]byte, 2*binary.MaxVarintLen64)
+	n := binary.PutUvarint(header, uint64(receivedAt.UnixNano()))
+	n += binary.PutUvarint(header[n:], uint64(len(data)))
+	_, err = r.buf.Write(header[:n])
+	if err == nil {
+		_, err = r.buf.Write(data)
+	}
+	if err != nil {
+		return err
+	}
+	if time.Since(r.lastFlush) > flushInterval {
+		return r.flush()
+	}
+	return nil
+}
+
+// rotate opens the file of the period t belongs to, closing the previous one
+func (r *Recorder) rotate(t time.Time) error {
+	period := t.UTC().Truncate(filePeriod)
+	if r.file != nil && !period.After(r.period) {
+		return nil
+	}
+	err := r.close()
+	if err != nil {
+		return err
+	}
+	// Appending to an existing file after a restart makes a multistream gzip, which is read as one stream
+	path := filepath.Join(r.dir, period.Format("20060102T15")+fileExtension)
+	r.file, err = os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	r.period = period
+	r.gzip = gzip.NewWriter(r.file)
+	r.buf = bufio.NewWriter(r.gzip)
+	r.lastFlush = time.Now()
+	return nil
+}
+
+func (r *Recorder) flush() error {
+	r.lastFlush = time.Now()
+	err := r.buf.Flush()
+	if err != nil {
+		return err
+	}
+	return r.gzip.Flush()
+}
+
+func (r *Recorder) close() error {
+	if r.file == nil {
+		return nil
+	}
+	err := r.buf.Flush()
+	if err == nil {
+		err = r.gzip.Close()
+	}
+	closeErr := r.file.Close()
+	r.file, r.gzip, r.buf = nil, nil, nil
+	if err != nil {
+		return err
+	}
+	return closeErr
+}
+
+// Close flushes and closes the current file, events recorded afterwards are rejected
+func (r *Recorder) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.closed = true
+	return r.close()
+}
+
+// List returns paths of the recordings in dir in chronological order
+func List(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var paths []string
+	for _, entry := range entries {
+		if !entry.IsDir() && strings.HasSuffix(entry.Name(), fileExtension) {
+			paths = append(paths, filepath.Join(dir, entry.Name()))
+		}
+	}
+	sort.Strings(paths)
+	return paths, nil
+}
+
+// Reader reads events from recordings one after another
+type Reader struct {
+	paths []string
+	file  *os.File
+	gzip  *gzip.Reader
+	buf   *bufio.Reader
+}
+
+// Open opens recordings to be read in the given order
+func Open(paths ...string) *Reader {
+	return &Reader{paths: paths}
+}
+
+// Next returns the next event, io.EOF after the last one
+func (r *Reader) Next() (Event, error) {
+	for {
+		if r.buf == nil {
+			if len(r.paths) == 0 {
+				return Event{}, io.EOF
+			}
+			err := r.openNext()
+			if err != nil {
+				return Event{}, err
+			}
+		}
+		event, err := r.read()
+		if err == io.EOF {
+			err = r.Close()
+			if err != nil {
+				return Event{}, err
+			}
+			continue
+		}
+		if err != nil {
+			return Event{}, fmt.Errorf("%v: %w", r.file.Name(), err)
+		}
+		return event, nil
+	}
+}
+
+func (r *Reader) openNext() error {
+	var err error
+	r.file, err = os.Open(r.paths[0])
+	if err != nil {
+		return err
+	}
+	r.paths = r.paths[1:]
+	r.gzip, err = gzip.NewReader(r.file)
+	if err != nil {
+		_ = r.file.Close()
+		return fmt.Errorf("%v: %w", r.file.Name(), err)
+	}
+	r.buf = bufio.NewReader(r.gzip)
+	return nil
+}
+
+func (r *Reader) read() (Event, error) {
+	receivedAt, err := binary.ReadUvarint(r.buf)
+	if err == io.ErrUnexpectedEOF {
+		// The file is still being written, its gzip stream isn't closed yet
+		return Event{}, io.EOF
+	}
+	if err != nil {
+		return Event{}, err
+	}
+	length, err := binary.ReadUvarint(r.buf)
+	if err != nil {
+		return Event{}, unexpectedEOF(err)
+	}
+	data := make([]byte, length)
+	_, err = io.ReadFull(r.buf, data)
+	if err != nil {
+		return Event{}, unexpectedEOF(err)
+	}
+	event := Event{
+		ReceivedAt: time.Unix(0, int64(receivedAt)),
+		Data:       &investapi.MarketDataResponse{},
+	}
+	return event, proto.Unmarshal(data, event.Data)
+}
+
+// unexpectedEOF tells a truncated record from the end of a file
+func unexpectedEOF(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+// Close closes the file being read, the next one is opened by Next
+func (r *Reader) Close() error {
+	if r.file == nil {
+		return nil
+	}
+	err := r.file.Close()
+	r.file, r.gzip, r.buf = nil, nil, nil
+	return err
+}
+
+// Replay passes events to handle in the order they were recorded, which is deterministic for the same recordings.
+// Gaps between events are kept divided by speed, zero speed replays as fast as possible.
+// Returns the number of events replayed
+func Replay(ctx context.Context, reader *Reader, speed float64, handle func(Event)) (int, error) {
+	defer reader.Close()
+	var prev time.Time
+	n := 0
+	for ctx.Err() == nil {
+		event, err := reader.Next()
+		if errors.Is(err, io.EOF) {
+			return n, nil
+		}
+		if err != nil {
+			return n, err
+		}
+		if d := delay(prev, event.ReceivedAt, speed); d > 0 {
+			select {
+			case <-time.After(d):
+			case <-ctx.Done():
+				return n, ctx.Err()
+			}
+		}
+		prev = event.ReceivedAt
+		handle(event)
+		n++
+	}
+	return n, ctx.Err()
+}
+
+// delay returns how long to wait before replaying an event received at next, the previous one having been received at prev
+func delay(prev time.Time, next time.Time, speed float64) time.Duration {
+	if speed <= 0 || prev.IsZero() || !next.After(prev) {
+		return 0
+	}
+	return time.Duration(float64(next.Sub(prev)) / speed)
+}
@@ -0,0 +1,94 @@
+package recording
+
+import (
+	"context"
+	"testing"
+	"time"
+	"tinkoff-invest-contest/internal/client/investapi"
+)
+
+func TestRecorder(t *testing.T) {
+	dir := t.TempDir()
+	recorder, err := NewRecorder(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := time.Date(2022, 5, 25, 10, 59, 59, 0, time.UTC)
+	events := []*investapi.MarketDataResponse{
+		{Payload: &investapi.MarketDataResponse_Candle{Candle: &investapi.Candle{Figi: "A", Volume: 1}}},
+		{Payload: &investapi.MarketDataResponse_Ping{Ping: &investapi.Ping{}}},
+		{Payload: &investapi.MarketDataResponse_Orderbook{Orderbook: &investapi.OrderBook{Figi: "A", Depth: 10}}},
+		{Payload: &investapi.MarketDataResponse_Trade{Trade: &investapi.Trade{Figi: "A", Quantity: 3}}},
+		{Payload: &investapi.MarketDataResponse_TradingStatus{TradingStatus: &investapi.TradingStatus{Figi: "A"}}},
+	}
+	for i, event := range events {
+		err = recorder.Record(start.Add(time.Duration(i)*time.Second), event)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	err = recorder.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	paths, err := List(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The second event goes to the next hour's file
+	if len(paths) != 2 {
+		t.Fatalf("List() = %v, want 2 files", paths)
+	}
+	var got []Event
+	n, err := Replay(context.Background(), Open(paths...), 0, func(event Event) {
+		got = append(got, event)
+	})
+	if err != nil || n != 4 {
+		t.Fatalf("Replay() = %v, %v, want 4 events", n, err)
+	}
+	wantTypes := []string{"candle", "orderbook", "trade", "trading_status"}
+	for i, event := range got {
+		var gotType string
+		switch event.Data.Payload.(type) {
+		case *investapi.MarketDataResponse_Candle:
+			gotType = "candle"
+		case *investapi.MarketDataResponse_Orderbook:
+			gotType = "orderbook"
+		case *investapi.MarketDataResponse_Trade:
+			gotType = "trade"
+		case *investapi.MarketDataResponse_TradingStatus:
+			gotType = "trading_status"
+		}
+		if gotType != wantTypes[i] {
+			t.Errorf("event %v is %v, want %v", i, gotType, wantTypes[i])
+		}
+	}
+	if !got[0].ReceivedAt.Equal(start) || got[2].Data.GetTrade().Quantity != 3 {
+		t.Errorf("events don't match the recorded ones: %v", got)
+	}
+}
+
+func Test_delay(t *testing.T) {
+	prev := time.Date(2022, 5, 25, 10, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name  string
+		prev  time.Time
+		next  time.Time
+		speed float64
+		want  time.Duration
+	}{
+		{name: "test1", prev: prev, next: prev.Add(time.Second), speed: 1, want: time.Second},
+		{name: "test2", prev: prev, next: prev.Add(time.Second), speed: 10, want: 100 * time.Millisecond},
+		{name: "test3", prev: prev, next: prev.Add(time.Second), speed: 0, want: 0},
+		{name: "test4", next: prev, speed: 1, want: 0},
+		{name: "test5", prev: prev, next: prev.Add(-time.Second), speed: 1, want: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := delay(tt.prev, tt.next, tt.speed); got != tt.want {
+				t.Errorf("delay() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
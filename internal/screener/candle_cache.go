@@ -0,0 +1,48 @@
+package screener
+
+import (
+	"sync"
+	"time"
+	"tinkoff-invest-contest/internal/client/investapi"
+)
+
+type cachedCandles struct {
+	candles   []*investapi.HistoricCandle
+	from      time.Time
+	fetchedAt time.Time
+}
+
+// CandleCache keeps daily candles of instruments for ttl, so that repeated screenings don't hit API rate limits
+type CandleCache struct {
+	mu         sync.Mutex
+	marketData MarketData
+	ttl        time.Duration
+	candles    map[string]cachedCandles
+}
+
+func NewCandleCache(marketData MarketData, ttl time.Duration) *CandleCache {
+	return &CandleCache{
+		marketData: marketData,
+		ttl:        ttl,
+		candles:    make(map[string]cachedCandles),
+	}
+}
+
+// DailyCandles returns daily candles covering at least the given number of trading days before now
+func (c *CandleCache) DailyCandles(figi string, days int, now time.Time) ([]*investapi.HistoricCandle, error) {
+	c.mu.Lock()
+	cached, ok := c.candles[figi]
+	c.mu.Unlock()
+	from := now.AddDate(0, 0, -days*calendarDaysPerTradingDay)
+	if ok && now.Sub(cached.fetchedAt) < c.ttl && !cached.from.After(from) {
+		return cached.candles, nil
+	}
+	candles, err := c.marketData.GetCandles(figi, from, now, investapi.CandleInterval_CANDLE_INTERVAL_DAY)
+	if err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	c.candles[figi] = cachedCandles{candles: candles, from: from, fetchedAt: now}
+	c.mu.Unlock()
+	return candles, nil
+}
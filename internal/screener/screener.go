@@ -0,0 +1,375 @@
+package screener
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"tinkoff-invest-contest/internal/catalog"
+	"tinkoff-invest-contest/internal/client/investapi"
+	indicators "tinkoff-invest-contest/internal/technical_indicators"
+	"tinkoff-invest-contest/internal/utils"
+)
+
+const (
+	// MaxCandidates limits the number of instruments market data is requested for, so that screening
+	// fits in the API rate limits
+	MaxCandidates = 300
+
+	defaultDays = 20
+	// Candles are requested for a longer span of calendar days, since there are no candles on weekends and holidays
+	calendarDaysPerTradingDay = 2
+	maxCalendarDays           = 365
+
+	workers = 4
+)
+
+// MarketData provides market data of instruments, see client.Client
+type MarketData interface {
+	GetCandles(figi string, from time.Time, to time.Time, interval investapi.CandleInterval) ([]*investapi.HistoricCandle, error)
+	GetOrderBook(figi string, depth int32) (*investapi.GetOrderBookResponse, error)
+	GetTradingStatus(figi string) (*investapi.GetTradingStatusResponse, error)
+}
+
+// Filter selects instruments. Zero values don't filter
+type Filter struct {
+	Type     string
+	Exchange string
+	Currency string
+	Sector   string
+	MinLot   int32
+	MaxLot   int32
+	// Only instruments that can be sold short
+	ShortEnabled bool
+	// Only instruments that are available for trading through the API right now
+	Tradable bool
+	// Average daily turnover over the last Days days, in the instrument's currency
+	MinTurnover float64
+	// Average True Range over the last Days days as a percent of the last close
+	MinATRPercent float64
+	MaxATRPercent float64
+	// Spread between the best ask and bid, in basis points of the mid price
+	MaxSpreadBps float64
+	// Days to average turnover and ATR over
+	Days int
+}
+
+// SortKey is a field results are sorted by
+type SortKey string
+
+const (
+	SortByTicker   SortKey = "ticker"
+	SortByTurnover SortKey = "turnover"
+	SortByATR      SortKey = "atr"
+	SortBySpread   SortKey = "spread"
+)
+
+func StringToSortKey(s string) (SortKey, error) {
+	switch key := SortKey(s); key {
+	case "":
+		return SortByTicker, nil
+	case SortByTicker, SortByTurnover, SortByATR, SortBySpread:
+		return key, nil
+	}
+	return "", errors.New("unknown sort key: " + s)
+}
+
+// Result is an instrument that passed the filter. Spread and trading status are nil if they weren't requested
+type Result struct {
+	*catalog.Entry
+	Turnover      float64  `json:"turnover"`
+	ATRPercent    float64  `json:"atrPercent"`
+	SpreadBps     *float64 `json:"spreadBps"`
+	TradingStatus *string  `json:"tradingStatus"`
+	Tradable      *bool    `json:"tradable"`
+}
+
+// Screener filters instruments of the catalog by their static attributes and market data
+type Screener struct {
+	catalog    *catalog.Catalog
+	marketData MarketData
+	candles    *CandleCache
+	// Instruments matching the static filters beyond it aren't screened
+	maxCandidates int
+}
+
+func New(catalog *catalog.Catalog, marketData MarketData) *Screener {
+	return &Screener{
+		catalog:       catalog,
+		marketData:    marketData,
+		candles:       NewCandleCache(marketData, time.Hour),
+		maxCandidates: MaxCandidates,
+	}
+}
+
+// Screen returns at most limit instruments that pass the filter, sorted by the key.
+// Only the first MaxCandidates instruments by ticker that match the static filters are screened,
+// the number of the other ones is returned as well. Instruments market data couldn't be requested for are skipped
+func (s *Screener) Screen(filter Filter, sortKey SortKey, descending bool, limit int) (results []Result, notScreened int, err error) {
+	if filter.Days <= 0 {
+		filter.Days = defaultDays
+	}
+	if filter.Days*calendarDaysPerTradingDay > maxCalendarDays {
+		return nil, 0, fmt.Errorf("turnover and ATR can be averaged over %v days at most", maxCalendarDays/calendarDaysPerTradingDay)
+	}
+	var candidates []*catalog.Entry
+	for _, entry := range s.catalog.Entries() {
+		if filter.matches(entry) {
+			candidates = append(candidates, entry)
+		}
+	}
+	if len(candidates) > s.maxCandidates {
+		notScreened = len(candidates) - s.maxCandidates
+		candidates = candidates[:s.maxCandidates]
+	}
+
+	results = make([]Result, 0, len(candidates))
+	for _, entry := range candidates {
+		results = append(results, Result{Entry: entry})
+	}
+	results = s.forEachResult(results, func(result Result) (Result, bool, error) {
+		candles, err := s.candles.DailyCandles(result.Figi, filter.Days, time.Now())
+		if err != nil {
+			return result, false, err
+		}
+		result.Turnover, result.ATRPercent = CandleMetrics(candles, result.Lot, filter.Days)
+		return result, filter.matchesCandleMetrics(result), nil
+	})
+
+	// Live data is requested for all the instruments if it's filtered or sorted by, otherwise just for the ones returned
+	liveNeeded := filter.Tradable || filter.MaxSpreadBps > 0 || sortKey == SortBySpread
+	if liveNeeded {
+		results = s.forEachResult(results, func(result Result) (Result, bool, error) {
+			err := s.addLiveData(&result)
+			return result, filter.matchesLiveData(result), err
+		})
+	}
+	Sort(results, sortKey, descending)
+	if limit > 0 && len(results) > limit {
+		results = results[:limit]
+	}
+	if !liveNeeded {
+		// Results are shown without live data rather than skipped if it couldn't be requested
+		results = s.forEachResult(results, func(result Result) (Result, bool, error) {
+			err := s.addLiveData(&result)
+			if err != nil {
+				log.Printf("screener: no live data for %v (%v): %v", result.Ticker, result.Figi, err)
+			}
+			return result, true, nil
+		})
+	}
+	return results, notScreened, nil
+}
+
+func (filter Filter) matches(entry *catalog.Entry) bool {
+	switch {
+	case filter.Type != "" && !strings.EqualFold(entry.TypeName, filter.Type):
+		return false
+	case filter.Exchange != "" && !strings.EqualFold(entry.Exchange, filter.Exchange):
+		return false
+	case filter.Currency != "" && !strings.EqualFold(entry.Currency, filter.Currency):
+		return false
+	case filter.Sector != "" && !strings.EqualFold(entry.Sector, filter.Sector):
+		return false
+	case filter.MinLot > 0 && entry.Lot < filter.MinLot:
+		return false
+	case filter.MaxLot > 0 && entry.Lot > filter.MaxLot:
+		return false
+	case filter.ShortEnabled && !entry.ShortEnabled:
+		return false
+	case filter.Tradable && !entry.ApiTradeAvailable:
+		return false
+	}
+	return true
+}
+
+func (filter Filter) matchesCandleMetrics(result Result) bool {
+	switch {
+	case filter.MinTurnover > 0 && result.Turnover < filter.MinTurnover:
+		return false
+	case filter.MinATRPercent > 0 && result.ATRPercent < filter.MinATRPercent:
+		return false
+	case filter.MaxATRPercent > 0 && result.ATRPercent > filter.MaxATRPercent:
+		return false
+	}
+	return true
+}
+
+func (filter Filter) matchesLiveData(result Result) bool {
+	switch {
+	case filter.Tradable && (result.Tradable == nil || !*result.Tradable):
+		return false
+	case filter.MaxSpreadBps > 0 && (result.SpreadBps == nil || *result.SpreadBps > filter.MaxSpreadBps):
+		return false
+	}
+	return true
+}
+
+// addLiveData requests the spread and the trading status of the instrument
+func (s *Screener) addLiveData(result *Result) error {
+	orderBook, err := s.marketData.GetOrderBook(result.Figi, 1)
+	if err != nil {
+		return err
+	}
+	if len(orderBook.Bids) > 0 && len(orderBook.Asks) > 0 {
+		spread := SpreadBps(utils.QuotationToFloat(orderBook.Bids[0].Price), utils.QuotationToFloat(orderBook.Asks[0].Price))
+		result.SpreadBps = &spread
+	}
+	tradingStatus, err := s.marketData.GetTradingStatus(result.Figi)
+	if err != nil {
+		return err
+	}
+	status := strings.ToLower(strings.TrimPrefix(tradingStatus.TradingStatus.String(), "SECURITY_TRADING_STATUS_"))
+	tradable := tradingStatus.TradingStatus == investapi.SecurityTradingStatus_SECURITY_TRADING_STATUS_NORMAL_TRADING &&
+		tradingStatus.ApiTradeAvailableFlag &&
+		(tradingStatus.MarketOrderAvailableFlag || tradingStatus.LimitOrderAvailableFlag)
+	result.TradingStatus = &status
+	result.Tradable = &tradable
+	return nil
+}
+
+// forEachResult applies f to the results concurrently, keeping the order and the results f accepts
+func (s *Screener) forEachResult(results []Result, f func(result Result) (Result, bool, error)) []Result {
+	accepted := make([]bool, len(results))
+	newResults := make([]Result, len(results))
+	indexes := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				result, ok, err := f(results[i])
+				if err != nil {
+					log.Printf("screener: skipping %v (%v): %v", results[i].Ticker, results[i].Figi, err)
+					continue
+				}
+				newResults[i], accepted[i] = result, ok
+			}
+		}()
+	}
+	for i := range results {
+		indexes <- i
+	}
+	close(indexes)
+	wg.Wait()
+
+	filtered := newResults[:0]
+	for i, result := range newResults {
+		if accepted[i] {
+			filtered = append(filtered, result)
+		}
+	}
+	return filtered
+}
+
+// CandleMetrics returns average daily turnover and ATR as a percent of the last close over the last days
+// complete daily candles
+func CandleMetrics(candles []*investapi.HistoricCandle, lot int32, days int) (turnover float64, atrPercent float64) {
+	complete := make([]*investapi.HistoricCandle, 0, len(candles))
+	for _, candle := range candles {
+		if candle.IsComplete {
+			complete = append(complete, candle)
+		}
+	}
+	if len(complete) > days {
+		complete = complete[len(complete)-days:]
+	}
+	if len(complete) == 0 {
+		return 0, 0
+	}
+	for _, candle := range complete {
+		turnover += utils.QuotationToFloat(candle.Close) * float64(candle.Volume) * float64(lot)
+	}
+	turnover /= float64(len(complete))
+	lastClose := utils.QuotationToFloat(complete[len(complete)-1].Close)
+	if lastClose > 0 {
+		atrPercent = indicators.NewATR(days).Calculate(complete) / lastClose * 100
+	}
+	return turnover, atrPercent
+}
+
+// SpreadBps returns the spread in basis points of the mid price
+func SpreadBps(bid float64, ask float64) float64 {
+	mid := (bid + ask) / 2
+	if mid <= 0 {
+		return 0
+	}
+	return (ask - bid) / mid * 10000
+}
+
+// Sort sorts results by the key, results without a spread go last when sorted by spread
+func Sort(results []Result, sortKey SortKey, descending bool) {
+	less := func(a, b Result) bool {
+		switch sortKey {
+		case SortByTurnover:
+			return a.Turnover < b.Turnover
+		case SortByATR:
+			return a.ATRPercent < b.ATRPercent
+		case SortBySpread:
+			return *a.SpreadBps < *b.SpreadBps
+		}
+		return a.Ticker < b.Ticker
+	}
+	sort.SliceStable(results, func(i, j int) bool {
+		a, b := results[i], results[j]
+		if sortKey == SortBySpread && (a.SpreadBps == nil || b.SpreadBps == nil) {
+			return a.SpreadBps != nil && b.SpreadBps == nil
+		}
+		if descending {
+			return less(b, a)
+		}
+		return less(a, b)
+	})
+}
+
+// WriteCSV exports the results
+func WriteCSV(w io.Writer, results []Result) error {
+	writer := csv.NewWriter(w)
+	err := writer.Write([]string{"figi", "ticker", "class_code", "isin", "name", "type", "exchange", "sector", "currency",
+		"lot", "short_enabled", "turnover", "atr_percent", "spread_bps", "trading_status", "tradable"})
+	if err != nil {
+		return err
+	}
+	for _, result := range results {
+		var spread, tradingStatus, tradable string
+		if result.SpreadBps != nil {
+			spread = strconv.FormatFloat(*result.SpreadBps, 'f', 2, 64)
+		}
+		if result.TradingStatus != nil {
+			tradingStatus = *result.TradingStatus
+		}
+		if result.Tradable != nil {
+			tradable = strconv.FormatBool(*result.Tradable)
+		}
+		err = writer.Write([]string{
+			result.Figi,
+			result.Ticker,
+			result.ClassCode,
+			result.Isin,
+			result.Name,
+			result.TypeName,
+			result.Exchange,
+			result.Sector,
+			result.Currency,
+			strconv.Itoa(int(result.Lot)),
+			strconv.FormatBool(result.ShortEnabled),
+			strconv.FormatFloat(result.Turnover, 'f', 2, 64),
+			strconv.FormatFloat(result.ATRPercent, 'f', 4, 64),
+			spread,
+			tradingStatus,
+			tradable,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
@@ -0,0 +1,272 @@
+package screener
+
+import (
+	"bytes"
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+	"tinkoff-invest-contest/internal/catalog"
+	"tinkoff-invest-contest/internal/client/investapi"
+	"tinkoff-invest-contest/internal/utils"
+)
+
+const testSnapshot = `{
+	"shares": [
+		{"figi": "F1", "ticker": "AAA", "classCode": "TQBR", "name": "A", "currency": "rub", "lot": 10, "exchange": "MOEX", "sector": "it", "shortEnabledFlag": true, "apiTradeAvailableFlag": true},
+		{"figi": "F2", "ticker": "BBB", "classCode": "TQBR", "name": "B", "currency": "rub", "lot": 1, "exchange": "MOEX", "sector": "energy", "apiTradeAvailableFlag": true},
+		{"figi": "F3", "ticker": "CCC", "classCode": "SPBXM", "name": "C", "currency": "usd", "lot": 1, "exchange": "SPB", "sector": "it", "shortEnabledFlag": true, "apiTradeAvailableFlag": true}
+	]
+}`
+
+type fakeMarketData struct {
+	// Close price and volume of every daily candle, high and low are 1% away from close
+	closes  map[string]float64
+	volumes map[string]int64
+	spreads map[string]float64
+	halted  map[string]bool
+
+	candleRequests int
+}
+
+func (f *fakeMarketData) GetCandles(figi string, from time.Time, to time.Time,
+	interval investapi.CandleInterval) ([]*investapi.HistoricCandle, error) {
+	f.candleRequests++
+	var candles []*investapi.HistoricCandle
+	for i := 0; i < 5; i++ {
+		closePrice := f.closes[figi]
+		candles = append(candles, &investapi.HistoricCandle{
+			High:       utils.FloatToQuotation(closePrice * 1.01),
+			Low:        utils.FloatToQuotation(closePrice * 0.99),
+			Close:      utils.FloatToQuotation(closePrice),
+			Volume:     f.volumes[figi],
+			IsComplete: true,
+		})
+	}
+	return candles, nil
+}
+
+func (f *fakeMarketData) GetOrderBook(figi string, depth int32) (*investapi.GetOrderBookResponse, error) {
+	closePrice := f.closes[figi]
+	return &investapi.GetOrderBookResponse{
+		Bids: []*investapi.Order{{Price: utils.FloatToQuotation(closePrice)}},
+		Asks: []*investapi.Order{{Price: utils.FloatToQuotation(closePrice * (1 + f.spreads[figi]))}},
+	}, nil
+}
+
+func (f *fakeMarketData) GetTradingStatus(figi string) (*investapi.GetTradingStatusResponse, error) {
+	status := investapi.SecurityTradingStatus_SECURITY_TRADING_STATUS_NORMAL_TRADING
+	if f.halted[figi] {
+		status = investapi.SecurityTradingStatus_SECURITY_TRADING_STATUS_NOT_AVAILABLE_FOR_TRADING
+	}
+	return &investapi.GetTradingStatusResponse{
+		Figi:                     figi,
+		TradingStatus:            status,
+		ApiTradeAvailableFlag:    true,
+		MarketOrderAvailableFlag: true,
+	}, nil
+}
+
+func newTestScreener(t *testing.T) (*Screener, *fakeMarketData) {
+	path := filepath.Join(t.TempDir(), "instruments.json")
+	if err := os.WriteFile(path, []byte(testSnapshot), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	c := catalog.New()
+	if err := c.LoadSnapshot(path); err != nil {
+		t.Fatal(err)
+	}
+	marketData := &fakeMarketData{
+		// Turnovers are 100000, 50000 and 900000
+		closes:  map[string]float64{"F1": 100, "F2": 50, "F3": 300},
+		volumes: map[string]int64{"F1": 100, "F2": 1000, "F3": 3000},
+		spreads: map[string]float64{"F1": 0.001, "F2": 0.0001, "F3": 0.01},
+		halted:  map[string]bool{"F2": true},
+	}
+	return New(c, marketData), marketData
+}
+
+func TestScreener_Screen(t *testing.T) {
+	s, _ := newTestScreener(t)
+	tests := []struct {
+		name       string
+		filter     Filter
+		sortKey    SortKey
+		descending bool
+		limit      int
+		want       []string
+	}{
+		{
+			name:    "test1",
+			sortKey: SortByTicker,
+			want:    []string{"AAA", "BBB", "CCC"},
+		},
+		{
+			name:    "test2",
+			filter:  Filter{Exchange: "moex"},
+			sortKey: SortByTurnover,
+			want:    []string{"BBB", "AAA"},
+		},
+		{
+			name:       "test3",
+			sortKey:    SortByTurnover,
+			descending: true,
+			limit:      2,
+			want:       []string{"CCC", "AAA"},
+		},
+		{
+			name:    "test4",
+			filter:  Filter{ShortEnabled: true, Sector: "IT", MinTurnover: 200000},
+			sortKey: SortByTicker,
+			want:    []string{"CCC"},
+		},
+		{
+			name:    "test5",
+			filter:  Filter{Tradable: true},
+			sortKey: SortBySpread,
+			want:    []string{"AAA", "CCC"},
+		},
+		{
+			name:    "test6",
+			filter:  Filter{MaxSpreadBps: 50, MinLot: 1, MaxLot: 5},
+			sortKey: SortByTicker,
+			want:    []string{"BBB"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			results, notScreened, err := s.Screen(tt.filter, tt.sortKey, tt.descending, tt.limit)
+			if err != nil {
+				t.Fatalf("Screen() error = %v", err)
+			}
+			if notScreened != 0 {
+				t.Errorf("Screen() left %v instruments not screened, want 0", notScreened)
+			}
+			var got []string
+			for _, result := range results {
+				got = append(got, result.Ticker)
+				if result.Tradable == nil || result.SpreadBps == nil {
+					t.Errorf("Screen() returned %v without live data", result.Ticker)
+				}
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Screen() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestScreener_Screen_CachesCandles(t *testing.T) {
+	s, marketData := newTestScreener(t)
+	for i := 0; i < 2; i++ {
+		if _, _, err := s.Screen(Filter{}, SortByTicker, false, 0); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if marketData.candleRequests != 3 {
+		t.Errorf("candles requested %v times, want 3", marketData.candleRequests)
+	}
+}
+
+func TestScreener_Screen_TooManyCandidates(t *testing.T) {
+	s, marketData := newTestScreener(t)
+	s.maxCandidates = 2
+	results, notScreened, err := s.Screen(Filter{}, SortByTurnover, true, 0)
+	if err != nil {
+		t.Fatalf("Screen() error = %v", err)
+	}
+	var got []string
+	for _, result := range results {
+		got = append(got, result.Ticker)
+	}
+	if want := []string{"AAA", "BBB"}; !reflect.DeepEqual(got, want) || notScreened != 1 {
+		t.Errorf("Screen() = %v, %v not screened, want %v, 1 not screened", got, notScreened, want)
+	}
+	if marketData.candleRequests != 2 {
+		t.Errorf("candles requested %v times, want 2", marketData.candleRequests)
+	}
+}
+
+func TestCandleMetrics(t *testing.T) {
+	candle := func(high, low, close float64, volume int64, complete bool) *investapi.HistoricCandle {
+		return &investapi.HistoricCandle{
+			High:       utils.FloatToQuotation(high),
+			Low:        utils.FloatToQuotation(low),
+			Close:      utils.FloatToQuotation(close),
+			Volume:     volume,
+			IsComplete: complete,
+		}
+	}
+	tests := []struct {
+		name           string
+		candles        []*investapi.HistoricCandle
+		lot            int32
+		days           int
+		wantTurnover   float64
+		wantATRPercent float64
+	}{
+		{
+			name: "test1",
+			candles: []*investapi.HistoricCandle{
+				candle(102, 98, 100, 10, true),
+				candle(103, 99, 100, 30, true),
+				candle(150, 50, 100, 1000, false),
+			},
+			lot:            10,
+			days:           20,
+			wantTurnover:   20000,
+			wantATRPercent: 4,
+		},
+		{
+			name: "test2",
+			candles: []*investapi.HistoricCandle{
+				candle(120, 80, 100, 1000, true),
+				candle(102, 98, 100, 10, true),
+			},
+			lot:            1,
+			days:           1,
+			wantTurnover:   1000,
+			wantATRPercent: 4,
+		},
+		{
+			name:    "test3",
+			candles: nil,
+			lot:     1,
+			days:    20,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			turnover, atrPercent := CandleMetrics(tt.candles, tt.lot, tt.days)
+			if math.Abs(turnover-tt.wantTurnover) > 1e-9 || math.Abs(atrPercent-tt.wantATRPercent) > 1e-9 {
+				t.Errorf("CandleMetrics() = %v, %v, want %v, %v", turnover, atrPercent, tt.wantTurnover, tt.wantATRPercent)
+			}
+		})
+	}
+}
+
+func TestWriteCSV(t *testing.T) {
+	spread := 12.5
+	tradable := true
+	status := "normal_trading"
+	results := []Result{{
+		Entry:         &catalog.Entry{Figi: "F1", Ticker: "AAA", Name: "A, Inc", Lot: 10, TypeName: "share"},
+		Turnover:      1000,
+		ATRPercent:    2,
+		SpreadBps:     &spread,
+		TradingStatus: &status,
+		Tradable:      &tradable,
+	}}
+	var buf bytes.Buffer
+	if err := WriteCSV(&buf, results); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	want := `F1,AAA,,,"A, Inc",share,,,,10,false,1000.00,2.0000,12.50,normal_trading,true`
+	if len(lines) != 2 || lines[1] != want {
+		t.Errorf("WriteCSV() = %q, want %q", lines, want)
+	}
+}
@@ -0,0 +1,196 @@
+package sizing
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"tinkoff-invest-contest/internal/client/investapi"
+	indicators "tinkoff-invest-contest/internal/technical_indicators"
+)
+
+// Model tells how many lots to open a position with
+type Model int
+
+const (
+	// ModelAll uses all the money available for the deal
+	ModelAll Model = iota
+	// ModelFixedLots always trades the same number of lots
+	ModelFixedLots
+	// ModelFixedMoney trades for a fixed amount of money
+	ModelFixedMoney
+	// ModelEquityPercent trades for a percentage of equity
+	ModelEquityPercent
+	// ModelVolatilityTarget sizes the position so that a move by one ATR changes its value by a share of equity
+	ModelVolatilityTarget
+	// ModelKelly trades for a Kelly fraction of equity, capped at a limit
+	ModelKelly
+	// ModelRiskPerTrade sizes the position so that hitting the stop loss costs a share of equity
+	ModelRiskPerTrade
+)
+
+func StringToModel(s string) (Model, error) {
+	switch s {
+	case "", "all":
+		return ModelAll, nil
+	case "fixed_lots":
+		return ModelFixedLots, nil
+	case "fixed_money":
+		return ModelFixedMoney, nil
+	case "equity_percent":
+		return ModelEquityPercent, nil
+	case "volatility":
+		return ModelVolatilityTarget, nil
+	case "kelly":
+		return ModelKelly, nil
+	case "risk":
+		return ModelRiskPerTrade, nil
+	}
+	return 0, errors.New("unknown sizing model: " + s)
+}
+
+func ModelToString(model Model) string {
+	switch model {
+	case ModelAll:
+		return "all"
+	case ModelFixedLots:
+		return "fixed_lots"
+	case ModelFixedMoney:
+		return "fixed_money"
+	case ModelEquityPercent:
+		return "equity_percent"
+	case ModelVolatilityTarget:
+		return "volatility"
+	case ModelKelly:
+		return "kelly"
+	case ModelRiskPerTrade:
+		return "risk"
+	}
+	return ""
+}
+
+type Config struct {
+	Model Model
+
+	FixedLots     int64
+	FixedMoney    float64
+	EquityPercent float64
+
+	// Share of equity a position may gain or lose on a move by one ATR
+	VolatilityTarget float64
+	ATRPeriod        int
+
+	// Kelly fraction is WinRate - (1 - WinRate) / WinLossRatio, but no more than KellyCap
+	KellyWinRate      float64
+	KellyWinLossRatio float64
+	KellyCap          float64
+
+	// Share of equity lost if the stop loss is hit
+	RiskPerTrade float64
+}
+
+func (config Config) Validate() error {
+	switch config.Model {
+	case ModelFixedLots:
+		if config.FixedLots < 1 {
+			return errors.New("fixed lots sizing needs at least one lot")
+		}
+	case ModelFixedMoney:
+		if config.FixedMoney <= 0 {
+			return errors.New("fixed money sizing needs a positive amount")
+		}
+	case ModelEquityPercent:
+		if config.EquityPercent <= 0 || config.EquityPercent > 100 {
+			return errors.New("equity percent sizing needs a percent in (0, 100]")
+		}
+	case ModelVolatilityTarget:
+		if config.VolatilityTarget <= 0 || config.ATRPeriod < 1 {
+			return errors.New("volatility sizing needs a positive target and ATR period")
+		}
+	case ModelKelly:
+		if config.KellyWinRate <= 0 || config.KellyWinRate >= 1 || config.KellyWinLossRatio <= 0 ||
+			config.KellyCap <= 0 || config.KellyCap > 1 {
+			return errors.New("kelly sizing needs a win rate in (0, 1), a positive win/loss ratio and a cap in (0, 1]")
+		}
+	case ModelRiskPerTrade:
+		if config.RiskPerTrade <= 0 || config.RiskPerTrade > 1 {
+			return errors.New("risk per trade sizing needs a share of equity in (0, 1]")
+		}
+	}
+	return nil
+}
+
+// NeedsEquity tells whether Input.Equity has to be provided
+func (config Config) NeedsEquity() bool {
+	switch config.Model {
+	case ModelEquityPercent, ModelVolatilityTarget, ModelKelly, ModelRiskPerTrade:
+		return true
+	}
+	return false
+}
+
+// Input describes the deal to size. Prices are per instrument unit
+type Input struct {
+	Price float64
+	Lot   int64
+	Fee   float64
+	// Lots the account can afford, the size never exceeds it
+	MaxLots int64
+	// Money of the account in the instrument's currency
+	Equity float64
+	// The latest candle is the current one
+	Candles          []*investapi.HistoricCandle
+	StopLossDistance float64
+}
+
+// Size returns the number of lots to trade and a human-readable explanation of how it's been calculated
+func (config Config) Size(input Input) (lots int64, description string) {
+	if input.Price <= 0 || input.Lot <= 0 {
+		return 0, "no price to size the position by"
+	}
+	lotCost := input.Price * float64(input.Lot) * (1 + input.Fee)
+	switch config.Model {
+	case ModelFixedLots:
+		lots = config.FixedLots
+		description = fmt.Sprintf("fixed %v lots", config.FixedLots)
+	case ModelFixedMoney:
+		lots = int64(config.FixedMoney / lotCost)
+		description = fmt.Sprintf("fixed %v of money", config.FixedMoney)
+	case ModelEquityPercent:
+		lots = int64(input.Equity * config.EquityPercent / 100 / lotCost)
+		description = fmt.Sprintf("%v%% of equity %v", config.EquityPercent, input.Equity)
+	case ModelVolatilityTarget:
+		atr := indicators.NewATR(config.ATRPeriod).Calculate(input.Candles)
+		if atr > 0 {
+			lots = int64(input.Equity * config.VolatilityTarget / atr / float64(input.Lot))
+		}
+		description = fmt.Sprintf("%v of equity %v per ATR(%v) = %.6g", config.VolatilityTarget, input.Equity, config.ATRPeriod, atr)
+	case ModelKelly:
+		fraction := KellyFraction(config.KellyWinRate, config.KellyWinLossRatio, config.KellyCap)
+		lots = int64(input.Equity * fraction / lotCost)
+		description = fmt.Sprintf("kelly fraction %.4g of equity %v", fraction, input.Equity)
+	case ModelRiskPerTrade:
+		// Fees are paid on both entry and exit
+		riskPerUnit := input.StopLossDistance + 2*input.Price*input.Fee
+		if riskPerUnit > 0 {
+			lots = int64(input.Equity * config.RiskPerTrade / riskPerUnit / float64(input.Lot))
+		}
+		description = fmt.Sprintf("risking %v of equity %v with stop loss %.6g away", config.RiskPerTrade, input.Equity, input.StopLossDistance)
+	default:
+		lots = input.MaxLots
+		description = "all available money"
+	}
+	if lots > input.MaxLots {
+		lots = input.MaxLots
+		description += fmt.Sprintf(", capped at %v affordable lots", input.MaxLots)
+	}
+	if lots < 0 {
+		lots = 0
+	}
+	return lots, description
+}
+
+// KellyFraction returns the share of equity to bet, from 0 to maxFraction
+func KellyFraction(winRate float64, winLossRatio float64, maxFraction float64) float64 {
+	fraction := winRate - (1-winRate)/winLossRatio
+	return math.Max(0, math.Min(fraction, maxFraction))
+}
@@ -0,0 +1,117 @@
+package sizing
+
+import (
+	"testing"
+	"tinkoff-invest-contest/internal/client/investapi"
+	"tinkoff-invest-contest/internal/utils"
+)
+
+func TestConfig_Size(t *testing.T) {
+	candles := []*investapi.HistoricCandle{
+		{High: utils.FloatToQuotation(102), Low: utils.FloatToQuotation(98), Close: utils.FloatToQuotation(100)},
+		{High: utils.FloatToQuotation(102), Low: utils.FloatToQuotation(98), Close: utils.FloatToQuotation(100)},
+	}
+	input := Input{
+		Price:            100,
+		Lot:              1,
+		MaxLots:          500,
+		Equity:           10000,
+		Candles:          candles,
+		StopLossDistance: 2,
+	}
+	tests := []struct {
+		name    string
+		config  Config
+		maxLots int64
+		want    int64
+	}{
+		{
+			name:   "test1",
+			config: Config{Model: ModelAll},
+			want:   500,
+		},
+		{
+			name:   "test2",
+			config: Config{Model: ModelFixedLots, FixedLots: 3},
+			want:   3,
+		},
+		{
+			name:   "test3",
+			config: Config{Model: ModelFixedMoney, FixedMoney: 1050},
+			want:   10,
+		},
+		{
+			name:   "test4",
+			config: Config{Model: ModelEquityPercent, EquityPercent: 25},
+			want:   25,
+		},
+		{
+			name:   "test5",
+			config: Config{Model: ModelVolatilityTarget, VolatilityTarget: 0.01, ATRPeriod: 14},
+			want:   25,
+		},
+		{
+			name:   "test6",
+			config: Config{Model: ModelKelly, KellyWinRate: 0.6, KellyWinLossRatio: 2, KellyCap: 0.2},
+			want:   20,
+		},
+		{
+			name:   "test7",
+			config: Config{Model: ModelRiskPerTrade, RiskPerTrade: 0.01},
+			want:   50,
+		},
+		{
+			name:    "test8",
+			config:  Config{Model: ModelFixedLots, FixedLots: 30},
+			maxLots: 7,
+			want:    7,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			in := input
+			if tt.maxLots > 0 {
+				in.MaxLots = tt.maxLots
+			}
+			if got, _ := tt.config.Size(in); got != tt.want {
+				t.Errorf("Size() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestKellyFraction(t *testing.T) {
+	type args struct {
+		winRate      float64
+		winLossRatio float64
+		maxFraction  float64
+	}
+	tests := []struct {
+		name string
+		args args
+		want float64
+	}{
+		{
+			name: "test1",
+			args: args{winRate: 0.5, winLossRatio: 2, maxFraction: 1},
+			want: 0.25,
+		},
+		{
+			name: "test2",
+			args: args{winRate: 0.3, winLossRatio: 1, maxFraction: 1},
+			want: 0,
+		},
+		{
+			name: "test3",
+			args: args{winRate: 0.8, winLossRatio: 3, maxFraction: 0.1},
+			want: 0.1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := KellyFraction(tt.args.winRate, tt.args.winLossRatio, tt.args.maxFraction); got != tt.want {
+				t.Errorf("KellyFraction() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
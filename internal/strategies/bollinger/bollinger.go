@@ -1,7 +1,6 @@
 package bollinger
 
 import (
-	"encoding/json"
 	"github.com/go-yaml/yaml"
 	"tinkoff-invest-contest/internal/client/investapi"
 	"tinkoff-invest-contest/internal/strategies"
@@ -19,17 +18,38 @@ type bollingerParams struct {
 	PointDeviation float64 `json:"pointDev" yaml:"PointDeviation"`
 }
 
+var schema = strategies.Schema{
+	{
+		Name:        "coef",
+		Type:        strategies.ParamTypeFloat,
+		Default:     3,
+		Min:         strategies.Bound(0),
+		MinExcluded: true,
+		Description: "Width of the bands in standard deviations",
+	},
+	{
+		Name:        "pointDev",
+		Type:        strategies.ParamTypeFloat,
+		Default:     0.0005,
+		Min:         strategies.Bound(0),
+		Max:         strategies.Bound(1),
+		MaxExcluded: true,
+		Description: "How close to a band price must be to trigger a signal, as a ratio of price",
+	},
+}
+
 func init() {
 	strategyName := "bollinger"
 	strategies.Names = append(strategies.Names, strategyName)
 	strategies.JSONConstructors[strategyName] = NewFromJSON
-	strategies.DefaultsJSON[strategyName] = GetDefaultsJSON
+	strategies.DefaultsJSON[strategyName] = schema.DefaultsJSON
+	strategies.Schemas[strategyName] = schema
 }
 
 func NewFromJSON(s string) (strategies.Strategy, error) {
 	p := bollingerParams{}
 
-	err := json.Unmarshal([]byte(s), &p)
+	err := schema.Unmarshal(s, &p)
 	if err != nil {
 		return nil, err
 	}
@@ -40,16 +60,6 @@ func NewFromJSON(s string) (strategies.Strategy, error) {
 	}, nil
 }
 
-func GetDefaultsJSON() string {
-	defaults := bollingerParams{
-		Coef:           3,
-		PointDeviation: 0.0005,
-	}
-	bytes, err := json.MarshalIndent(&defaults, "", "  ")
-	utils.MaybeCrash(err)
-	return string(bytes)
-}
-
 func (s *bollingerStrategy) GetTradeSignal(_ utils.InstrumentInterface, marketData strategies.MarketData,
 	_ strategies.OrdersConfig) (*strategies.TradeSignal, map[string]any) {
 	lowerBound, upperBound := s.indicator.Calculate(marketData.Candles)
@@ -88,14 +98,16 @@ func (*bollingerStrategy) GetOutputKeys() []string {
 	}
 }
 
-func (s *bollingerStrategy) GetYAML() string {
+func (s *bollingerStrategy) GetYAML() (string, error) {
 	obj := bollingerParams{
 		Coef:           s.indicator.GetCoef(),
 		PointDeviation: s.pointDeviation,
 	}
 	bytes, err := yaml.Marshal(obj)
-	utils.MaybeCrash(err)
-	return string(bytes)
+	if err != nil {
+		return "", err
+	}
+	return string(bytes), nil
 }
 
 func (*bollingerStrategy) GetName() string {
@@ -8,7 +8,6 @@ for specified amount of times in a row.
 package consecutive_ratio
 
 import (
-	"encoding/json"
 	"github.com/go-yaml/yaml"
 	"tinkoff-invest-contest/internal/client/investapi"
 	"tinkoff-invest-contest/internal/strategies"
@@ -28,17 +27,37 @@ type consecutiveRatioParams struct {
 	TimesRepeated int     `json:"timesRepeated" yaml:"TimesRepeated"`
 }
 
+var schema = strategies.Schema{
+	{
+		Name:        "ratio",
+		Type:        strategies.ParamTypeFloat,
+		Default:     0.8,
+		Min:         strategies.Bound(0),
+		Max:         strategies.Bound(1),
+		MinExcluded: true,
+		Description: "Share of bids or asks among all orders in the order book that triggers a signal",
+	},
+	{
+		Name:        "timesRepeated",
+		Type:        strategies.ParamTypeInt,
+		Default:     10,
+		Min:         strategies.Bound(1),
+		Description: "How many order book updates in a row the ratio must hold for",
+	},
+}
+
 func init() {
 	strategyName := "consecutive_ratio"
 	strategies.Names = append(strategies.Names, strategyName)
 	strategies.JSONConstructors[strategyName] = NewFromJSON
-	strategies.DefaultsJSON[strategyName] = GetDefaultsJSON
+	strategies.DefaultsJSON[strategyName] = schema.DefaultsJSON
+	strategies.Schemas[strategyName] = schema
 }
 
 func NewFromJSON(s string) (strategies.Strategy, error) {
 	p := consecutiveRatioParams{}
 
-	err := json.Unmarshal([]byte(s), &p)
+	err := schema.Unmarshal(s, &p)
 	if err != nil {
 		return nil, err
 	}
@@ -49,16 +68,6 @@ func NewFromJSON(s string) (strategies.Strategy, error) {
 	}, nil
 }
 
-func GetDefaultsJSON() string {
-	defaults := consecutiveRatioParams{
-		Ratio:         0.8,
-		TimesRepeated: 10,
-	}
-	bytes, err := json.MarshalIndent(&defaults, "", "  ")
-	utils.MaybeCrash(err)
-	return string(bytes)
-}
-
 func (s *consecutiveRatioStrategy) GetTradeSignal(instrument utils.InstrumentInterface, marketData strategies.MarketData,
 	ordersConfig strategies.OrdersConfig) (*strategies.TradeSignal, map[string]any) {
 	var bids, asks int64
@@ -101,14 +110,16 @@ func (*consecutiveRatioStrategy) GetOutputKeys() []string {
 	return []string{}
 }
 
-func (s *consecutiveRatioStrategy) GetYAML() string {
+func (s *consecutiveRatioStrategy) GetYAML() (string, error) {
 	obj := consecutiveRatioParams{
 		Ratio:         s.triggerRatio,
 		TimesRepeated: s.triggerTimesRepeated,
 	}
 	bytes, err := yaml.Marshal(obj)
-	utils.MaybeCrash(err)
-	return string(bytes)
+	if err != nil {
+		return "", err
+	}
+	return string(bytes), nil
 }
 
 func (*consecutiveRatioStrategy) GetName() string {
@@ -8,7 +8,6 @@ anomalously high lots quantity appears in the orderbook.
 package kwatoko
 
 import (
-	"encoding/json"
 	"github.com/go-yaml/yaml"
 	"tinkoff-invest-contest/internal/client/investapi"
 	"tinkoff-invest-contest/internal/strategies"
@@ -25,17 +24,38 @@ type kwatokoParams struct {
 	PriceDelta       float64 `json:"priceDelta" yaml:"PriceDelta"`
 }
 
+var schema = strategies.Schema{
+	{
+		Name:        "anomalyThreshold",
+		Type:        strategies.ParamTypeFloat,
+		Default:     10,
+		Min:         strategies.Bound(1),
+		MinExcluded: true,
+		Description: "How many times an order's quantity must exceed the average quantity to be anomalous",
+	},
+	{
+		Name:        "priceDelta",
+		Type:        strategies.ParamTypeFloat,
+		Default:     0.001,
+		Min:         strategies.Bound(0),
+		Max:         strategies.Bound(1),
+		MaxExcluded: true,
+		Description: "Distance from the anomalous order's price to place the order at, as a ratio of price",
+	},
+}
+
 func init() {
 	strategyName := "kwatoko"
 	strategies.Names = append(strategies.Names, strategyName)
 	strategies.JSONConstructors[strategyName] = NewFromJSON
-	strategies.DefaultsJSON[strategyName] = GetDefaultsJSON
+	strategies.DefaultsJSON[strategyName] = schema.DefaultsJSON
+	strategies.Schemas[strategyName] = schema
 }
 
 func NewFromJSON(s string) (strategies.Strategy, error) {
 	p := kwatokoParams{}
 
-	err := json.Unmarshal([]byte(s), &p)
+	err := schema.Unmarshal(s, &p)
 	if err != nil {
 		return nil, err
 	}
@@ -46,16 +66,6 @@ func NewFromJSON(s string) (strategies.Strategy, error) {
 	}, nil
 }
 
-func GetDefaultsJSON() string {
-	defaults := kwatokoParams{
-		AnomalyThreshold: 10,
-		PriceDelta:       0.001,
-	}
-	bytes, err := json.MarshalIndent(&defaults, "", "  ")
-	utils.MaybeCrash(err)
-	return string(bytes)
-}
-
 func (s *kwatokoStrategy) GetTradeSignal(instrument utils.InstrumentInterface, marketData strategies.MarketData,
 	ordersConfig strategies.OrdersConfig) (*strategies.TradeSignal, map[string]any) {
 
@@ -100,14 +110,16 @@ func (*kwatokoStrategy) GetOutputKeys() []string {
 	return []string{}
 }
 
-func (s *kwatokoStrategy) GetYAML() string {
+func (s *kwatokoStrategy) GetYAML() (string, error) {
 	obj := kwatokoParams{
 		AnomalyThreshold: s.anomalyThreshold,
 		PriceDelta:       s.priceDelta,
 	}
 	bytes, err := yaml.Marshal(obj)
-	utils.MaybeCrash(err)
-	return string(bytes)
+	if err != nil {
+		return "", err
+	}
+	return string(bytes), nil
 }
 
 func (*kwatokoStrategy) GetName() string {
@@ -5,4 +5,6 @@ import "tinkoff-invest-contest/internal/client/investapi"
 type MarketData struct {
 	Candles   []*investapi.HistoricCandle
 	OrderBook *investapi.OrderBook
+	// Recent trades, oldest first. The direction of a trade is its aggressor side
+	Trades []*investapi.Trade
 }
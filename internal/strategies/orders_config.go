@@ -1,6 +1,16 @@
 package strategies
 
-import "tinkoff-invest-contest/internal/client/investapi"
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"tinkoff-invest-contest/internal/client/investapi"
+	"tinkoff-invest-contest/internal/execution"
+	"tinkoff-invest-contest/internal/sizing"
+	indicators "tinkoff-invest-contest/internal/technical_indicators"
+	"tinkoff-invest-contest/internal/validation"
+)
 
 type OrdersConfig struct {
 	OrderType         investapi.OrderType
@@ -8,4 +18,136 @@ type OrdersConfig struct {
 	TakeProfitRatio   float64
 	StopLossRatio     float64
 	StopLossExecRatio float64
+
+	// Trailing stop loss distance as a ratio of price, 0 to disable
+	TrailingStopRatio float64
+	// Trailing stop loss distance in multiples of ATR, takes precedence over the ratio, 0 to disable
+	TrailingStopATRMultiple float64
+	TrailingStopATRPeriod   int
+	// Stop loss starts trailing once price has moved this far from the entry price (as a ratio) in a favorable direction
+	TrailingStopActivationRatio float64
+
+	// Take-profit ladder, replaces TakeProfitRatio if not empty
+	TakeProfitLevels []TakeProfitLevel
+	// Move stop loss to break-even (entry price plus fees) once the first take-profit level is hit
+	BreakEvenAfterFirstTarget bool
+	// Place stop loss as an exchange stop order instead of emulating it (combat only), take-profits are always emulated
+	UseExchangeStopOrders bool
+
+	// How orders are split into child orders
+	Execution execution.Config
+	// How many lots a position is opened with
+	Sizing sizing.Config
+}
+
+// TakeProfitLevel closes Share percent of the initial position once price has moved by Ratio from the entry price
+type TakeProfitLevel struct {
+	Ratio float64
+	Share float64
+}
+
+// ParseTakeProfitLevels parses a take-profit ladder like "0.01:50,0.02:30,0.03:20" (ratio:percent).
+// Ratios must be ascending and percents must not sum up to more than 100, the last level closes the rest anyway
+func ParseTakeProfitLevels(s string) ([]TakeProfitLevel, error) {
+	var levels []TakeProfitLevel
+	var totalShare float64
+	for _, item := range strings.Split(s, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		parts := strings.Split(item, ":")
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("invalid take-profit level %q, expected ratio:percent", item)
+		}
+		ratio, err := strconv.ParseFloat(strings.TrimSpace(parts[0]), 64)
+		if err != nil || ratio <= 0 {
+			return nil, fmt.Errorf("invalid take-profit ratio %q", parts[0])
+		}
+		share, err := strconv.ParseFloat(strings.TrimSpace(parts[1]), 64)
+		if err != nil || share <= 0 {
+			return nil, fmt.Errorf("invalid take-profit percent %q", parts[1])
+		}
+		if len(levels) > 0 && ratio <= levels[len(levels)-1].Ratio {
+			return nil, errors.New("take-profit ratios must be ascending")
+		}
+		totalShare += share
+		levels = append(levels, TakeProfitLevel{Ratio: ratio, Share: share})
+	}
+	if totalShare > 100 {
+		return nil, fmt.Errorf("take-profit percents sum up to %v, which is more than 100", totalShare)
+	}
+	return levels, nil
+}
+
+// FormatTakeProfitLevels formats a take-profit ladder the way ParseTakeProfitLevels parses it
+func FormatTakeProfitLevels(levels []TakeProfitLevel) string {
+	items := make([]string, 0, len(levels))
+	for _, level := range levels {
+		items = append(items, strconv.FormatFloat(level.Ratio, 'f', -1, 64)+":"+strconv.FormatFloat(level.Share, 'f', -1, 64))
+	}
+	return strings.Join(items, ",")
+}
+
+// Validate checks the config, fields are named as API arguments.
+// Ratios are relative price moves, which can't reach 1 for short positions
+func (config OrdersConfig) Validate() validation.Errors {
+	var errs validation.Errors
+	if !isMarketOrLimit(config.OrderType) {
+		errs.Add("orderType", "must be market (%v) or limit (%v)",
+			int32(investapi.OrderType_ORDER_TYPE_MARKET), int32(investapi.OrderType_ORDER_TYPE_LIMIT))
+	}
+	if !isMarketOrLimit(config.StopLossOrderType) {
+		errs.Add("stopLossOrderType", "must be market (%v) or limit (%v)",
+			int32(investapi.OrderType_ORDER_TYPE_MARKET), int32(investapi.OrderType_ORDER_TYPE_LIMIT))
+	}
+	errs.Ratio("takeProfitRatio", config.TakeProfitRatio)
+	errs.Ratio("stopLossRatio", config.StopLossRatio)
+	errs.Ratio("stopLossExecRatio", config.StopLossExecRatio)
+	if config.StopLossOrderType == investapi.OrderType_ORDER_TYPE_LIMIT && config.StopLossExecRatio < config.StopLossRatio {
+		errs.Add("stopLossExecRatio", "must not be less than stop loss ratio %v, or the stop limit order may not execute",
+			config.StopLossRatio)
+	}
+	errs.Ratio("trailingStopRatio", config.TrailingStopRatio)
+	errs.NonNegative("trailingStopATRMultiple", config.TrailingStopATRMultiple)
+	if config.TrailingStopATRMultiple > 0 && config.TrailingStopATRPeriod < 1 {
+		errs.Add("trailingStopATRPeriod", "must be at least 1 for an ATR trailing stop, got %v", config.TrailingStopATRPeriod)
+	}
+	errs.Ratio("trailingStopActivationRatio", config.TrailingStopActivationRatio)
+	for _, level := range config.TakeProfitLevels {
+		if level.Ratio >= 1 {
+			errs.Add("takeProfitLevels", "ratio must be less than 1, got %v", level.Ratio)
+		}
+	}
+	if err := config.Execution.Validate(); err != nil {
+		errs.Add("executionAlgorithm", "%v", err)
+	}
+	if err := config.Sizing.Validate(); err != nil {
+		errs.Add("sizingModel", "%v", err)
+	}
+	return errs
+}
+
+func isMarketOrLimit(orderType investapi.OrderType) bool {
+	return orderType == investapi.OrderType_ORDER_TYPE_MARKET || orderType == investapi.OrderType_ORDER_TYPE_LIMIT
+}
+
+// GetTakeProfitLevels returns the take-profit ladder, which is a single level at TakeProfitRatio if not configured
+func (config OrdersConfig) GetTakeProfitLevels() []TakeProfitLevel {
+	if len(config.TakeProfitLevels) > 0 {
+		return config.TakeProfitLevels
+	}
+	return []TakeProfitLevel{{Ratio: config.TakeProfitRatio, Share: 100}}
+}
+
+func (config OrdersConfig) IsTrailingStopEnabled() bool {
+	return config.TrailingStopRatio > 0 || config.TrailingStopATRMultiple > 0
+}
+
+// TrailingStopDistance returns the distance to keep between price and a trailing stop loss
+func (config OrdersConfig) TrailingStopDistance(price float64, candles []*investapi.HistoricCandle) float64 {
+	if config.TrailingStopATRMultiple > 0 {
+		return config.TrailingStopATRMultiple * indicators.NewATR(config.TrailingStopATRPeriod).Calculate(candles)
+	}
+	return config.TrailingStopRatio * price
 }
@@ -0,0 +1,145 @@
+package strategies
+
+import (
+	"reflect"
+	"testing"
+	"tinkoff-invest-contest/internal/client/investapi"
+	"tinkoff-invest-contest/internal/execution"
+	"tinkoff-invest-contest/internal/sizing"
+)
+
+func TestParseTakeProfitLevels(t *testing.T) {
+	tests := []struct {
+		name    string
+		s       string
+		want    []TakeProfitLevel
+		wantErr bool
+	}{
+		{
+			name: "test1",
+			s:    "0.01:50, 0.02:30,0.03:20",
+			want: []TakeProfitLevel{{Ratio: 0.01, Share: 50}, {Ratio: 0.02, Share: 30}, {Ratio: 0.03, Share: 20}},
+		},
+		{
+			name: "test2",
+			s:    "",
+			want: nil,
+		},
+		{
+			name:    "test3",
+			s:       "0.02:50,0.01:50",
+			wantErr: true,
+		},
+		{
+			name:    "test4",
+			s:       "0.01:70,0.02:40",
+			wantErr: true,
+		},
+		{
+			name:    "test5",
+			s:       "0.01",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseTakeProfitLevels(tt.s)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ParseTakeProfitLevels() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseTakeProfitLevels() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFormatTakeProfitLevels(t *testing.T) {
+	tests := []struct {
+		name   string
+		levels []TakeProfitLevel
+		want   string
+	}{
+		{
+			name:   "test1",
+			levels: []TakeProfitLevel{{Ratio: 0.01, Share: 50}, {Ratio: 0.025, Share: 30}},
+			want:   "0.01:50,0.025:30",
+		},
+		{
+			name: "test2",
+			want: "",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := FormatTakeProfitLevels(tt.levels)
+			if got != tt.want {
+				t.Errorf("FormatTakeProfitLevels() = %v, want %v", got, tt.want)
+			}
+			parsed, err := ParseTakeProfitLevels(got)
+			if err != nil || len(parsed) != len(tt.levels) {
+				t.Errorf("ParseTakeProfitLevels(%q) = %v, %v", got, parsed, err)
+			}
+		})
+	}
+}
+
+func TestOrdersConfig_Validate(t *testing.T) {
+	valid := OrdersConfig{
+		OrderType:         investapi.OrderType_ORDER_TYPE_MARKET,
+		StopLossOrderType: investapi.OrderType_ORDER_TYPE_LIMIT,
+		TakeProfitRatio:   0.01,
+		StopLossRatio:     0.007,
+		StopLossExecRatio: 0.0085,
+	}
+	tests := []struct {
+		name       string
+		modify     func(config *OrdersConfig)
+		wantFields []string
+	}{
+		{
+			name:   "test1",
+			modify: func(config *OrdersConfig) {},
+		},
+		{
+			name: "test2",
+			modify: func(config *OrdersConfig) {
+				config.OrderType = investapi.OrderType_ORDER_TYPE_UNSPECIFIED
+				config.TakeProfitRatio = 1
+				config.StopLossRatio = -0.1
+			},
+			wantFields: []string{"orderType", "takeProfitRatio", "stopLossRatio"},
+		},
+		{
+			name: "test3",
+			modify: func(config *OrdersConfig) {
+				config.StopLossExecRatio = 0.005
+				config.TrailingStopATRMultiple = 2
+				config.TakeProfitLevels = []TakeProfitLevel{{Ratio: 0.5, Share: 50}, {Ratio: 1.5, Share: 50}}
+			},
+			wantFields: []string{"stopLossExecRatio", "trailingStopATRPeriod", "takeProfitLevels"},
+		},
+		{
+			name: "test4",
+			modify: func(config *OrdersConfig) {
+				config.Execution.Algorithm = execution.AlgorithmTWAP
+				config.Sizing.Model = sizing.ModelFixedLots
+			},
+			wantFields: []string{"executionAlgorithm", "sizingModel"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := valid
+			tt.modify(&config)
+			var gotFields []string
+			for _, fieldError := range config.Validate() {
+				gotFields = append(gotFields, fieldError.Field)
+			}
+			if !reflect.DeepEqual(gotFields, tt.wantFields) {
+				t.Errorf("Validate() fields = %v, want %v", gotFields, tt.wantFields)
+			}
+		})
+	}
+}
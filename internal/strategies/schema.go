@@ -0,0 +1,115 @@
+package strategies
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"tinkoff-invest-contest/internal/validation"
+)
+
+type ParamType string
+
+const (
+	ParamTypeFloat ParamType = "float"
+	ParamTypeInt   ParamType = "int"
+)
+
+// Param describes a strategy parameter, which is a number
+type Param struct {
+	Name        string    `json:"name"`
+	Type        ParamType `json:"type"`
+	Default     float64   `json:"default"`
+	Min         *float64  `json:"min,omitempty"`
+	Max         *float64  `json:"max,omitempty"`
+	MinExcluded bool      `json:"minExcluded,omitempty"`
+	MaxExcluded bool      `json:"maxExcluded,omitempty"`
+	Description string    `json:"description"`
+}
+
+// Bound is a shorthand for Param.Min and Param.Max
+func Bound(value float64) *float64 {
+	return &value
+}
+
+// Schema lists parameters of a strategy in the order they're shown in the form
+type Schema []Param
+
+func (schema Schema) Param(name string) (Param, bool) {
+	for _, param := range schema {
+		if param.Name == name {
+			return param, true
+		}
+	}
+	return Param{}, false
+}
+
+func (param Param) validate(errs *validation.Errors, value float64) {
+	if param.Type == ParamTypeInt && value != math.Trunc(value) {
+		errs.Add(param.Name, "must be an integer, got %v", value)
+		return
+	}
+	errs.Bounds(param.Name, value, param.Min, param.Max, param.MinExcluded, param.MaxExcluded)
+}
+
+// Unmarshal parses a JSON object of parameters into v, whose fields are tagged with parameter names.
+// Unknown parameters, values of a wrong type and out of range are reported as validation.Errors,
+// missing parameters take their defaults
+func (schema Schema) Unmarshal(s string, v any) error {
+	var raw map[string]json.RawMessage
+	err := json.Unmarshal([]byte(s), &raw)
+	if err != nil {
+		return err
+	}
+	var unknown []string
+	for name := range raw {
+		if _, ok := schema.Param(name); !ok {
+			unknown = append(unknown, name)
+		}
+	}
+	sort.Strings(unknown)
+	var errs validation.Errors
+	for _, name := range unknown {
+		errs.Add(name, "unknown parameter")
+	}
+	values := make(map[string]float64, len(schema))
+	for _, param := range schema {
+		value := param.Default
+		if rawValue, ok := raw[param.Name]; ok {
+			if json.Unmarshal(rawValue, &value) != nil {
+				errs.Add(param.Name, "must be a number, got %s", rawValue)
+				continue
+			}
+		}
+		param.validate(&errs, value)
+		values[param.Name] = value
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+
+	data, err := json.Marshal(values)
+	if err != nil {
+		return err
+	}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	return decoder.Decode(v)
+}
+
+// DefaultsJSON returns an indented JSON object of parameters with their default values
+func (schema Schema) DefaultsJSON() string {
+	var buffer bytes.Buffer
+	buffer.WriteString("{")
+	for i, param := range schema {
+		if i > 0 {
+			buffer.WriteString(",")
+		}
+		name, _ := json.Marshal(param.Name)
+		value, _ := json.Marshal(param.Default)
+		buffer.WriteString(fmt.Sprintf("\n  %s: %s", name, value))
+	}
+	buffer.WriteString("\n}")
+	return buffer.String()
+}
@@ -0,0 +1,87 @@
+package strategies
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"tinkoff-invest-contest/internal/validation"
+)
+
+func TestSchema_Unmarshal(t *testing.T) {
+	schema := Schema{
+		{Name: "coef", Type: ParamTypeFloat, Default: 2, Min: Bound(0), MinExcluded: true},
+		{Name: "period", Type: ParamTypeInt, Default: 14, Min: Bound(1), Max: Bound(100)},
+	}
+	type params struct {
+		Coef   float64 `json:"coef"`
+		Period int     `json:"period"`
+	}
+	tests := []struct {
+		name       string
+		s          string
+		want       params
+		wantFields []string
+		wantErr    bool
+	}{
+		{
+			name: "test1",
+			s:    `{"coef": 1.5, "period": 20}`,
+			want: params{Coef: 1.5, Period: 20},
+		},
+		{
+			name: "test2",
+			s:    `{"period": 5}`,
+			want: params{Coef: 2, Period: 5},
+		},
+		{
+			name:       "test3",
+			s:          `{"coef": 0, "period": 2.5, "perod": 3}`,
+			wantFields: []string{"perod", "coef", "period"},
+			wantErr:    true,
+		},
+		{
+			name:       "test4",
+			s:          `{"coef": "1", "period": 101}`,
+			wantFields: []string{"coef", "period"},
+			wantErr:    true,
+		},
+		{
+			name:    "test5",
+			s:       `{"coef": 1`,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got params
+			err := schema.Unmarshal(tt.s, &got)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Unmarshal() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			var gotFields []string
+			var errs validation.Errors
+			if errors.As(err, &errs) {
+				for _, fieldError := range errs {
+					gotFields = append(gotFields, fieldError.Field)
+				}
+			}
+			if !reflect.DeepEqual(gotFields, tt.wantFields) {
+				t.Errorf("Unmarshal() fields = %v, want %v", gotFields, tt.wantFields)
+			}
+			if !tt.wantErr && got != tt.want {
+				t.Errorf("Unmarshal() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSchema_DefaultsJSON(t *testing.T) {
+	schema := Schema{
+		{Name: "coef", Type: ParamTypeFloat, Default: 0.5},
+		{Name: "period", Type: ParamTypeInt, Default: 14},
+	}
+	want := "{\n  \"coef\": 0.5,\n  \"period\": 14\n}"
+	if got := schema.DefaultsJSON(); got != want {
+		t.Errorf("DefaultsJSON() = %q, want %q", got, want)
+	}
+}
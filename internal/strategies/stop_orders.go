@@ -1,6 +1,7 @@
 package strategies
 
 import (
+	"math"
 	"tinkoff-invest-contest/internal/client/investapi"
 	"tinkoff-invest-contest/internal/utils"
 )
@@ -10,6 +11,24 @@ type TradeSignalStopOrder struct {
 	Type         investapi.StopOrderType
 	TriggerPrice *investapi.Quotation
 	ExecPrice    *investapi.Quotation
+	// Percent of the initial position to close, take-profit only
+	Share float64
+}
+
+// LotsToClose returns how many lots a take-profit level closes. The last level closes everything that's left,
+// the others close at least one lot but never more than what's left
+func LotsToClose(initialLots int64, remainingLots int64, share float64, isLast bool) int64 {
+	if isLast {
+		return remainingLots
+	}
+	lots := int64(math.Round(float64(initialLots) * share / 100))
+	if lots < 1 {
+		lots = 1
+	}
+	if lots > remainingLots {
+		lots = remainingLots
+	}
+	return lots
 }
 
 func (stopOrder *TradeSignalStopOrder) IsTriggered(price *investapi.Quotation) bool {
@@ -30,3 +49,75 @@ func (stopOrder *TradeSignalStopOrder) IsTriggered(price *investapi.Quotation) b
 	}
 	return false
 }
+
+// Trail moves the stop toward the price so that it's kept at the given distance, as long as the price
+// has moved from entryPrice by at least activationRatio in a favorable direction. The stop never moves back.
+// Exec price of a stop-limit order is moved by the same amount. Returns whether the stop has moved
+func (stopOrder *TradeSignalStopOrder) Trail(price float64, entryPrice float64, distance float64, activationRatio float64,
+	minPriceIncrement *investapi.Quotation) bool {
+	if distance <= 0 {
+		return false
+	}
+	triggerPrice := utils.QuotationToFloat(stopOrder.TriggerPrice)
+	var newTriggerPrice float64
+	switch stopOrder.Direction {
+	case investapi.OrderDirection_ORDER_DIRECTION_SELL:
+		// Long position
+		if price < entryPrice*(1+activationRatio) {
+			return false
+		}
+		newTriggerPrice = utils.QuotationToFloat(utils.RoundQuotation(utils.FloatToQuotation(price-distance), minPriceIncrement))
+		if newTriggerPrice <= triggerPrice {
+			return false
+		}
+	case investapi.OrderDirection_ORDER_DIRECTION_BUY:
+		// Short position
+		if price > entryPrice*(1-activationRatio) {
+			return false
+		}
+		newTriggerPrice = utils.QuotationToFloat(utils.RoundQuotation(utils.FloatToQuotation(price+distance), minPriceIncrement))
+		if newTriggerPrice >= triggerPrice {
+			return false
+		}
+	default:
+		return false
+	}
+	if stopOrder.ExecPrice != nil {
+		stopOrder.ExecPrice = utils.RoundQuotation(utils.FloatToQuotation(
+			utils.QuotationToFloat(stopOrder.ExecPrice)+newTriggerPrice-triggerPrice,
+		), minPriceIncrement)
+	}
+	stopOrder.TriggerPrice = utils.FloatToQuotation(newTriggerPrice)
+	return true
+}
+
+// MoveToBreakEven moves the stop loss to the price at which closing the position covers the entry price
+// and both fees, unless the stop is already beyond it. Returns whether the stop has moved
+func (stopOrder *TradeSignalStopOrder) MoveToBreakEven(entryPrice float64, fee float64,
+	minPriceIncrement *investapi.Quotation) bool {
+	triggerPrice := utils.QuotationToFloat(stopOrder.TriggerPrice)
+	var breakEvenPrice float64
+	switch stopOrder.Direction {
+	case investapi.OrderDirection_ORDER_DIRECTION_SELL:
+		breakEvenPrice = utils.QuotationToFloat(utils.RoundQuotation(utils.FloatToQuotation(
+			entryPrice*(1+fee)/(1-fee)), minPriceIncrement))
+		if breakEvenPrice <= triggerPrice {
+			return false
+		}
+	case investapi.OrderDirection_ORDER_DIRECTION_BUY:
+		breakEvenPrice = utils.QuotationToFloat(utils.RoundQuotation(utils.FloatToQuotation(
+			entryPrice*(1-fee)/(1+fee)), minPriceIncrement))
+		if breakEvenPrice >= triggerPrice {
+			return false
+		}
+	default:
+		return false
+	}
+	if stopOrder.ExecPrice != nil {
+		stopOrder.ExecPrice = utils.RoundQuotation(utils.FloatToQuotation(
+			utils.QuotationToFloat(stopOrder.ExecPrice)+breakEvenPrice-triggerPrice,
+		), minPriceIncrement)
+	}
+	stopOrder.TriggerPrice = utils.FloatToQuotation(breakEvenPrice)
+	return true
+}
@@ -61,3 +61,167 @@ func TestTradeSignalStopOrder_IsTriggered(t *testing.T) {
 		})
 	}
 }
+
+func TestTradeSignalStopOrder_Trail(t *testing.T) {
+	type fields struct {
+		Direction    investapi.OrderDirection
+		TriggerPrice float64
+	}
+	type args struct {
+		price           float64
+		entryPrice      float64
+		distance        float64
+		activationRatio float64
+	}
+	tests := []struct {
+		name             string
+		fields           fields
+		args             args
+		want             bool
+		wantTriggerPrice float64
+	}{
+		{
+			name:             "test1",
+			fields:           fields{Direction: investapi.OrderDirection_ORDER_DIRECTION_SELL, TriggerPrice: 95},
+			args:             args{price: 110, entryPrice: 100, distance: 5},
+			want:             true,
+			wantTriggerPrice: 105,
+		},
+		{
+			name:             "test2",
+			fields:           fields{Direction: investapi.OrderDirection_ORDER_DIRECTION_SELL, TriggerPrice: 105},
+			args:             args{price: 108, entryPrice: 100, distance: 5},
+			want:             false,
+			wantTriggerPrice: 105,
+		},
+		{
+			name:             "test3",
+			fields:           fields{Direction: investapi.OrderDirection_ORDER_DIRECTION_SELL, TriggerPrice: 95},
+			args:             args{price: 104, entryPrice: 100, distance: 5, activationRatio: 0.05},
+			want:             false,
+			wantTriggerPrice: 95,
+		},
+		{
+			name:             "test4",
+			fields:           fields{Direction: investapi.OrderDirection_ORDER_DIRECTION_BUY, TriggerPrice: 105},
+			args:             args{price: 90, entryPrice: 100, distance: 5},
+			want:             true,
+			wantTriggerPrice: 95,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stopOrder := &TradeSignalStopOrder{
+				Direction:    tt.fields.Direction,
+				Type:         investapi.StopOrderType_STOP_ORDER_TYPE_STOP_LOSS,
+				TriggerPrice: utils.FloatToQuotation(tt.fields.TriggerPrice),
+			}
+			got := stopOrder.Trail(tt.args.price, tt.args.entryPrice, tt.args.distance, tt.args.activationRatio,
+				utils.FloatToQuotation(0.01))
+			if got != tt.want {
+				t.Errorf("Trail() = %v, want %v", got, tt.want)
+			}
+			if triggerPrice := utils.QuotationToFloat(stopOrder.TriggerPrice); triggerPrice != tt.wantTriggerPrice {
+				t.Errorf("Trail() trigger price = %v, want %v", triggerPrice, tt.wantTriggerPrice)
+			}
+		})
+	}
+}
+
+func TestTradeSignalStopOrder_MoveToBreakEven(t *testing.T) {
+	type fields struct {
+		Direction    investapi.OrderDirection
+		TriggerPrice float64
+	}
+	type args struct {
+		entryPrice float64
+		fee        float64
+	}
+	tests := []struct {
+		name             string
+		fields           fields
+		args             args
+		want             bool
+		wantTriggerPrice float64
+	}{
+		{
+			name:             "test1",
+			fields:           fields{Direction: investapi.OrderDirection_ORDER_DIRECTION_SELL, TriggerPrice: 95},
+			args:             args{entryPrice: 100, fee: 0.001},
+			want:             true,
+			wantTriggerPrice: 100.2,
+		},
+		{
+			name:             "test2",
+			fields:           fields{Direction: investapi.OrderDirection_ORDER_DIRECTION_SELL, TriggerPrice: 101},
+			args:             args{entryPrice: 100, fee: 0.001},
+			want:             false,
+			wantTriggerPrice: 101,
+		},
+		{
+			name:             "test3",
+			fields:           fields{Direction: investapi.OrderDirection_ORDER_DIRECTION_BUY, TriggerPrice: 105},
+			args:             args{entryPrice: 100, fee: 0.001},
+			want:             true,
+			wantTriggerPrice: 99.8,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stopOrder := &TradeSignalStopOrder{
+				Direction:    tt.fields.Direction,
+				Type:         investapi.StopOrderType_STOP_ORDER_TYPE_STOP_LOSS,
+				TriggerPrice: utils.FloatToQuotation(tt.fields.TriggerPrice),
+			}
+			got := stopOrder.MoveToBreakEven(tt.args.entryPrice, tt.args.fee, utils.FloatToQuotation(0.01))
+			if got != tt.want {
+				t.Errorf("MoveToBreakEven() = %v, want %v", got, tt.want)
+			}
+			if triggerPrice := utils.QuotationToFloat(stopOrder.TriggerPrice); triggerPrice != tt.wantTriggerPrice {
+				t.Errorf("MoveToBreakEven() trigger price = %v, want %v", triggerPrice, tt.wantTriggerPrice)
+			}
+		})
+	}
+}
+
+func TestLotsToClose(t *testing.T) {
+	type args struct {
+		initialLots   int64
+		remainingLots int64
+		share         float64
+		isLast        bool
+	}
+	tests := []struct {
+		name string
+		args args
+		want int64
+	}{
+		{
+			name: "test1",
+			args: args{initialLots: 10, remainingLots: 10, share: 50},
+			want: 5,
+		},
+		{
+			name: "test2",
+			args: args{initialLots: 3, remainingLots: 3, share: 10},
+			want: 1,
+		},
+		{
+			name: "test3",
+			args: args{initialLots: 10, remainingLots: 2, share: 30},
+			want: 2,
+		},
+		{
+			name: "test4",
+			args: args{initialLots: 10, remainingLots: 4, share: 20, isLast: true},
+			want: 4,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := LotsToClose(tt.args.initialLots, tt.args.remainingLots, tt.args.share, tt.args.isLast); got != tt.want {
+				t.Errorf("LotsToClose() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -5,7 +5,7 @@ import "tinkoff-invest-contest/internal/utils"
 type Strategy interface {
 	GetTradeSignal(instrument utils.InstrumentInterface, marketData MarketData, ordersConfig OrdersConfig) (*TradeSignal, map[string]any)
 	GetOutputKeys() []string
-	GetYAML() string
+	GetYAML() (string, error)
 	GetName() string
 }
 
@@ -13,3 +13,6 @@ var Names = make([]string, 0)
 
 var JSONConstructors = make(map[string]func(string) (Strategy, error))
 var DefaultsJSON = make(map[string]func() string)
+
+// Schemas describe parameters of strategies, constructors reject configs that don't match them
+var Schemas = make(map[string]Schema)
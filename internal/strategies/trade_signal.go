@@ -10,11 +10,15 @@ type TradeSignalOrder struct {
 	Direction investapi.OrderDirection
 	Type      investapi.OrderType
 	Price     *investapi.Quotation
+	// Lots to trade, 0 to let the bot decide
+	Quantity int64
 }
 
 type TradeSignal struct {
-	Order                *TradeSignalOrder
-	StopLoss, TakeProfit *TradeSignalStopOrder
+	Order    *TradeSignalOrder
+	StopLoss *TradeSignalStopOrder
+	// Take-profit ladder, ordered by distance from the entry price
+	TakeProfits []*TradeSignalStopOrder
 }
 
 func NewTradeSignal(direction investapi.OrderDirection, orderType investapi.OrderType,
@@ -37,18 +41,21 @@ func NewTradeSignalWithStopOrders(direction investapi.OrderDirection, price *inv
 			Type:      ordersConfig.OrderType,
 			Price:     price,
 		},
-		TakeProfit: &TradeSignalStopOrder{
-			Direction: stopOrdersDirection,
-			Type:      investapi.StopOrderType_STOP_ORDER_TYPE_TAKE_PROFIT,
-		},
 		StopLoss: &TradeSignalStopOrder{
 			Direction: stopOrdersDirection,
 		},
 	}
 	priceFloat := utils.QuotationToFloat(price)
-	signal.TakeProfit.TriggerPrice = utils.RoundQuotation(utils.FloatToQuotation(
-		priceFloat*(1+ordersConfig.TakeProfitRatio*math.Pow(-1, float64(stopOrdersDirection))),
-	), minPriceIncrement)
+	for _, level := range ordersConfig.GetTakeProfitLevels() {
+		signal.TakeProfits = append(signal.TakeProfits, &TradeSignalStopOrder{
+			Direction: stopOrdersDirection,
+			Type:      investapi.StopOrderType_STOP_ORDER_TYPE_TAKE_PROFIT,
+			TriggerPrice: utils.RoundQuotation(utils.FloatToQuotation(
+				priceFloat*(1+level.Ratio*math.Pow(-1, float64(stopOrdersDirection))),
+			), minPriceIncrement),
+			Share: level.Share,
+		})
+	}
 
 	signal.StopLoss.TriggerPrice = utils.RoundQuotation(utils.FloatToQuotation(
 		priceFloat*(1-ordersConfig.StopLossRatio*math.Pow(-1, float64(stopOrdersDirection))),
@@ -0,0 +1,258 @@
+package supervisor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"runtime/debug"
+	"sync"
+	"time"
+	"tinkoff-invest-contest/internal/client"
+	"tinkoff-invest-contest/internal/utils"
+)
+
+type State int
+
+const (
+	StateCreated State = iota
+	StateRunning
+	StatePaused
+	StateBackingOff
+	StateFailed
+	StateStopped
+)
+
+func StateToString(state State) string {
+	switch state {
+	case StateCreated:
+		return "created"
+	case StateRunning:
+		return "running"
+	case StatePaused:
+		return "paused"
+	case StateBackingOff:
+		return "backing off"
+	case StateFailed:
+		return "failed"
+	case StateStopped:
+		return "stopped"
+	}
+	return ""
+}
+
+// RestartPolicy tells how a failed run is restarted
+type RestartPolicy struct {
+	// Total number of restarts, 0 for unlimited
+	MaxRestarts int
+	// The circuit opens (and the supervisor gives up) after this many consecutive failures, 0 to never open
+	FailureThreshold int
+	// A run lasting at least this long resets consecutive failures
+	StableAfter time.Duration
+	Backoff     client.Backoff
+}
+
+// GetRestartPolicy returns the restart policy, defaults can be overridden with 'RESTART_*' environment variables
+func GetRestartPolicy() RestartPolicy {
+	policy := RestartPolicy{
+		MaxRestarts:      0,
+		FailureThreshold: 5,
+		StableAfter:      5 * time.Minute,
+		Backoff: client.Backoff{
+			Base: 10 * time.Second,
+			Max:  5 * time.Minute,
+		},
+	}
+	utils.EnvInt("RESTART_MAX_RESTARTS", &policy.MaxRestarts)
+	utils.EnvInt("RESTART_FAILURE_THRESHOLD", &policy.FailureThreshold)
+	utils.EnvDuration("RESTART_STABLE_AFTER", &policy.StableAfter)
+	utils.EnvDuration("RESTART_BACKOFF_BASE", &policy.Backoff.Base)
+	utils.EnvDuration("RESTART_BACKOFF_MAX", &policy.Backoff.Max)
+	return policy
+}
+
+// PanicError is a recovered panic
+type PanicError struct {
+	Value any
+	Stack string
+}
+
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("panic: %v", e.Value)
+}
+
+// NewPanicError wraps a value returned by recover(), must be called from the deferred function
+func NewPanicError(recovered any) *PanicError {
+	return &PanicError{Value: recovered, Stack: string(debug.Stack())}
+}
+
+// Status is a snapshot of supervisor's state
+type Status struct {
+	State               string     `json:"state"`
+	Restarts            int        `json:"restarts"`
+	ConsecutiveFailures int        `json:"consecutiveFailures"`
+	LastError           string     `json:"lastError,omitempty"`
+	LastErrorAt         *time.Time `json:"lastErrorAt,omitempty"`
+	StackTrace          string     `json:"stackTrace,omitempty"`
+	NextRestartAt       *time.Time `json:"nextRestartAt,omitempty"`
+}
+
+// Supervisor runs a function, isolating its panics and restarting it on failures according to the restart policy
+type Supervisor struct {
+	policy RestartPolicy
+	// Logs are prefixed with it
+	name string
+
+	mu                  sync.Mutex
+	state               State
+	active              bool
+	done                chan struct{}
+	restarts            int
+	consecutiveFailures int
+	lastError           error
+	lastErrorAt         time.Time
+	stackTrace          string
+	nextRestartAt       time.Time
+}
+
+func New(name string, policy RestartPolicy) *Supervisor {
+	done := make(chan struct{})
+	close(done)
+	return &Supervisor{
+		policy: policy,
+		name:   name,
+		state:  StateCreated,
+		done:   done,
+	}
+}
+
+// Run calls run until it returns nil, ctx is done or the restart policy gives up.
+// Does nothing if the supervisor is already running
+func (s *Supervisor) Run(ctx context.Context, run func(ctx context.Context) error) {
+	s.mu.Lock()
+	if s.active {
+		s.mu.Unlock()
+		return
+	}
+	s.active = true
+	s.done = make(chan struct{})
+	s.consecutiveFailures = 0
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		s.active = false
+		close(s.done)
+		s.mu.Unlock()
+	}()
+
+	for {
+		s.setState(StateRunning)
+		start := time.Now()
+		err := runRecovered(ctx, run)
+		if err == nil || ctx.Err() != nil {
+			s.setState(StateStopped)
+			return
+		}
+		delay, giveUp := s.fail(err, time.Since(start))
+		if giveUp {
+			log.Printf("%v has failed too many times, giving up: %v", s.name, err)
+			return
+		}
+		log.Printf("%v has crashed (%v), restarting in %v...", s.name, err, delay.Round(time.Second))
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			s.setState(StateStopped)
+			return
+		}
+	}
+}
+
+func runRecovered(ctx context.Context, run func(ctx context.Context) error) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = NewPanicError(r)
+		}
+	}()
+	return run(ctx)
+}
+
+// fail records the error and decides whether to restart
+func (s *Supervisor) fail(err error, ranFor time.Duration) (delay time.Duration, giveUp bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.lastError, s.lastErrorAt, s.stackTrace = err, time.Now(), ""
+	var panicErr *PanicError
+	if errors.As(err, &panicErr) {
+		s.stackTrace = panicErr.Stack
+	}
+	if ranFor >= s.policy.StableAfter {
+		s.consecutiveFailures = 0
+	}
+	s.consecutiveFailures++
+	if (s.policy.FailureThreshold > 0 && s.consecutiveFailures >= s.policy.FailureThreshold) ||
+		(s.policy.MaxRestarts > 0 && s.restarts >= s.policy.MaxRestarts) {
+		s.state = StateFailed
+		return 0, true
+	}
+	s.restarts++
+	delay = s.policy.Backoff.Duration(s.consecutiveFailures - 1)
+	s.state, s.nextRestartAt = StateBackingOff, time.Now().Add(delay)
+	return delay, false
+}
+
+func (s *Supervisor) setState(state State) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.state = state
+}
+
+// SetPaused switches between running and paused states, other states are kept
+func (s *Supervisor) SetPaused(paused bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if paused && s.state == StateRunning {
+		s.state = StatePaused
+	} else if !paused && s.state == StatePaused {
+		s.state = StateRunning
+	}
+}
+
+// Wait blocks until the supervisor is not running or ctx is done
+func (s *Supervisor) Wait(ctx context.Context) error {
+	s.mu.Lock()
+	done := s.done
+	s.mu.Unlock()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (s *Supervisor) GetState() State {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.state
+}
+
+func (s *Supervisor) GetStatus() Status {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	status := Status{
+		State:               StateToString(s.state),
+		Restarts:            s.restarts,
+		ConsecutiveFailures: s.consecutiveFailures,
+		StackTrace:          s.stackTrace,
+	}
+	if s.lastError != nil {
+		lastErrorAt := s.lastErrorAt
+		status.LastError, status.LastErrorAt = s.lastError.Error(), &lastErrorAt
+	}
+	if s.state == StateBackingOff {
+		nextRestartAt := s.nextRestartAt
+		status.NextRestartAt = &nextRestartAt
+	}
+	return status
+}
@@ -0,0 +1,87 @@
+package supervisor
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+	"tinkoff-invest-contest/internal/client"
+)
+
+func TestSupervisor_Run(t *testing.T) {
+	policy := RestartPolicy{
+		FailureThreshold: 3,
+		StableAfter:      time.Minute,
+		Backoff:          client.Backoff{Base: time.Millisecond, Max: time.Millisecond},
+	}
+	tests := []struct {
+		name         string
+		policy       RestartPolicy
+		failures     int
+		panics       bool
+		wantState    State
+		wantRestarts int
+		wantStack    bool
+	}{
+		{
+			name:         "test1",
+			policy:       policy,
+			failures:     2,
+			wantState:    StateStopped,
+			wantRestarts: 2,
+		},
+		{
+			name:         "test2",
+			policy:       policy,
+			failures:     10,
+			wantState:    StateFailed,
+			wantRestarts: 2,
+		},
+		{
+			name:         "test3",
+			policy:       policy,
+			failures:     10,
+			panics:       true,
+			wantState:    StateFailed,
+			wantRestarts: 2,
+			wantStack:    true,
+		},
+		{
+			name: "test4",
+			policy: RestartPolicy{
+				MaxRestarts: 1,
+				Backoff:     client.Backoff{Base: time.Millisecond, Max: time.Millisecond},
+			},
+			failures:     10,
+			wantState:    StateFailed,
+			wantRestarts: 1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := New("test", tt.policy)
+			calls := 0
+			s.Run(context.Background(), func(ctx context.Context) error {
+				calls++
+				if calls > tt.failures {
+					return nil
+				}
+				if tt.panics {
+					panic("boom")
+				}
+				return errors.New("boom")
+			})
+			status := s.GetStatus()
+			if s.GetState() != tt.wantState {
+				t.Errorf("state = %v, want %v", status.State, StateToString(tt.wantState))
+			}
+			if status.Restarts != tt.wantRestarts {
+				t.Errorf("restarts = %v, want %v", status.Restarts, tt.wantRestarts)
+			}
+			if (status.StackTrace != "") != tt.wantStack || !strings.Contains(status.LastError, "boom") {
+				t.Errorf("lastError = %q, stackTrace = %q", status.LastError, status.StackTrace)
+			}
+		})
+	}
+}
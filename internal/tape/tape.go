@@ -0,0 +1,116 @@
+package tape
+
+import (
+	"google.golang.org/protobuf/proto"
+	"sort"
+	"time"
+	"tinkoff-invest-contest/internal/client/investapi"
+)
+
+// Tape is a rolling window of the latest trades of an instrument, oldest first.
+// The direction of a trade is its aggressor side
+type Tape struct {
+	window time.Duration
+	// The oldest trades are dropped beyond it, even if they're in the window
+	maxLen int
+	trades []*investapi.Trade
+}
+
+func New(window time.Duration, maxLen int) *Tape {
+	return &Tape{
+		window: window,
+		maxLen: maxLen,
+	}
+}
+
+// Add adds the trade in time order and drops the trades out of the window as of the latest one.
+// Returns false if the trade is already on the tape, e.g. when the stream repeats requested trades.
+// A trade older than the window restarts the tape, since time only goes back when market data is replayed
+func (t *Tape) Add(trade *investapi.Trade) bool {
+	ts := trade.Time.AsTime()
+	if len(t.trades) > 0 && ts.Before(t.latest().Add(-t.window)) {
+		t.trades = nil
+	}
+	i := sort.Search(len(t.trades), func(i int) bool {
+		return t.trades[i].Time.AsTime().After(ts)
+	})
+	for j := i - 1; j >= 0 && t.trades[j].Time.AsTime().Equal(ts); j-- {
+		if proto.Equal(t.trades[j], trade) {
+			return false
+		}
+	}
+	t.trades = append(t.trades, nil)
+	copy(t.trades[i+1:], t.trades[i:])
+	t.trades[i] = trade
+	t.trim()
+	return true
+}
+
+// Reset replaces the trades, e.g. with the ones requested from the API after a gap in the stream
+func (t *Tape) Reset(trades []*investapi.Trade) {
+	t.trades = nil
+	for _, trade := range trades {
+		t.Add(trade)
+	}
+}
+
+// Trades returns a copy of the trades, oldest first
+func (t *Tape) Trades() []*investapi.Trade {
+	trades := make([]*investapi.Trade, len(t.trades))
+	copy(trades, t.trades)
+	return trades
+}
+
+func (t *Tape) latest() time.Time {
+	return t.trades[len(t.trades)-1].Time.AsTime()
+}
+
+func (t *Tape) trim() {
+	start := t.latest().Add(-t.window)
+	i := sort.Search(len(t.trades), func(i int) bool {
+		return !t.trades[i].Time.AsTime().Before(start)
+	})
+	if len(t.trades)-i > t.maxLen {
+		i = len(t.trades) - t.maxLen
+	}
+	t.trades = t.trades[i:]
+}
+
+// Volume returns the lots bought and sold by aggressors
+func Volume(trades []*investapi.Trade) (buy int64, sell int64) {
+	for _, trade := range trades {
+		switch trade.Direction {
+		case investapi.TradeDirection_TRADE_DIRECTION_BUY:
+			buy += trade.Quantity
+		case investapi.TradeDirection_TRADE_DIRECTION_SELL:
+			sell += trade.Quantity
+		}
+	}
+	return buy, sell
+}
+
+// Flow is the lots bought and sold by aggressors since Start, e.g. within a candle
+type Flow struct {
+	Start time.Time
+	Buy   int64
+	Sell  int64
+}
+
+// Add accounts the trade that belongs to the period starting at start, the flow starts over on a new period.
+// Trades of earlier periods are ignored
+func (f *Flow) Add(trade *investapi.Trade, start time.Time) {
+	if start.Before(f.Start) {
+		return
+	}
+	if !start.Equal(f.Start) {
+		*f = Flow{Start: start}
+	}
+	buy, sell := Volume([]*investapi.Trade{trade})
+	f.Buy += buy
+	f.Sell += sell
+}
+
+// Delta is the difference between bought and sold lots, positive when buyers are more aggressive
+func (f Flow) Delta() int64 {
+	return f.Buy - f.Sell
+}
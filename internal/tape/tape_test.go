@@ -0,0 +1,130 @@
+package tape
+
+import (
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"reflect"
+	"testing"
+	"time"
+	"tinkoff-invest-contest/internal/client/investapi"
+	"tinkoff-invest-contest/internal/utils"
+)
+
+var start = time.Date(2022, 6, 1, 10, 0, 0, 0, time.UTC)
+
+// newTrade makes a trade at the given second after start, a positive quantity is bought and a negative one is sold
+func newTrade(second int, quantity int64) *investapi.Trade {
+	trade := &investapi.Trade{
+		Figi:      "BBG000000001",
+		Direction: investapi.TradeDirection_TRADE_DIRECTION_BUY,
+		Price:     utils.FloatToQuotation(100),
+		Quantity:  quantity,
+		Time:      timestamppb.New(start.Add(time.Duration(second) * time.Second)),
+	}
+	if quantity < 0 {
+		trade.Direction, trade.Quantity = investapi.TradeDirection_TRADE_DIRECTION_SELL, -quantity
+	}
+	return trade
+}
+
+// tradeSeconds returns the seconds after start of the trades
+func tradeSeconds(trades []*investapi.Trade) []int {
+	seconds := make([]int, 0, len(trades))
+	for _, trade := range trades {
+		seconds = append(seconds, int(trade.Time.AsTime().Sub(start)/time.Second))
+	}
+	return seconds
+}
+
+func TestTape_Add(t *testing.T) {
+	tests := []struct {
+		name   string
+		maxLen int
+		trades []*investapi.Trade
+		want   []int
+	}{
+		{
+			name:   "test1",
+			maxLen: 100,
+			trades: []*investapi.Trade{newTrade(0, 1), newTrade(30, 2), newTrade(90, 3)},
+			want:   []int{30, 90},
+		},
+		{
+			name:   "test2",
+			maxLen: 100,
+			trades: []*investapi.Trade{newTrade(10, 1), newTrade(30, 2), newTrade(20, 3), newTrade(30, 2)},
+			want:   []int{10, 20, 30},
+		},
+		{
+			name:   "test3",
+			maxLen: 2,
+			trades: []*investapi.Trade{newTrade(10, 1), newTrade(20, 2), newTrade(30, 3)},
+			want:   []int{20, 30},
+		},
+		{
+			name:   "test4",
+			maxLen: 100,
+			trades: []*investapi.Trade{newTrade(1000, 1), newTrade(1010, 2), newTrade(10, 3)},
+			want:   []int{10},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tape := New(time.Minute, tt.maxLen)
+			for _, trade := range tt.trades {
+				tape.Add(trade)
+			}
+			if got := tradeSeconds(tape.Trades()); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Trades() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestVolume(t *testing.T) {
+	buy, sell := Volume([]*investapi.Trade{newTrade(0, 3), newTrade(1, -5), newTrade(2, 4)})
+	if buy != 7 || sell != 5 {
+		t.Errorf("Volume() = %v, %v, want 7, 5", buy, sell)
+	}
+}
+
+func TestFlow_Add(t *testing.T) {
+	minute := func(n int) time.Time {
+		return start.Add(time.Duration(n) * time.Minute)
+	}
+	tests := []struct {
+		name   string
+		trades []*investapi.Trade
+		starts []time.Time
+		want   Flow
+	}{
+		{
+			name:   "test1",
+			trades: []*investapi.Trade{newTrade(0, 3), newTrade(10, -5), newTrade(20, 4)},
+			starts: []time.Time{minute(0), minute(0), minute(0)},
+			want:   Flow{Start: minute(0), Buy: 7, Sell: 5},
+		},
+		{
+			name:   "test2",
+			trades: []*investapi.Trade{newTrade(0, 3), newTrade(70, -5), newTrade(80, 4)},
+			starts: []time.Time{minute(0), minute(1), minute(1)},
+			want:   Flow{Start: minute(1), Buy: 4, Sell: 5},
+		},
+		{
+			name:   "test3",
+			trades: []*investapi.Trade{newTrade(70, 3), newTrade(10, -5)},
+			starts: []time.Time{minute(1), minute(0)},
+			want:   Flow{Start: minute(1), Buy: 3},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var flow Flow
+			for i, trade := range tt.trades {
+				flow.Add(trade, tt.starts[i])
+			}
+			if !reflect.DeepEqual(flow, tt.want) {
+				t.Errorf("Flow = %+v, want %+v", flow, tt.want)
+			}
+		})
+	}
+}
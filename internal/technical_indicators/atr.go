@@ -0,0 +1,44 @@
+package indicators
+
+import (
+	"math"
+	"tinkoff-invest-contest/internal/client/investapi"
+	"tinkoff-invest-contest/internal/utils"
+)
+
+type ATR struct {
+	// Number of the latest candles to average true ranges over
+	period int
+}
+
+func NewATR(period int) *ATR {
+	return &ATR{period: period}
+}
+
+// Calculate calculates Average True Range as a simple average of true ranges over the period.
+// If there are fewer candles, all of them are used
+func (atr *ATR) Calculate(candles []*investapi.HistoricCandle) float64 {
+	if len(candles) == 0 {
+		return 0
+	}
+	from := 0
+	if atr.period > 0 && len(candles) > atr.period {
+		from = len(candles) - atr.period
+	}
+	var sum float64
+	for i := from; i < len(candles); i++ {
+		high := utils.QuotationToFloat(candles[i].High)
+		low := utils.QuotationToFloat(candles[i].Low)
+		trueRange := high - low
+		if i > 0 {
+			prevClose := utils.QuotationToFloat(candles[i-1].Close)
+			trueRange = math.Max(trueRange, math.Max(math.Abs(high-prevClose), math.Abs(low-prevClose)))
+		}
+		sum += trueRange
+	}
+	return sum / float64(len(candles)-from)
+}
+
+func (atr *ATR) GetPeriod() int {
+	return atr.period
+}
@@ -0,0 +1,62 @@
+package indicators
+
+import (
+	"math"
+	"testing"
+	"tinkoff-invest-contest/internal/client/investapi"
+	"tinkoff-invest-contest/internal/utils"
+)
+
+func candle(high, low, close float64) *investapi.HistoricCandle {
+	return &investapi.HistoricCandle{
+		High:  utils.FloatToQuotation(high),
+		Low:   utils.FloatToQuotation(low),
+		Close: utils.FloatToQuotation(close),
+	}
+}
+
+func TestATR_Calculate(t *testing.T) {
+	type args struct {
+		candles []*investapi.HistoricCandle
+	}
+	tests := []struct {
+		name   string
+		period int
+		args   args
+		want   float64
+	}{
+		{
+			name:   "test1",
+			period: 14,
+			args: args{candles: []*investapi.HistoricCandle{
+				candle(11, 9, 10),
+				candle(12, 10, 11),
+			}},
+			want: 2,
+		},
+		{
+			name:   "test2",
+			period: 2,
+			args: args{candles: []*investapi.HistoricCandle{
+				candle(11, 9, 10),
+				candle(10.5, 10, 10.2),
+				// Gap up: true range is measured from the previous close
+				candle(13, 12, 12.5),
+			}},
+			want: (0.5 + 2.8) / 2,
+		},
+		{
+			name:   "test3",
+			period: 14,
+			args:   args{candles: []*investapi.HistoricCandle{}},
+			want:   0,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := NewATR(tt.period).Calculate(tt.args.candles); math.Abs(got-tt.want) > 1e-9 {
+				t.Errorf("Calculate() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -1,6 +1,7 @@
 package tradeenv
 
 import (
+	"log"
 	"sort"
 	"strings"
 	"tinkoff-invest-contest/internal/utils"
@@ -39,35 +40,71 @@ func (e *TradeEnv) GetUnoccupiedAccount(currency string) (accountId string, disc
 	return
 }
 
-func (e *TradeEnv) ReleaseAccount(accountId string, currency string) {
-	positions, err := e.Client.WrapGetPositions(e.isSandbox, accountId)
-	utils.MaybeCrash(err)
+// OccupyAccount marks the given account as occupied, returns false if it is already occupied or doesn't exist
+func (e *TradeEnv) OccupyAccount(accountId string, currency string) bool {
 	e.mu.Lock()
-	for _, moneyPosition := range positions.Money {
-		e.accounts[accountId][moneyPosition.Currency].amount = utils.MoneyValueToFloat(moneyPosition)
+	defer e.mu.Unlock()
+	moneyPosition, ok := e.accounts[accountId][currency]
+	if !ok || moneyPosition.occupied {
+		return false
+	}
+	moneyPosition.occupied = true
+	return true
+}
+
+// ReleaseAccount marks the account as unoccupied and refreshes its money positions.
+// The account is released even if the refresh fails
+func (e *TradeEnv) ReleaseAccount(accountId string, currency string) error {
+	positions, err := e.getPositions(accountId)
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if err == nil {
+		for _, moneyPosition := range positions.Money {
+			if _, ok := e.accounts[accountId][moneyPosition.Currency]; ok {
+				e.accounts[accountId][moneyPosition.Currency].amount = utils.MoneyValueToFloat(moneyPosition)
+			}
+		}
 	}
 	e.accounts[accountId][currency].occupied = false
-	e.mu.Unlock()
+	return err
 }
 
-func (e *TradeEnv) CreateSandboxAccount(money map[string]float64) (accountId string) {
+// CreateSandboxAccount opens a sandbox account, or a paper trading one in paper environment, with the given money
+func (e *TradeEnv) CreateSandboxAccount(money map[string]float64) (accountId string, err error) {
+	if e.paper != nil {
+		accountId = e.paper.OpenAccount(money)
+		e.addAccount(accountId, money)
+		return accountId, nil
+	}
 	accountResp, err := e.Client.OpenSandboxAccount()
-	utils.MaybeCrash(err)
+	if err != nil {
+		return "", err
+	}
 	accountId = accountResp.AccountId
-	e.mu.Lock()
-	e.accounts[accountResp.AccountId] = make(map[string]*moneyPosition)
 	for currency, amount := range money {
 		if amount > 0 {
-			_, err = e.Client.SandboxPayIn(accountResp.AccountId, currency, amount)
-			utils.MaybeCrash(err)
+			_, err = e.Client.SandboxPayIn(accountId, currency, amount)
+			if err != nil {
+				_, _ = e.Client.CloseSandboxAccount(accountId)
+				return "", err
+			}
 		}
-		e.accounts[accountResp.AccountId][currency] = &moneyPosition{
+	}
+	e.addAccount(accountId, money)
+	return
+}
+
+func (e *TradeEnv) addAccount(accountId string, money map[string]float64) {
+	moneyPositions := make(map[string]*moneyPosition)
+	for currency, amount := range money {
+		moneyPositions[currency] = &moneyPosition{
 			amount:   amount,
 			occupied: false,
 		}
 	}
+	e.mu.Lock()
+	e.accounts[accountId] = moneyPositions
 	e.mu.Unlock()
-	return
 }
 
 func (e *TradeEnv) RemoveSandboxAccount(id string) {
@@ -76,31 +113,44 @@ func (e *TradeEnv) RemoveSandboxAccount(id string) {
 		delete(e.accounts, id)
 		e.mu.Unlock()
 
-		_, _ = e.Client.CloseSandboxAccount(id)
+		if e.paper != nil {
+			e.paper.CloseAccount(id)
+		} else {
+			_, _ = e.Client.CloseSandboxAccount(id)
+		}
 	}
 }
 
-func (e *TradeEnv) loadCombatAccounts() {
+func (e *TradeEnv) loadCombatAccounts() error {
 	accounts, err := e.Client.GetAccounts()
-	utils.MaybeCrash(err)
+	if err != nil {
+		return err
+	}
 	accountIds := make([]string, 0)
-	e.mu.Lock()
 	for _, account := range accounts {
 		positions, err := e.Client.GetPositions(account.Id)
-		utils.MaybeCrash(err)
-		e.accounts[account.Id] = map[string]*moneyPosition{
+		if err != nil {
+			return err
+		}
+		moneyPositions := map[string]*moneyPosition{
 			"rub": {},
 			"usd": {},
 		}
 		for _, position := range positions.Money {
-			if _, ok := e.accounts[account.Id][position.Currency]; ok {
-				e.accounts[account.Id][position.Currency].amount = utils.MoneyValueToFloat(position)
+			if _, ok := moneyPositions[position.Currency]; ok {
+				moneyPositions[position.Currency].amount = utils.MoneyValueToFloat(position)
 			}
 		}
+		e.mu.Lock()
+		e.accounts[account.Id] = moneyPositions
+		e.mu.Unlock()
 		accountIds = append(accountIds, account.Id)
 	}
-	e.mu.Unlock()
-	e.InitTradesChannels(accountIds)
+	err = e.Client.InitTradesStream(accountIds)
+	if err != nil {
+		log.Println("error: can't open trades stream, will retry:", err)
+	}
+	return nil
 }
 
 type accountsPayloadEntry struct {
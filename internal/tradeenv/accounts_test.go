@@ -6,7 +6,7 @@ import (
 )
 
 func TestTradeEnv_CreateSandboxAccount(t *testing.T) {
-	e := New(utils.GetSandboxToken(), true)
+	e := newSandboxEnv(t)
 	type args struct {
 		money map[string]float64
 	}
@@ -23,7 +23,7 @@ func TestTradeEnv_CreateSandboxAccount(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			e.CreateSandboxAccount(tt.args.money)
+			_, _ = e.CreateSandboxAccount(tt.args.money)
 			for id, positions1 := range e.accounts {
 				positions2, err := e.Client.GetSandboxPositions(id)
 				if err != nil {
@@ -48,7 +48,7 @@ func TestTradeEnv_CreateSandboxAccount(t *testing.T) {
 }
 
 func TestTradeEnv_GetUnoccupiedAccount(t *testing.T) {
-	e := New(utils.GetSandboxToken(), true)
+	e := newSandboxEnv(t)
 	type args struct {
 		currency string
 	}
@@ -76,7 +76,7 @@ func TestTradeEnv_GetUnoccupiedAccount(t *testing.T) {
 				if money[tt.args.currency] > maxMoney {
 					maxMoney = money[tt.args.currency]
 				}
-				e.CreateSandboxAccount(money)
+				_, _ = e.CreateSandboxAccount(money)
 			}
 
 			gotAccountId, unlock, _ := e.GetUnoccupiedAccount(tt.args.currency)
@@ -6,15 +6,11 @@ import (
 )
 
 func (e *TradeEnv) CalculateMaxDealValue(accountId string, direction investapi.OrderDirection,
-	instrument utils.InstrumentInterface, price *investapi.Quotation, allowMargin bool) float64 {
-	var positions *investapi.PositionsResponse
-	var err error
-	if e.isSandbox {
-		positions, err = e.Client.GetSandboxPositions(accountId)
-	} else {
-		positions, err = e.Client.GetPositions(accountId)
+	instrument utils.InstrumentInterface, price *investapi.Quotation, allowMargin bool) (float64, error) {
+	positions, err := e.getPositions(accountId)
+	if err != nil {
+		return 0, err
 	}
-	utils.MaybeCrash(err)
 
 	var moneyHave float64
 	var lotsHave int64
@@ -32,7 +28,9 @@ func (e *TradeEnv) CalculateMaxDealValue(accountId string, direction investapi.O
 	var marginAttributes *investapi.GetMarginAttributesResponse
 	if !e.isSandbox && allowMargin {
 		marginAttributes, err = e.Client.GetMarginAttributes(accountId)
-		utils.MaybeCrash(err)
+		if err != nil {
+			return 0, err
+		}
 	}
 
 	var maxDealValue float64
@@ -55,7 +53,21 @@ func (e *TradeEnv) CalculateMaxDealValue(accountId string, direction investapi.O
 			maxDealValue = float64(lotsHave) * float64(instrument.GetLot()) * utils.QuotationToFloat(price)
 		}
 	}
-	return maxDealValue
+	return maxDealValue, nil
+}
+
+// GetMoneyHave returns the account's money in the given currency
+func (e *TradeEnv) GetMoneyHave(accountId string, currency string) (float64, error) {
+	positions, err := e.getPositions(accountId)
+	if err != nil {
+		return 0, err
+	}
+	for _, money := range positions.Money {
+		if money.Currency == currency {
+			return utils.MoneyValueToFloat(money), nil
+		}
+	}
+	return 0, nil
 }
 
 func (e *TradeEnv) CalculateLotsCanAfford(direction investapi.OrderDirection, maxDealValue float64,
@@ -76,7 +88,7 @@ func (e *TradeEnv) CalculateLotsCanAfford(direction investapi.OrderDirection, ma
 }
 
 func (e *TradeEnv) GetLotsHave(accountId string, instrument utils.InstrumentInterface) (lots int64, err error) { // TODO: with expectation that it can return negative quantity for short position
-	portfolio, err := e.Client.WrapGetPortfolio(e.isSandbox, accountId)
+	portfolio, err := e.getPortfolio(accountId)
 	if err != nil {
 		return 0, err
 	}
@@ -87,3 +99,40 @@ func (e *TradeEnv) GetLotsHave(accountId string, instrument utils.InstrumentInte
 	}
 	return
 }
+
+// BrokerPosition is the broker's view of an instrument position on an account
+type BrokerPosition struct {
+	Lots         int64 // negative for short position
+	AvgPrice     float64
+	ActiveOrders []*investapi.OrderState
+}
+
+// GetBrokerPosition returns the instrument position and its active orders on the account
+func (e *TradeEnv) GetBrokerPosition(accountId string, instrument utils.InstrumentInterface) (*BrokerPosition, error) {
+	portfolio, err := e.getPortfolio(accountId)
+	if err != nil {
+		return nil, err
+	}
+	orders, err := e.getOrders(accountId)
+	if err != nil {
+		return nil, err
+	}
+	brokerPosition := &BrokerPosition{}
+	for _, position := range portfolio.Positions {
+		if position.Figi == instrument.GetFigi() {
+			brokerPosition.Lots = int64(utils.QuotationToFloat(position.QuantityLots))
+			brokerPosition.AvgPrice = utils.MoneyValueToFloat(position.AveragePositionPrice)
+		}
+	}
+	for _, order := range orders {
+		if order.Figi == instrument.GetFigi() {
+			brokerPosition.ActiveOrders = append(brokerPosition.ActiveOrders, order)
+		}
+	}
+	return brokerPosition, nil
+}
+
+// CancelOrder cancels an active order on the account
+func (e *TradeEnv) CancelOrder(accountId string, orderId string) error {
+	return e.cancelOrder(accountId, orderId)
+}
@@ -1,13 +1,14 @@
 package tradeenv
 
 import (
+	"context"
 	"testing"
 	"tinkoff-invest-contest/internal/client/investapi"
 	"tinkoff-invest-contest/internal/utils"
 )
 
 func TestTradeEnv_CalculateLotsCanAfford(t *testing.T) {
-	e := New(utils.GetSandboxToken(), true)
+	e := newSandboxEnv(t)
 	type args struct {
 		direction      investapi.OrderDirection
 		maxDealValue   float64
@@ -49,8 +50,8 @@ func TestTradeEnv_CalculateLotsCanAfford(t *testing.T) {
 }
 
 func TestTradeEnv_CalculateMaxDealValue(t *testing.T) {
-	e := New(utils.GetSandboxToken(), true)
-	e.CreateSandboxAccount(map[string]float64{"rub": 10000, "usd": 0})
+	e := newSandboxEnv(t)
+	_, _ = e.CreateSandboxAccount(map[string]float64{"rub": 10000, "usd": 0})
 
 	type args struct {
 		direction      investapi.OrderDirection
@@ -84,7 +85,11 @@ func TestTradeEnv_CalculateMaxDealValue(t *testing.T) {
 		t.Run(tt.name, func(t *testing.T) {
 			instrument, _ := e.Client.InstrumentByFigi(tt.args.figi, tt.args.instrumentType)
 			accountId, unlock, _ := e.GetUnoccupiedAccount(instrument.GetCurrency())
-			got := e.CalculateMaxDealValue(accountId, tt.args.direction, instrument, tt.args.price, tt.args.allowMargin)
+			got, err := e.CalculateMaxDealValue(accountId, tt.args.direction, instrument, tt.args.price, tt.args.allowMargin)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("CalculateMaxDealValue() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
 			if got != tt.want {
 				t.Errorf("CalculateMaxDealValue() got = %v, want %v", got, tt.want)
 			}
@@ -97,8 +102,8 @@ func TestTradeEnv_CalculateMaxDealValue(t *testing.T) {
 }
 
 func TestTradeEnv_GetLotsHave(t *testing.T) {
-	e := New(utils.GetSandboxToken(), true)
-	e.CreateSandboxAccount(map[string]float64{"rub": 100000, "usd": 0})
+	e := newSandboxEnv(t)
+	_, _ = e.CreateSandboxAccount(map[string]float64{"rub": 100000, "usd": 0})
 	type args struct {
 		figi           string
 		instrumentType utils.InstrumentType
@@ -123,7 +128,7 @@ func TestTradeEnv_GetLotsHave(t *testing.T) {
 		t.Run(tt.name, func(t *testing.T) {
 			instrument, _ := e.Client.InstrumentByFigi(tt.args.figi, tt.args.instrumentType)
 			accountId, unlock, _ := e.GetUnoccupiedAccount(instrument.GetCurrency())
-			_, _ = e.DoOrder(tt.args.figi, tt.wantLots, utils.FloatToQuotation(1000),
+			_, _ = e.DoOrder(context.Background(), tt.args.figi, tt.wantLots, utils.FloatToQuotation(1000),
 				investapi.OrderDirection_ORDER_DIRECTION_BUY, accountId, investapi.OrderType_ORDER_TYPE_MARKET)
 			gotLots, err := e.GetLotsHave(accountId, instrument)
 			if (err != nil) != tt.wantErr {
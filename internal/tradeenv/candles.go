@@ -2,6 +2,7 @@ package tradeenv
 
 import (
 	"time"
+	"tinkoff-invest-contest/internal/bars"
 	"tinkoff-invest-contest/internal/client/investapi"
 )
 
@@ -31,3 +32,58 @@ func (e *TradeEnv) GetAtLeastNLastCandles(figi string,
 	}
 	return candles, nil
 }
+
+const maxHistoryDepth = 10 * 365 * 24 * time.Hour
+
+// maxBarsRequests limits the number of candle requests made to backfill bars, so that an instrument that hasn't been
+// traded for a long time doesn't exhaust the rate limit
+const maxBarsRequests = 60
+
+// candlesRequestPeriod is the longest period candles of the interval can be requested for at once
+func candlesRequestPeriod(candleInterval investapi.CandleInterval) time.Duration {
+	switch candleInterval {
+	case investapi.CandleInterval_CANDLE_INTERVAL_HOUR:
+		return 7 * 24 * time.Hour
+	case investapi.CandleInterval_CANDLE_INTERVAL_DAY:
+		return 365 * 24 * time.Hour
+	}
+	return 24 * time.Hour
+}
+
+// GetAtLeastNLastBars returns at least n last candles of the interval aggregated from the API's candles,
+// the last one is the current one. Fewer candles are returned if the instrument doesn't have that much history
+// within maxBarsRequests requests. The replay environment returns the candles replayed so far
+func (e *TradeEnv) GetAtLeastNLastBars(figi string, interval bars.Interval, session bars.Session,
+	n int) ([]*investapi.HistoricCandle, error) {
+	if e.replayOnly {
+		return e.history.bars(figi, interval, session, n), nil
+	}
+	source := interval.Source()
+	sourceInterval, err := bars.FromCandleInterval(source)
+	if err != nil {
+		return nil, err
+	}
+	period := candlesRequestPeriod(source)
+	sources := make([]*investapi.HistoricCandle, 0)
+	candles := make([]*investapi.HistoricCandle, 0)
+	// The first candle may lack its beginning, which is in the previous period
+	for i := 0; len(candles) < n+1 && i < maxBarsRequests; i++ {
+		to := time.Now().Add(-time.Duration(i) * period)
+		portion, err := e.Client.GetCandles(figi, to.Add(-period), to, source)
+		if err != nil {
+			return nil, err
+		}
+		sources = append(portion, sources...)
+		if len(sources) > 0 {
+			candles = bars.Aggregate(sources, sourceInterval, interval, session)
+		}
+		if time.Since(to) > maxHistoryDepth {
+			// The instrument doesn't have that much history
+			break
+		}
+	}
+	if len(candles) > n {
+		candles = candles[1:]
+	}
+	return candles, nil
+}
@@ -4,11 +4,10 @@ import (
 	"testing"
 	"time"
 	"tinkoff-invest-contest/internal/client/investapi"
-	"tinkoff-invest-contest/internal/utils"
 )
 
 func TestTradeEnv_GetCandlesFor1NthDayBeforeNow(t *testing.T) {
-	e := New(utils.GetSandboxToken(), true)
+	e := newSandboxEnv(t)
 	type args struct {
 		figi           string
 		candleInterval investapi.CandleInterval
@@ -60,7 +59,7 @@ func TestTradeEnv_GetCandlesFor1NthDayBeforeNow(t *testing.T) {
 }
 
 func TestTradeEnv_GetAtLeastNLastCandles(t *testing.T) {
-	e := New(utils.GetSandboxToken(), true)
+	e := newSandboxEnv(t)
 	type args struct {
 		figi           string
 		candleInterval investapi.CandleInterval
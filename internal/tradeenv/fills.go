@@ -0,0 +1,189 @@
+package tradeenv
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+	"tinkoff-invest-contest/internal/client"
+	"tinkoff-invest-contest/internal/client/investapi"
+	"tinkoff-invest-contest/internal/utils"
+)
+
+const (
+	// Pending orders are also checked with GetOrderState this often, in case a trades message got lost
+	orderReconcileInterval = time.Minute
+	// Orders waited for with a deadline (e.g. on shutdown, when the trades stream may be gone) are checked this often
+	deadlineReconcileInterval = time.Second
+	// Trades of orders nobody waits for (e.g. placed manually) are kept this long
+	unclaimedTradesTTL = 10 * time.Minute
+)
+
+// pendingOrder collects the fills of an order placed by DoOrder until it is fully executed
+type pendingOrder struct {
+	accountId     string
+	orderId       string
+	lotsRequested int64
+
+	lotsExecuted int64
+	notional     float64
+
+	done     chan struct{}
+	once     sync.Once
+	avgPrice float64
+	err      error
+}
+
+type unclaimedTrades struct {
+	receivedAt time.Time
+	trades     []*investapi.OrderTrades
+}
+
+func (p *pendingOrder) finish(avgPrice float64, err error) {
+	p.once.Do(func() {
+		p.avgPrice, p.err = avgPrice, err
+		close(p.done)
+	})
+}
+
+// addTrades must be called with ordersMu locked
+func (p *pendingOrder) addTrades(orderTrades *investapi.OrderTrades) {
+	for _, trade := range orderTrades.Trades {
+		p.lotsExecuted += trade.Quantity
+		p.notional += utils.QuotationToFloat(trade.Price) * float64(trade.Quantity)
+	}
+	if p.lotsExecuted >= p.lotsRequested && p.lotsExecuted > 0 {
+		p.finish(p.notional/float64(p.lotsExecuted), nil)
+	}
+}
+
+// orderStateResult tells whether the order is in a final state and what the outcome is
+func orderStateResult(state *investapi.OrderState) (done bool, avgPrice float64, err error) {
+	switch state.ExecutionReportStatus {
+	case investapi.OrderExecutionReportStatus_EXECUTION_REPORT_STATUS_FILL:
+		return true, utils.MoneyValueToFloat(state.AveragePositionPrice), nil
+	case investapi.OrderExecutionReportStatus_EXECUTION_REPORT_STATUS_REJECTED,
+		investapi.OrderExecutionReportStatus_EXECUTION_REPORT_STATUS_CANCELLED:
+		return true, 0, fmt.Errorf("order %v is %v (%v of %v lots executed)", state.OrderId,
+			state.ExecutionReportStatus, state.LotsExecuted, state.LotsRequested)
+	}
+	return false, 0, nil
+}
+
+// trackOrder starts collecting the fills of the order, including the ones that arrived before the order was tracked
+func (e *TradeEnv) trackOrder(accountId string, order *investapi.PostOrderResponse) *pendingOrder {
+	p := &pendingOrder{
+		accountId:     accountId,
+		orderId:       order.OrderId,
+		lotsRequested: order.LotsRequested,
+		done:          make(chan struct{}),
+	}
+	e.ordersMu.Lock()
+	defer e.ordersMu.Unlock()
+	e.pendingOrders[p.orderId] = p
+	if unclaimed, ok := e.unclaimedTrades[p.orderId]; ok {
+		delete(e.unclaimedTrades, p.orderId)
+		for _, orderTrades := range unclaimed.trades {
+			p.addTrades(orderTrades)
+		}
+	}
+	return p
+}
+
+func (e *TradeEnv) untrackOrder(p *pendingOrder) {
+	e.ordersMu.Lock()
+	defer e.ordersMu.Unlock()
+	delete(e.pendingOrders, p.orderId)
+}
+
+// waitForFill blocks until the order is executed or ctx is done,
+// checking order state periodically in case a fill was missed, and often if ctx has a deadline
+func (e *TradeEnv) waitForFill(ctx context.Context, p *pendingOrder) (avgPrice float64, err error) {
+	interval := orderReconcileInterval
+	if _, ok := ctx.Deadline(); ok {
+		interval = deadlineReconcileInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-p.done:
+			return p.avgPrice, p.err
+		case <-ticker.C:
+			e.reconcileOrder(p)
+		case <-ctx.Done():
+			return 0, ctx.Err()
+		}
+	}
+}
+
+func (e *TradeEnv) handleTradesStream(resp *investapi.TradesStreamResponse) {
+	orderTrades := resp.GetOrderTrades()
+	if orderTrades == nil {
+		return
+	}
+	e.ordersMu.Lock()
+	defer e.ordersMu.Unlock()
+	if p, ok := e.pendingOrders[orderTrades.OrderId]; ok {
+		p.addTrades(orderTrades)
+		return
+	}
+	for orderId, unclaimed := range e.unclaimedTrades {
+		if time.Since(unclaimed.receivedAt) > unclaimedTradesTTL {
+			delete(e.unclaimedTrades, orderId)
+		}
+	}
+	unclaimed, ok := e.unclaimedTrades[orderTrades.OrderId]
+	if !ok {
+		unclaimed = &unclaimedTrades{receivedAt: time.Now()}
+		e.unclaimedTrades[orderTrades.OrderId] = unclaimed
+	}
+	unclaimed.trades = append(unclaimed.trades, orderTrades)
+}
+
+func (e *TradeEnv) handleTradesStreamState(state client.StreamState) {
+	if state == client.StreamConnected {
+		go e.reconcilePendingOrders()
+	}
+}
+
+// reconcilePendingOrders catches up on the fills that happened while the trades stream was down.
+// Orders that are no longer active are looked up individually
+func (e *TradeEnv) reconcilePendingOrders() {
+	e.ordersMu.Lock()
+	byAccount := make(map[string][]*pendingOrder)
+	for _, p := range e.pendingOrders {
+		byAccount[p.accountId] = append(byAccount[p.accountId], p)
+	}
+	e.ordersMu.Unlock()
+
+	for accountId, pending := range byAccount {
+		active := make(map[string]bool)
+		orders, err := e.Client.WrapGetOrders(e.isSandbox, accountId)
+		if err != nil {
+			log.Printf("error: can't get active orders of account %v: %v", accountId, err)
+		}
+		for _, order := range orders {
+			active[order.OrderId] = true
+		}
+		for _, p := range pending {
+			if err == nil && active[p.orderId] {
+				continue
+			}
+			e.reconcileOrder(p)
+		}
+	}
+}
+
+func (e *TradeEnv) reconcileOrder(p *pendingOrder) {
+	state, err := e.getOrderState(p.accountId, p.orderId)
+	if err != nil {
+		log.Printf("error: can't get state of order %v: %v", p.orderId, err)
+		return
+	}
+	if done, avgPrice, err := orderStateResult(state); done {
+		log.Printf("order %v has been reconciled: %v", p.orderId, state.ExecutionReportStatus)
+		p.finish(avgPrice, err)
+	}
+}
@@ -0,0 +1,132 @@
+package tradeenv
+
+import (
+	"context"
+	"testing"
+	"tinkoff-invest-contest/internal/client/investapi"
+	"tinkoff-invest-contest/internal/utils"
+)
+
+func TestPendingOrder_addTrades(t *testing.T) {
+	type args struct {
+		trades []*investapi.OrderTrades
+	}
+	tests := []struct {
+		name         string
+		lots         int64
+		args         args
+		wantDone     bool
+		wantAvgPrice float64
+	}{
+		{
+			name: "test1",
+			lots: 3,
+			args: args{trades: []*investapi.OrderTrades{
+				{Trades: []*investapi.OrderTrade{
+					{Price: utils.FloatToQuotation(100), Quantity: 1},
+					{Price: utils.FloatToQuotation(103), Quantity: 2},
+				}},
+			}},
+			wantDone:     true,
+			wantAvgPrice: 102,
+		},
+		{
+			name: "test2",
+			lots: 3,
+			args: args{trades: []*investapi.OrderTrades{
+				{Trades: []*investapi.OrderTrade{{Price: utils.FloatToQuotation(100), Quantity: 2}}},
+			}},
+			wantDone: false,
+		},
+		{
+			name: "test3",
+			lots: 3,
+			args: args{trades: []*investapi.OrderTrades{
+				{Trades: []*investapi.OrderTrade{{Price: utils.FloatToQuotation(100), Quantity: 2}}},
+				{Trades: []*investapi.OrderTrade{{Price: utils.FloatToQuotation(106), Quantity: 1}}},
+			}},
+			wantDone:     true,
+			wantAvgPrice: 102,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := &pendingOrder{lotsRequested: tt.lots, done: make(chan struct{})}
+			for _, orderTrades := range tt.args.trades {
+				p.addTrades(orderTrades)
+			}
+			var done bool
+			select {
+			case <-p.done:
+				done = true
+			default:
+			}
+			if done != tt.wantDone {
+				t.Fatalf("done = %v, want %v", done, tt.wantDone)
+			}
+			if done && p.avgPrice != tt.wantAvgPrice {
+				t.Errorf("avgPrice = %v, want %v", p.avgPrice, tt.wantAvgPrice)
+			}
+		})
+	}
+}
+
+func Test_orderStateResult(t *testing.T) {
+	tests := []struct {
+		name         string
+		status       investapi.OrderExecutionReportStatus
+		wantDone     bool
+		wantAvgPrice float64
+		wantErr      bool
+	}{
+		{
+			name:         "test1",
+			status:       investapi.OrderExecutionReportStatus_EXECUTION_REPORT_STATUS_FILL,
+			wantDone:     true,
+			wantAvgPrice: 102,
+		},
+		{
+			name:     "test2",
+			status:   investapi.OrderExecutionReportStatus_EXECUTION_REPORT_STATUS_PARTIALLYFILL,
+			wantDone: false,
+		},
+		{
+			name:     "test3",
+			status:   investapi.OrderExecutionReportStatus_EXECUTION_REPORT_STATUS_CANCELLED,
+			wantDone: true,
+			wantErr:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			state := &investapi.OrderState{
+				ExecutionReportStatus: tt.status,
+				AveragePositionPrice:  utils.FloatToMoneyValue("rub", 102),
+			}
+			done, avgPrice, err := orderStateResult(state)
+			if done != tt.wantDone || avgPrice != tt.wantAvgPrice || (err != nil) != tt.wantErr {
+				t.Errorf("orderStateResult() = %v, %v, %v, want %v, %v, error %v",
+					done, avgPrice, err, tt.wantDone, tt.wantAvgPrice, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestTradeEnv_waitForFill(t *testing.T) {
+	e, accountId := newPaperEnv()
+	// No trades stream delivers the fill, like on shutdown
+	order, err := e.paper.PostOrder(paperFigi, 2, nil, investapi.OrderDirection_ORDER_DIRECTION_BUY, accountId,
+		investapi.OrderType_ORDER_TYPE_MARKET, "1")
+	if err != nil {
+		t.Fatalf("PostOrder() error = %v", err)
+	}
+	p := e.trackOrder(accountId, order)
+	defer e.untrackOrder(p)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*deadlineReconcileInterval)
+	defer cancel()
+	avgPrice, err := e.waitForFill(ctx, p)
+	if err != nil || avgPrice != 100 {
+		t.Errorf("waitForFill() = %v, %v, want 100", avgPrice, err)
+	}
+}
@@ -0,0 +1,77 @@
+package tradeenv
+
+import (
+	"fmt"
+	"google.golang.org/grpc/connectivity"
+	"time"
+	"tinkoff-invest-contest/internal/health"
+)
+
+const (
+	// Market data stream is considered stale if nothing (including pings) arrived for this long
+	marketDataStaleAfter = 5 * time.Minute
+	// ...and dead, so that the process should be restarted
+	marketDataDeadAfter = 15 * time.Minute
+)
+
+func (e *TradeEnv) registerHealthChecks() {
+	name := e.envName()
+	health.RegisterReadiness(name+".grpc_connection", func() error {
+		state := e.Client.ConnState()
+		if state == connectivity.TransientFailure || state == connectivity.Shutdown {
+			return fmt.Errorf("gRPC connection is %v", state)
+		}
+		return nil
+	})
+	health.RegisterReadiness(name+".market_data_stream", e.marketDataStreamCheck(marketDataStaleAfter))
+	health.RegisterLiveness(name+".market_data_stream_alive", e.marketDataStreamCheck(marketDataDeadAfter))
+	if !e.isSandbox {
+		health.RegisterReadiness(name+".trades_stream", func() error {
+			if !e.Client.IsTradesStreamAlive() {
+				return fmt.Errorf("trades stream is down (last message at %v)",
+					e.Client.LastTradesMessageTime().Format(time.RFC3339))
+			}
+			return nil
+		})
+	}
+}
+
+// marketDataStreamCheck checks the age of the last stream message, as long as there are any subscriptions
+func (e *TradeEnv) marketDataStreamCheck(maxAge time.Duration) health.Check {
+	ageCheck := health.MaxAge("market data stream message", e.Client.LastMarketDataMessageTime, maxAge)
+	return func() error {
+		if !e.hasSubscriptions() {
+			return nil
+		}
+		return ageCheck()
+	}
+}
+
+func (e *TradeEnv) hasSubscriptions() bool {
+	mu.Lock()
+	defer mu.Unlock()
+	for _, subscription := range e.subscriptions.candles {
+		if subscription != nil {
+			return true
+		}
+	}
+	for _, subscription := range e.subscriptions.orderBook {
+		if subscription != nil {
+			return true
+		}
+	}
+	return false
+}
+
+func (e *TradeEnv) envName() string {
+	if e.replayOnly {
+		return "replay"
+	}
+	if e.paper != nil {
+		return "paper"
+	}
+	if e.isSandbox {
+		return "sandbox"
+	}
+	return "combat"
+}
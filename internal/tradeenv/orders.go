@@ -1,45 +1,121 @@
 package tradeenv
 
 import (
+	"context"
+	"fmt"
 	"github.com/google/uuid"
+	"log"
 	"time"
 	"tinkoff-invest-contest/internal/client/investapi"
+	"tinkoff-invest-contest/internal/metrics"
 	"tinkoff-invest-contest/internal/utils"
 )
 
-// DoOrder posts either sandbox or real order with automatically generated orderId and waits for order to be filled
-func (e *TradeEnv) DoOrder(figi string, quantity int64, price *investapi.Quotation, direction investapi.OrderDirection,
+// DoOrder posts either sandbox, paper or real order with automatically generated orderId and waits for order to be filled.
+// If ctx is done before that, the order is left as is and ctx error is returned
+func (e *TradeEnv) DoOrder(ctx context.Context, figi string, quantity int64, price *investapi.Quotation, direction investapi.OrderDirection,
 	accountId string, orderType investapi.OrderType) (avgPositionPrice float64, err error) {
-	order, err := e.Client.WrapPostOrder(e.isSandbox, figi, quantity, price, direction, accountId, orderType, uuid.New().String())
+	_, avgPositionPrice, err = e.doOrder(ctx, figi, quantity, price, direction, accountId, orderType)
+	return
+}
+
+// DoOrderFor does the same as DoOrder, but waits for the order to be filled for at most timeout.
+// If the order isn't filled in time or ctx is done, it's cancelled and the executed part is returned,
+// along with ctx error in the latter case
+func (e *TradeEnv) DoOrderFor(ctx context.Context, timeout time.Duration, figi string, quantity int64, price *investapi.Quotation,
+	direction investapi.OrderDirection, accountId string, orderType investapi.OrderType) (lotsExecuted int64, avgPositionPrice float64, err error) {
+	timeoutCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	orderId, avgPositionPrice, err := e.doOrder(timeoutCtx, figi, quantity, price, direction, accountId, orderType)
+	if err == nil {
+		return quantity, avgPositionPrice, nil
+	}
+	if orderId == "" {
+		return 0, 0, err
+	}
+	if timeoutCtx.Err() != nil {
+		// The order could have been filled right before cancellation, the final state tells anyway
+		_ = e.cancelOrder(accountId, orderId)
+	}
+	// A rejected or cancelled order could have been executed partially too
+	orderState, stateErr := e.getOrderState(accountId, orderId)
+	if stateErr != nil {
+		return 0, 0, stateErr
+	}
+	lotsExecuted, avgPositionPrice = orderState.LotsExecuted, utils.MoneyValueToFloat(orderState.AveragePositionPrice)
+	switch orderState.ExecutionReportStatus {
+	case investapi.OrderExecutionReportStatus_EXECUTION_REPORT_STATUS_FILL:
+		return lotsExecuted, avgPositionPrice, nil
+	case investapi.OrderExecutionReportStatus_EXECUTION_REPORT_STATUS_CANCELLED:
+		if ctx.Err() != nil {
+			return lotsExecuted, avgPositionPrice, ctx.Err()
+		}
+		if timeoutCtx.Err() != nil {
+			return lotsExecuted, avgPositionPrice, nil
+		}
+		return lotsExecuted, avgPositionPrice, err
+	case investapi.OrderExecutionReportStatus_EXECUTION_REPORT_STATUS_REJECTED:
+		return lotsExecuted, avgPositionPrice, err
+	}
+	return lotsExecuted, avgPositionPrice,
+		fmt.Errorf("order %v couldn't be cancelled, it's %v", orderId, orderState.ExecutionReportStatus)
+}
+
+// doOrder returns the id of the posted order, so that it can be dealt with if waiting fails
+func (e *TradeEnv) doOrder(ctx context.Context, figi string, quantity int64, price *investapi.Quotation, direction investapi.OrderDirection,
+	accountId string, orderType investapi.OrderType) (orderId string, avgPositionPrice float64, err error) {
+	defer func(start time.Time) {
+		metrics.DoOrderDuration.WithLabelValues(e.envName()).Observe(time.Since(start).Seconds())
+	}(time.Now())
+	order, err := e.postOrder(figi, quantity, price, direction, accountId, orderType, uuid.New().String())
 	if err != nil {
 		return
 	}
+	if order.ExecutionReportStatus == investapi.OrderExecutionReportStatus_EXECUTION_REPORT_STATUS_REJECTED {
+		err = fmt.Errorf("order %v is rejected: %v", order.OrderId, order.Message)
+		return
+	}
+	orderId = order.OrderId
+	if order.ExecutionReportStatus == investapi.OrderExecutionReportStatus_EXECUTION_REPORT_STATUS_FILL {
+		// The response has no average price per instrument unit, but the state of a filled order needs no waiting
+		var orderState *investapi.OrderState
+		orderState, err = e.getOrderState(accountId, order.OrderId)
+		if err == nil {
+			_, avgPositionPrice, err = orderStateResult(orderState)
+			return
+		}
+		log.Printf("error: can't get state of filled order %v: %v", order.OrderId, err)
+	}
+	if e.paper != nil {
+		var orderState *investapi.OrderState
+		orderState, err = e.paper.WaitOrder(ctx, accountId, order.OrderId)
+		if err != nil {
+			return
+		}
+		_, avgPositionPrice, err = orderStateResult(orderState)
+		return
+	}
 	if e.isSandbox {
-		orderState := new(investapi.OrderState)
-		for orderState.ExecutionReportStatus != investapi.OrderExecutionReportStatus_EXECUTION_REPORT_STATUS_FILL {
-			orderState, err = e.Client.WrapGetOrderState(e.isSandbox, accountId, order.OrderId)
+		var orderState *investapi.OrderState
+		for {
+			orderState, err = e.getOrderState(accountId, order.OrderId)
 			if err != nil {
 				return
 			}
-			time.Sleep(time.Second)
-		}
-		avgPositionPrice = utils.MoneyValueToFloat(orderState.AveragePositionPrice)
-	} else {
-		var orderTrades *investapi.OrderTrades
-	loop:
-		for {
-			trades := e.GetTradesChannel(accountId)
+			var done bool
+			if done, avgPositionPrice, err = orderStateResult(orderState); done {
+				return
+			}
 			select {
-			case orderTrades = <-trades:
-				if orderTrades.OrderId == order.OrderId {
-					break loop
-				}
+			case <-time.After(time.Second):
+			case <-ctx.Done():
+				err = ctx.Err()
+				return
 			}
 		}
-		for _, trade := range orderTrades.Trades {
-			avgPositionPrice += utils.QuotationToFloat(trade.Price)
-		}
-		avgPositionPrice /= float64(len(orderTrades.Trades))
 	}
+	p := e.trackOrder(accountId, order)
+	defer e.untrackOrder(p)
+	avgPositionPrice, err = e.waitForFill(ctx, p)
 	return
 }
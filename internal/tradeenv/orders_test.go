@@ -1,13 +1,16 @@
 package tradeenv
 
 import (
+	"context"
+	"errors"
 	"testing"
+	"time"
 	"tinkoff-invest-contest/internal/client/investapi"
 	"tinkoff-invest-contest/internal/utils"
 )
 
 func TestTradeEnv_DoOrder(t *testing.T) {
-	e := New(utils.GetSandboxToken(), true)
+	e := newSandboxEnv(t)
 	type args struct {
 		figi           string
 		instrumentType utils.InstrumentType
@@ -32,10 +35,10 @@ func TestTradeEnv_DoOrder(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			e.CreateSandboxAccount(map[string]float64{"rub": 100000, "usd": 10000})
+			_, _ = e.CreateSandboxAccount(map[string]float64{"rub": 100000, "usd": 10000})
 			instrument, _ := e.Client.InstrumentByFigi(tt.args.figi, tt.args.instrumentType)
 			accountId, unlock, _ := e.GetUnoccupiedAccount(instrument.GetCurrency())
-			_, err := e.DoOrder(tt.args.figi, tt.args.quantity, tt.args.price,
+			_, err := e.DoOrder(context.Background(), tt.args.figi, tt.args.quantity, tt.args.price,
 				investapi.OrderDirection_ORDER_DIRECTION_BUY, accountId, investapi.OrderType_ORDER_TYPE_MARKET)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("DoOrder() (buy) error = %v, wantErr %v", err, tt.wantErr)
@@ -46,7 +49,7 @@ func TestTradeEnv_DoOrder(t *testing.T) {
 				t.Errorf("DoOrder() (buy) gotLotsHave = %v, want %v", gotLotsHave, tt.args.quantity)
 				return
 			}
-			_, err = e.DoOrder(tt.args.figi, tt.args.quantity, tt.args.price,
+			_, err = e.DoOrder(context.Background(), tt.args.figi, tt.args.quantity, tt.args.price,
 				investapi.OrderDirection_ORDER_DIRECTION_SELL, accountId, investapi.OrderType_ORDER_TYPE_MARKET)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("DoOrder() (sell) error = %v, wantErr %v", err, tt.wantErr)
@@ -62,3 +65,50 @@ func TestTradeEnv_DoOrder(t *testing.T) {
 		})
 	}
 }
+
+func TestTradeEnv_DoOrderFor(t *testing.T) {
+	tests := []struct {
+		name         string
+		price        float64
+		cancelCtx    bool
+		wantExecuted int64
+		wantErr      error
+	}{
+		{
+			name:         "test1",
+			price:        100,
+			wantExecuted: 10,
+		},
+		{
+			name:  "test2",
+			price: 99,
+		},
+		{
+			name:         "test3",
+			price:        100,
+			cancelCtx:    true,
+			wantExecuted: 10,
+			wantErr:      context.Canceled,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e, accountId := newPaperEnv()
+			ctx, cancel := context.WithCancel(context.Background())
+			if tt.cancelCtx {
+				time.AfterFunc(100*time.Millisecond, cancel)
+			}
+			defer cancel()
+			// 10 lots are taken right away at 100, the rest of the order rests on the book until it's cancelled
+			lotsExecuted, _, err := e.DoOrderFor(ctx, time.Second, paperFigi, 15, utils.FloatToQuotation(tt.price),
+				investapi.OrderDirection_ORDER_DIRECTION_BUY, accountId, investapi.OrderType_ORDER_TYPE_LIMIT)
+			if lotsExecuted != tt.wantExecuted || !errors.Is(err, tt.wantErr) {
+				t.Errorf("DoOrderFor() = %v lots, %v, want %v lots, %v", lotsExecuted, err, tt.wantExecuted, tt.wantErr)
+			}
+			orders, _ := e.getOrders(accountId)
+			if len(orders) != 0 {
+				t.Errorf("%v orders are left active", len(orders))
+			}
+		})
+	}
+}
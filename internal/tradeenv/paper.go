@@ -0,0 +1,62 @@
+package tradeenv
+
+import (
+	"tinkoff-invest-contest/internal/client/investapi"
+	"tinkoff-invest-contest/internal/utils"
+)
+
+// Orders and positions go to the paper trading venue if the environment has one, otherwise to Invest API
+
+func (e *TradeEnv) IsPaper() bool {
+	return e.paper != nil
+}
+
+// AddInstrument lets the paper trading venue fill orders for the instrument of a bot, does nothing in other environments
+func (e *TradeEnv) AddInstrument(instrument utils.InstrumentInterface) {
+	if e.paper != nil {
+		e.paper.AddInstrument(instrument)
+	}
+}
+
+func (e *TradeEnv) postOrder(figi string, quantity int64, price *investapi.Quotation, direction investapi.OrderDirection,
+	accountId string, orderType investapi.OrderType, orderId string) (*investapi.PostOrderResponse, error) {
+	if e.paper != nil {
+		return e.paper.PostOrder(figi, quantity, price, direction, accountId, orderType, orderId)
+	}
+	return e.Client.WrapPostOrder(e.isSandbox, figi, quantity, price, direction, accountId, orderType, orderId)
+}
+
+func (e *TradeEnv) cancelOrder(accountId string, orderId string) error {
+	if e.paper != nil {
+		return e.paper.CancelOrder(accountId, orderId)
+	}
+	return e.Client.WrapCancelOrder(e.isSandbox, accountId, orderId)
+}
+
+func (e *TradeEnv) getOrderState(accountId string, orderId string) (*investapi.OrderState, error) {
+	if e.paper != nil {
+		return e.paper.GetOrderState(accountId, orderId)
+	}
+	return e.Client.WrapGetOrderState(e.isSandbox, accountId, orderId)
+}
+
+func (e *TradeEnv) getOrders(accountId string) ([]*investapi.OrderState, error) {
+	if e.paper != nil {
+		return e.paper.GetOrders(accountId)
+	}
+	return e.Client.WrapGetOrders(e.isSandbox, accountId)
+}
+
+func (e *TradeEnv) getPortfolio(accountId string) (*investapi.PortfolioResponse, error) {
+	if e.paper != nil {
+		return e.paper.GetPortfolio(accountId)
+	}
+	return e.Client.WrapGetPortfolio(e.isSandbox, accountId)
+}
+
+func (e *TradeEnv) getPositions(accountId string) (*investapi.PositionsResponse, error) {
+	if e.paper != nil {
+		return e.paper.GetPositions(accountId)
+	}
+	return e.Client.WrapGetPositions(e.isSandbox, accountId)
+}
@@ -0,0 +1,158 @@
+package tradeenv
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sort"
+	"sync"
+	"time"
+	"tinkoff-invest-contest/internal/bars"
+	"tinkoff-invest-contest/internal/client/investapi"
+	"tinkoff-invest-contest/internal/recording"
+)
+
+// replayDeliveryTimeout is how long the replay waits for a bot to take an event. A bot that doesn't take it in time
+// (stopped, paused or removed) misses the events until it takes one again, so that it doesn't hold the replay up
+const replayDeliveryTimeout = time.Minute
+
+// lastTradesDepth is how long trades are kept for, the API has the trades of the last hour only
+const lastTradesDepth = time.Hour
+
+type replayCandlesKey struct {
+	figi     string
+	interval investapi.SubscriptionInterval
+}
+
+// replayHistory keeps the candles and trades replayed so far, so that a replay environment serves history
+// as of the time of the replayed event instead of requesting it from Invest API
+type replayHistory struct {
+	mu sync.Mutex
+	// Receive time of the latest replayed event
+	now     time.Time
+	candles map[replayCandlesKey][]*investapi.Candle
+	trades  map[string][]*investapi.Trade
+}
+
+func newReplayHistory() *replayHistory {
+	return &replayHistory{
+		candles: make(map[replayCandlesKey][]*investapi.Candle),
+		trades:  make(map[string][]*investapi.Trade),
+	}
+}
+
+// reset forgets the history of the previous replay
+func (h *replayHistory) reset() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.now = time.Time{}
+	h.candles = make(map[replayCandlesKey][]*investapi.Candle)
+	h.trades = make(map[string][]*investapi.Trade)
+}
+
+// add puts the candle or the trade of the event in the history, a candle updates the stored one of the same time
+func (h *replayHistory) add(event recording.Event) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.now = event.ReceivedAt
+	if candle := event.Data.GetCandle(); candle != nil {
+		key := replayCandlesKey{candle.Figi, candle.Interval}
+		candles := h.candles[key]
+		if len(candles) > 0 {
+			last := candles[len(candles)-1].Time.AsTime()
+			if candle.Time.AsTime().Before(last) {
+				// Out of order
+				return
+			}
+			if candle.Time.AsTime().Equal(last) {
+				candles = candles[:len(candles)-1]
+			}
+		}
+		h.candles[key] = append(candles, candle)
+	}
+	if trade := event.Data.GetTrade(); trade != nil {
+		trades := h.trades[trade.Figi]
+		cutoff := h.now.Add(-lastTradesDepth)
+		i := sort.Search(len(trades), func(i int) bool {
+			return !trades[i].Time.AsTime().Before(cutoff)
+		})
+		h.trades[trade.Figi] = append(trades[i:], trade)
+	}
+}
+
+// bars returns the candles of the interval aggregated from the replayed ones, the last one is the current one.
+// It's the same as TradeEnv.GetAtLeastNLastBars returns, but with as many candles as have been replayed
+func (h *replayHistory) bars(figi string, interval bars.Interval, session bars.Session, n int) []*investapi.HistoricCandle {
+	subscription := interval.Subscription()
+	source := bars.Interval{Duration: time.Minute}
+	if subscription == investapi.SubscriptionInterval_SUBSCRIPTION_INTERVAL_FIVE_MINUTES {
+		source = bars.Interval{Duration: 5 * time.Minute}
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	replayed := h.candles[replayCandlesKey{figi, subscription}]
+	if len(replayed) == 0 {
+		return nil
+	}
+	sources := make([]*investapi.HistoricCandle, 0, len(replayed))
+	for i, candle := range replayed {
+		sources = append(sources, &investapi.HistoricCandle{
+			Open:   candle.Open,
+			High:   candle.High,
+			Low:    candle.Low,
+			Close:  candle.Close,
+			Volume: candle.Volume,
+			Time:   candle.Time,
+			// Only the latest candle is still being updated
+			IsComplete: i < len(replayed)-1,
+		})
+	}
+	candles := bars.Aggregate(sources, source, interval, session)
+	if len(candles) > n {
+		// The first candle may lack its beginning, which wasn't recorded
+		candles = candles[1:]
+	}
+	return candles
+}
+
+// lastTrades returns the instrument's trades of the period before the replayed time, oldest first
+func (h *replayHistory) lastTrades(figi string, period time.Duration) []*investapi.Trade {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	trades := h.trades[figi]
+	from := h.now.Add(-period)
+	i := sort.Search(len(trades), func(i int) bool {
+		return trades[i].Time.AsTime().After(from)
+	})
+	return append([]*investapi.Trade(nil), trades[i:]...)
+}
+
+// ReplayMarketData sends recorded market data to the bots subscribed to it as if it came from the stream,
+// see recording.Replay. Events are handed over to each bot in the order they were recorded, waiting for the bot
+// to take them, and history is served from the events replayed so far. Replayed data isn't recorded again
+func (e *TradeEnv) ReplayMarketData(ctx context.Context, reader *recording.Reader, speed float64) (int, error) {
+	if !e.replayOnly {
+		return 0, errors.New("market data can only be replayed in the replay environment")
+	}
+	e.history.reset()
+	// Bots that haven't taken an event in time, they are only offered the next ones
+	stalled := make(map[int]bool)
+	return recording.Replay(ctx, reader, speed, func(event recording.Event) {
+		e.history.add(event)
+		if e.paper != nil && event.Data.GetOrderbook() != nil {
+			// Fill paper orders before the bots see the order book
+			e.paper.UpdateOrderBook(event.Data.GetOrderbook())
+		}
+		for _, s := range e.subscribers(event.Data) {
+			wait := replayDeliveryTimeout
+			if stalled[s.botId] {
+				wait = 0
+			}
+			delivered := sendMarketData(ctx, s, event.Data, wait)
+			if !delivered && !stalled[s.botId] && ctx.Err() == nil {
+				log.Printf("replay: bot #%v doesn't take market data, it misses the events until it does", s.botId)
+			}
+			stalled[s.botId] = !delivered
+		}
+	})
+}
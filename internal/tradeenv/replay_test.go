@@ -0,0 +1,101 @@
+package tradeenv
+
+import (
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"testing"
+	"time"
+	"tinkoff-invest-contest/internal/bars"
+	"tinkoff-invest-contest/internal/client/investapi"
+	"tinkoff-invest-contest/internal/recording"
+	"tinkoff-invest-contest/internal/utils"
+)
+
+func replayCandle(t time.Time, close float64) recording.Event {
+	return recording.Event{
+		ReceivedAt: t.Add(30 * time.Second),
+		Data: &investapi.MarketDataResponse{Payload: &investapi.MarketDataResponse_Candle{Candle: &investapi.Candle{
+			Figi:     "figi",
+			Interval: investapi.SubscriptionInterval_SUBSCRIPTION_INTERVAL_ONE_MINUTE,
+			Open:     utils.FloatToQuotation(close),
+			High:     utils.FloatToQuotation(close),
+			Low:      utils.FloatToQuotation(close),
+			Close:    utils.FloatToQuotation(close),
+			Time:     timestamppb.New(t),
+		}}},
+	}
+}
+
+func replayTrade(t time.Time) recording.Event {
+	return recording.Event{
+		ReceivedAt: t,
+		Data: &investapi.MarketDataResponse{Payload: &investapi.MarketDataResponse_Trade{Trade: &investapi.Trade{
+			Figi: "figi",
+			Time: timestamppb.New(t),
+		}}},
+	}
+}
+
+func TestReplayHistory(t *testing.T) {
+	start := time.Date(2022, 5, 16, 10, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name       string
+		events     []recording.Event
+		n          int
+		wantCloses []float64
+		wantTrades int
+	}{
+		{
+			name:       "test1",
+			events:     nil,
+			n:          2,
+			wantCloses: nil,
+			wantTrades: 0,
+		},
+		{
+			name: "test2",
+			events: []recording.Event{
+				replayCandle(start, 100),
+				replayCandle(start.Add(time.Minute), 101),
+				replayCandle(start.Add(time.Minute), 102),
+				replayCandle(start, 99),
+				replayCandle(start.Add(2*time.Minute), 103),
+			},
+			n:          2,
+			wantCloses: []float64{102, 103},
+			wantTrades: 0,
+		},
+		{
+			name: "test3",
+			events: []recording.Event{
+				replayTrade(start),
+				replayCandle(start, 100),
+				replayTrade(start.Add(2 * time.Minute)),
+				replayTrade(start.Add(6 * time.Minute)),
+				replayTrade(start.Add(8 * time.Minute)),
+			},
+			n:          2,
+			wantCloses: []float64{100},
+			wantTrades: 2,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := newReplayHistory()
+			for _, event := range tt.events {
+				h.add(event)
+			}
+			got := h.bars("figi", bars.Interval{Duration: time.Minute}, bars.MoexSession, tt.n)
+			if len(got) != len(tt.wantCloses) {
+				t.Fatalf("bars() got %v candles, want %v", len(got), len(tt.wantCloses))
+			}
+			for i, candle := range got {
+				if utils.QuotationToFloat(candle.Close) != tt.wantCloses[i] {
+					t.Errorf("bars() candle %v close = %v, want %v", i, utils.QuotationToFloat(candle.Close), tt.wantCloses[i])
+				}
+			}
+			if trades := h.lastTrades("figi", 5*time.Minute); len(trades) != tt.wantTrades {
+				t.Errorf("lastTrades() got %v trades, want %v", len(trades), tt.wantTrades)
+			}
+		})
+	}
+}
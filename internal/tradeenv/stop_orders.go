@@ -0,0 +1,99 @@
+package tradeenv
+
+import (
+	"errors"
+	"time"
+	"tinkoff-invest-contest/internal/client/investapi"
+	"tinkoff-invest-contest/internal/utils"
+)
+
+// ErrStopOrdersNotSupported is returned by stop order methods in sandbox, paper trading and replay environments,
+// which have no stop orders
+var ErrStopOrdersNotSupported = errors.New("stop orders are only supported in combat environment")
+
+// PostStopOrder places an exchange stop order, execPrice is only used by stop-limit orders
+func (e *TradeEnv) PostStopOrder(figi string, quantity int64, triggerPrice *investapi.Quotation,
+	execPrice *investapi.Quotation, direction investapi.OrderDirection, accountId string,
+	stopOrderType investapi.StopOrderType) (stopOrderId string, err error) {
+	if e.isSandbox {
+		return "", ErrStopOrdersNotSupported
+	}
+	var execPriceFloat float64
+	if execPrice != nil {
+		execPriceFloat = utils.QuotationToFloat(execPrice)
+	}
+	return e.Client.PostStopOrder(figi, quantity, execPriceFloat, utils.QuotationToFloat(triggerPrice), direction,
+		accountId, stopOrderType)
+}
+
+func (e *TradeEnv) CancelStopOrder(accountId string, stopOrderId string) error {
+	if e.isSandbox {
+		return ErrStopOrdersNotSupported
+	}
+	return e.Client.CancelStopOrder(accountId, stopOrderId)
+}
+
+// GetActiveStopOrderIds returns ids of the account's active stop orders on the instrument
+func (e *TradeEnv) GetActiveStopOrderIds(accountId string, figi string) (map[string]bool, error) {
+	if e.isSandbox {
+		return nil, ErrStopOrdersNotSupported
+	}
+	stopOrders, err := e.Client.GetStopOrders(accountId)
+	if err != nil {
+		return nil, err
+	}
+	ids := make(map[string]bool)
+	for _, stopOrder := range stopOrders {
+		if stopOrder.Figi == figi {
+			ids[stopOrder.StopOrderId] = true
+		}
+	}
+	return ids, nil
+}
+
+// Execution is what the account has bought or sold of an instrument in a period, e.g. by a stop order
+type Execution struct {
+	Lots     int64
+	AvgPrice float64
+	// Time of the latest operation, zero if nothing is executed
+	Last time.Time
+}
+
+// GetExecution returns the lots of the instrument the account has bought or sold (depending on direction) since the given time
+func (e *TradeEnv) GetExecution(accountId string, instrument utils.InstrumentInterface, direction investapi.OrderDirection,
+	since time.Time) (Execution, error) {
+	if e.isSandbox {
+		return Execution{}, ErrStopOrdersNotSupported
+	}
+	operations, err := e.Client.GetOperations(accountId, instrument.GetFigi(), since, time.Now())
+	if err != nil {
+		return Execution{}, err
+	}
+	return executionOf(operations, direction, int64(instrument.GetLot())), nil
+}
+
+func executionOf(operations []*investapi.Operation, direction investapi.OrderDirection, lot int64) Execution {
+	operationType := investapi.OperationType_OPERATION_TYPE_BUY
+	if direction == investapi.OrderDirection_ORDER_DIRECTION_SELL {
+		operationType = investapi.OperationType_OPERATION_TYPE_SELL
+	}
+	var execution Execution
+	var units int64
+	var notional float64
+	for _, operation := range operations {
+		if operation.OperationType != operationType || operation.State != investapi.OperationState_OPERATION_STATE_EXECUTED {
+			continue
+		}
+		executed := operation.Quantity - operation.QuantityRest
+		units += executed
+		notional += utils.MoneyValueToFloat(operation.Price) * float64(executed)
+		if date := operation.Date.AsTime(); date.After(execution.Last) {
+			execution.Last = date
+		}
+	}
+	if units > 0 {
+		execution.Lots = units / lot
+		execution.AvgPrice = notional / float64(units)
+	}
+	return execution
+}
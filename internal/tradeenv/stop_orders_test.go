@@ -0,0 +1,57 @@
+package tradeenv
+
+import (
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"testing"
+	"time"
+	"tinkoff-invest-contest/internal/client/investapi"
+	"tinkoff-invest-contest/internal/utils"
+)
+
+func Test_executionOf(t *testing.T) {
+	start := time.Date(2022, 6, 1, 10, 0, 0, 0, time.UTC)
+	newOperation := func(operationType investapi.OperationType, quantity int64, quantityRest int64, price float64,
+		minute int) *investapi.Operation {
+		return &investapi.Operation{
+			OperationType: operationType,
+			State:         investapi.OperationState_OPERATION_STATE_EXECUTED,
+			Quantity:      quantity,
+			QuantityRest:  quantityRest,
+			Price:         utils.FloatToMoneyValue("rub", price),
+			Date:          timestamppb.New(start.Add(time.Duration(minute) * time.Minute)),
+		}
+	}
+	tests := []struct {
+		name       string
+		operations []*investapi.Operation
+		direction  investapi.OrderDirection
+		want       Execution
+	}{
+		{
+			name: "test1",
+			operations: []*investapi.Operation{
+				newOperation(investapi.OperationType_OPERATION_TYPE_SELL, 20, 0, 100, 1),
+				newOperation(investapi.OperationType_OPERATION_TYPE_SELL, 20, 10, 106, 2),
+				newOperation(investapi.OperationType_OPERATION_TYPE_BUY, 50, 0, 90, 3),
+			},
+			direction: investapi.OrderDirection_ORDER_DIRECTION_SELL,
+			want:      Execution{Lots: 3, AvgPrice: 102, Last: start.Add(2 * time.Minute)},
+		},
+		{
+			name: "test2",
+			operations: []*investapi.Operation{
+				newOperation(investapi.OperationType_OPERATION_TYPE_SELL, 20, 0, 100, 1),
+			},
+			direction: investapi.OrderDirection_ORDER_DIRECTION_BUY,
+			want:      Execution{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := executionOf(tt.operations, tt.direction, 10)
+			if got.Lots != tt.want.Lots || got.AvgPrice != tt.want.AvgPrice || !got.Last.Equal(tt.want.Last) {
+				t.Errorf("executionOf() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -1,10 +1,15 @@
 package tradeenv
 
 import (
+	"context"
+	"fmt"
 	"log"
 	"sync"
+	"time"
+	"tinkoff-invest-contest/internal/client"
 	"tinkoff-invest-contest/internal/client/investapi"
-	"tinkoff-invest-contest/internal/utils"
+	"tinkoff-invest-contest/internal/metrics"
+	"tinkoff-invest-contest/internal/recording"
 )
 
 var mu sync.Mutex
@@ -13,114 +18,246 @@ type subscriptions struct {
 	candles   []*investapi.CandleInstrument
 	info      []*investapi.InfoInstrument
 	orderBook []*investapi.OrderBookInstrument
+	trades    []*investapi.TradeInstrument
 }
 
-func (e *TradeEnv) SubscribeCandles(botId int, figi string, interval investapi.SubscriptionInterval) {
-	err := e.Client.SubscribeCandles(figi, interval)
-	utils.MaybeCrash(err)
+func (e *TradeEnv) SubscribeCandles(botId int, figi string, interval investapi.SubscriptionInterval) error {
+	if !e.replayOnly {
+		err := e.Client.SubscribeCandles(figi, interval)
+		if err != nil {
+			return err
+		}
+	}
 	mu.Lock()
 	if len(e.subscriptions.candles) < botId+1 {
 		e.subscriptions.candles = append(e.subscriptions.candles,
 			make([]*investapi.CandleInstrument, 1+botId-len(e.subscriptions.candles))...)
 	}
-	mu.Unlock()
 	e.subscriptions.candles[botId] = &investapi.CandleInstrument{
 		Figi:     figi,
 		Interval: interval,
 	}
+	mu.Unlock()
+	return nil
 }
 
-func (e *TradeEnv) SubscribeInfo(botId int, figi string) {
-	err := e.Client.SubscribeInfo(figi)
-	utils.MaybeCrash(err)
+func (e *TradeEnv) SubscribeInfo(botId int, figi string) error {
+	if !e.replayOnly {
+		err := e.Client.SubscribeInfo(figi)
+		if err != nil {
+			return err
+		}
+	}
 	mu.Lock()
 	if len(e.subscriptions.info) < botId+1 {
 		e.subscriptions.info = append(e.subscriptions.info,
 			make([]*investapi.InfoInstrument, 1+botId-len(e.subscriptions.info))...)
 	}
-	mu.Unlock()
 	e.subscriptions.info[botId] = &investapi.InfoInstrument{
 		Figi: figi,
 	}
+	mu.Unlock()
+	return nil
 }
 
-func (e *TradeEnv) SubscribeOrderBook(botId int, figi string, depth int32) {
-	err := e.Client.SubscribeOrderBook(figi, depth)
-	utils.MaybeCrash(err)
+func (e *TradeEnv) SubscribeOrderBook(botId int, figi string, depth int32) error {
+	if !e.replayOnly {
+		err := e.Client.SubscribeOrderBook(figi, depth)
+		if err != nil {
+			return err
+		}
+	}
 	mu.Lock()
 	if len(e.subscriptions.orderBook) < botId+1 {
 		e.subscriptions.orderBook = append(e.subscriptions.orderBook,
 			make([]*investapi.OrderBookInstrument, 1+botId-len(e.subscriptions.orderBook))...)
 	}
-	mu.Unlock()
 	e.subscriptions.orderBook[botId] = &investapi.OrderBookInstrument{
 		Figi:  figi,
 		Depth: depth,
 	}
+	mu.Unlock()
+	return nil
 }
 
+func (e *TradeEnv) SubscribeTrades(botId int, figi string) error {
+	if !e.replayOnly {
+		err := e.Client.SubscribeTrades(figi)
+		if err != nil {
+			return err
+		}
+	}
+	mu.Lock()
+	if len(e.subscriptions.trades) < botId+1 {
+		e.subscriptions.trades = append(e.subscriptions.trades,
+			make([]*investapi.TradeInstrument, 1+botId-len(e.subscriptions.trades))...)
+	}
+	e.subscriptions.trades[botId] = &investapi.TradeInstrument{
+		Figi: figi,
+	}
+	mu.Unlock()
+	return nil
+}
+
+// UnsubscribeAll removes bot's subscriptions, unsubscribing from the stream those no other bot needs,
+// and the metrics of its market data channels
 func (e *TradeEnv) UnsubscribeAll(botId int) {
-	_ = e.Client.UnsubscribeCandles(e.subscriptions.candles[botId].Figi, e.subscriptions.candles[botId].Interval)
-	_ = e.Client.UnsubscribeInfo(e.subscriptions.info[botId].Figi)
-	_ = e.Client.UnsubscribeOrderBook(e.subscriptions.orderBook[botId].Figi, e.subscriptions.orderBook[botId].Depth)
-	e.subscriptions.candles[botId] = nil
-	e.subscriptions.info[botId] = nil
-	e.subscriptions.orderBook[botId] = nil
+	mu.Lock()
+	var candles *investapi.CandleInstrument
+	var info *investapi.InfoInstrument
+	var orderBook *investapi.OrderBookInstrument
+	var trades *investapi.TradeInstrument
+	if botId < len(e.subscriptions.candles) {
+		candles, e.subscriptions.candles[botId] = e.subscriptions.candles[botId], nil
+		for _, subscription := range e.subscriptions.candles {
+			if candles != nil && subscription != nil && subscription.String() == candles.String() {
+				candles = nil
+			}
+		}
+	}
+	if botId < len(e.subscriptions.info) {
+		info, e.subscriptions.info[botId] = e.subscriptions.info[botId], nil
+		for _, subscription := range e.subscriptions.info {
+			if info != nil && subscription != nil && subscription.String() == info.String() {
+				info = nil
+			}
+		}
+	}
+	if botId < len(e.subscriptions.orderBook) {
+		orderBook, e.subscriptions.orderBook[botId] = e.subscriptions.orderBook[botId], nil
+		for _, subscription := range e.subscriptions.orderBook {
+			if orderBook != nil && subscription != nil && subscription.String() == orderBook.String() {
+				orderBook = nil
+			}
+		}
+	}
+	if botId < len(e.subscriptions.trades) {
+		trades, e.subscriptions.trades[botId] = e.subscriptions.trades[botId], nil
+		for _, subscription := range e.subscriptions.trades {
+			if trades != nil && subscription != nil && subscription.String() == trades.String() {
+				trades = nil
+			}
+		}
+	}
+	mu.Unlock()
+	metrics.UnregisterChannelBacklogs(fmt.Sprint(botId))
+	if e.replayOnly {
+		return
+	}
+
+	if candles != nil {
+		_ = e.Client.UnsubscribeCandles(candles.Figi, candles.Interval)
+	}
+	if info != nil {
+		_ = e.Client.UnsubscribeInfo(info.Figi)
+	}
+	if orderBook != nil {
+		_ = e.Client.UnsubscribeOrderBook(orderBook.Figi, orderBook.Depth)
+	}
+	if trades != nil {
+		_ = e.Client.UnsubscribeTrades(trades.Figi)
+	}
 }
 
+// handleResubscribe replays all active subscriptions on a freshly opened market data stream
 func (e *TradeEnv) handleResubscribe() error {
+	mu.Lock()
+	candles := make(map[string]*investapi.CandleInstrument)
 	for _, subscription := range e.subscriptions.candles {
-		if subscription == nil {
-			continue
-		}
-		_ = e.Client.UnsubscribeCandles(
-			subscription.Figi,
-			subscription.Interval,
-		)
-		if err := e.Client.SubscribeCandles(
-			subscription.Figi,
-			subscription.Interval,
-		); err != nil {
-			return err
+		if subscription != nil {
+			candles[subscription.String()] = subscription
 		}
 	}
+	info := make(map[string]*investapi.InfoInstrument)
 	for _, subscription := range e.subscriptions.info {
-		if subscription == nil {
-			continue
-		}
-		_ = e.Client.UnsubscribeInfo(
-			subscription.Figi,
-		)
-		if err := e.Client.SubscribeInfo(
-			subscription.Figi,
-		); err != nil {
-			return err
+		if subscription != nil {
+			info[subscription.String()] = subscription
 		}
 	}
+	orderBook := make(map[string]*investapi.OrderBookInstrument)
 	for _, subscription := range e.subscriptions.orderBook {
-		if subscription == nil {
-			continue
-		}
-		_ = e.Client.UnsubscribeOrderBook(
-			subscription.Figi,
-			subscription.Depth,
-		)
-		if err := e.Client.SubscribeOrderBook(
-			subscription.Figi,
-			subscription.Depth,
-		); err != nil {
+		if subscription != nil {
+			orderBook[subscription.String()] = subscription
+		}
+	}
+	trades := make(map[string]*investapi.TradeInstrument)
+	for _, subscription := range e.subscriptions.trades {
+		if subscription != nil {
+			trades[subscription.String()] = subscription
+		}
+	}
+	mu.Unlock()
+
+	for _, subscription := range candles {
+		if err := e.Client.SubscribeCandles(subscription.Figi, subscription.Interval); err != nil {
+			return err
+		}
+	}
+	for _, subscription := range info {
+		if err := e.Client.SubscribeInfo(subscription.Figi); err != nil {
+			return err
+		}
+	}
+	for _, subscription := range orderBook {
+		if err := e.Client.SubscribeOrderBook(subscription.Figi, subscription.Depth); err != nil {
+			return err
+		}
+	}
+	for _, subscription := range trades {
+		if err := e.Client.SubscribeTrades(subscription.Figi); err != nil {
 			return err
 		}
 	}
 	return nil
 }
 
+// handleStreamState notifies all bots about market data stream going down or coming back
+func (e *TradeEnv) handleStreamState(state client.StreamState) {
+	mu.Lock()
+	defer mu.Unlock()
+	for i, stack := range e.marketData {
+		if stack != nil {
+			offer(stack.StreamState, state, i, "stream_state")
+		}
+	}
+}
+
+// offer sends v unless the channel is full, so that a stalled bot can't block the stream for others
+func offer[T any](ch chan T, v T, botId int, channel string) bool {
+	select {
+	case ch <- v:
+		return true
+	default:
+		metrics.MarketDataDropped.WithLabelValues(fmt.Sprint(botId), channel).Inc()
+		return false
+	}
+}
+
+// send sends v waiting up to wait for the bot to take it, it's dropped afterwards or when ctx is done.
+// Zero wait offers v
+func send[T any](ctx context.Context, ch chan T, v T, botId int, channel string, wait time.Duration) bool {
+	if wait <= 0 {
+		return offer(ch, v, botId, channel)
+	}
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case ch <- v:
+		return true
+	case <-timer.C:
+	case <-ctx.Done():
+	}
+	metrics.MarketDataDropped.WithLabelValues(fmt.Sprint(botId), channel).Inc()
+	return false
+}
+
 func (e *TradeEnv) handleMarketDataStream(event *investapi.MarketDataResponse) {
+	countMarketDataEvent(event)
 	subscribeInfoResp := event.GetSubscribeInfoResponse()
 	if subscribeInfoResp != nil {
 		for _, s := range subscribeInfoResp.InfoSubscriptions {
 			if s.SubscriptionStatus != investapi.SubscriptionStatus_SUBSCRIPTION_STATUS_SUCCESS {
-				log.Fatalf("failed to subscribe to info (%v): %v", s.Figi, s.SubscriptionStatus.String())
+				log.Printf("error: failed to subscribe to info (%v): %v", s.Figi, s.SubscriptionStatus.String())
 			}
 		}
 	}
@@ -128,7 +265,7 @@ func (e *TradeEnv) handleMarketDataStream(event *investapi.MarketDataResponse) {
 	if subscribeCandlesResp != nil {
 		for _, s := range subscribeCandlesResp.CandlesSubscriptions {
 			if s.SubscriptionStatus != investapi.SubscriptionStatus_SUBSCRIPTION_STATUS_SUCCESS {
-				log.Fatalf("failed to subscribe to candles (%v): %v", s.Figi, s.SubscriptionStatus.String())
+				log.Printf("error: failed to subscribe to candles (%v): %v", s.Figi, s.SubscriptionStatus.String())
 			}
 		}
 	}
@@ -136,10 +273,52 @@ func (e *TradeEnv) handleMarketDataStream(event *investapi.MarketDataResponse) {
 	if subscribeOrderBookResp != nil {
 		for _, s := range subscribeOrderBookResp.OrderBookSubscriptions {
 			if s.SubscriptionStatus != investapi.SubscriptionStatus_SUBSCRIPTION_STATUS_SUCCESS {
-				log.Fatalf("failed to subscribe to order book (%v): %v", s.Figi, s.SubscriptionStatus.String())
+				log.Printf("error: failed to subscribe to order book (%v): %v", s.Figi, s.SubscriptionStatus.String())
+			}
+		}
+	}
+	subscribeTradesResp := event.GetSubscribeTradesResponse()
+	if subscribeTradesResp != nil {
+		for _, s := range subscribeTradesResp.TradeSubscriptions {
+			if s.SubscriptionStatus != investapi.SubscriptionStatus_SUBSCRIPTION_STATUS_SUCCESS {
+				log.Printf("error: failed to subscribe to trades (%v): %v", s.Figi, s.SubscriptionStatus.String())
 			}
 		}
 	}
+	mu.Lock()
+	recorder := e.recorder
+	mu.Unlock()
+	if recorder != nil {
+		err := recorder.Record(time.Now(), event)
+		if err != nil {
+			log.Printf("error: can't record market data: %v", err)
+		}
+	}
+	e.dispatchMarketData(event)
+}
+
+// dispatchMarketData offers market data to the bots subscribed to it
+func (e *TradeEnv) dispatchMarketData(event *investapi.MarketDataResponse) {
+	if e.paper != nil && event.GetOrderbook() != nil {
+		// Fill paper orders before the bots see the order book
+		e.paper.UpdateOrderBook(event.GetOrderbook())
+	}
+	for _, s := range e.subscribers(event) {
+		sendMarketData(context.Background(), s, event, 0)
+	}
+}
+
+// subscriber is a bot subscribed to a market data event
+type subscriber struct {
+	botId int
+	stack *MarketDataChannelStack
+}
+
+// subscribers returns the bots subscribed to the market data of the event
+func (e *TradeEnv) subscribers(event *investapi.MarketDataResponse) []subscriber {
+	mu.Lock()
+	defer mu.Unlock()
+	var subscribers []subscriber
 	tradingStatus := event.GetTradingStatus()
 	if tradingStatus != nil {
 		for i, subscription := range e.subscriptions.info {
@@ -147,7 +326,7 @@ func (e *TradeEnv) handleMarketDataStream(event *investapi.MarketDataResponse) {
 				continue
 			}
 			if subscription.Figi == tradingStatus.Figi {
-				e.marketData[i].TradingStatus <- tradingStatus
+				subscribers = append(subscribers, subscriber{i, e.marketData[i]})
 			}
 		}
 	}
@@ -158,7 +337,7 @@ func (e *TradeEnv) handleMarketDataStream(event *investapi.MarketDataResponse) {
 				continue
 			}
 			if subscription.Figi == candle.Figi && subscription.Interval == candle.Interval {
-				e.marketData[i].Candle <- candle
+				subscribers = append(subscribers, subscriber{i, e.marketData[i]})
 			}
 		}
 	}
@@ -169,49 +348,108 @@ func (e *TradeEnv) handleMarketDataStream(event *investapi.MarketDataResponse) {
 				continue
 			}
 			if subscription.Figi == orderBook.Figi && subscription.Depth == orderBook.Depth {
-				e.marketData[i].OrderBook <- orderBook
+				subscribers = append(subscribers, subscriber{i, e.marketData[i]})
+			}
+		}
+	}
+	trade := event.GetTrade()
+	if trade != nil {
+		for i, subscription := range e.subscriptions.trades {
+			if subscription == nil {
+				continue
+			}
+			if subscription.Figi == trade.Figi {
+				subscribers = append(subscribers, subscriber{i, e.marketData[i]})
 			}
 		}
 	}
+	return subscribers
+}
+
+// sendMarketData sends the market data of the event to the subscriber's channel of its kind, see send
+func sendMarketData(ctx context.Context, s subscriber, event *investapi.MarketDataResponse, wait time.Duration) bool {
+	switch payload := event.GetPayload().(type) {
+	case *investapi.MarketDataResponse_TradingStatus:
+		return send(ctx, s.stack.TradingStatus, payload.TradingStatus, s.botId, "trading_status", wait)
+	case *investapi.MarketDataResponse_Candle:
+		return send(ctx, s.stack.Candle, payload.Candle, s.botId, "candle", wait)
+	case *investapi.MarketDataResponse_Orderbook:
+		return send(ctx, s.stack.OrderBook, payload.Orderbook, s.botId, "order_book", wait)
+	case *investapi.MarketDataResponse_Trade:
+		return send(ctx, s.stack.Trade, payload.Trade, s.botId, "trade", wait)
+	}
+	return false
 }
 
 type MarketDataChannelStack struct {
 	TradingStatus chan *investapi.TradingStatus
 	Candle        chan *investapi.Candle
 	OrderBook     chan *investapi.OrderBook
+	Trade         chan *investapi.Trade
+	StreamState   chan client.StreamState
 }
 
-func (e *TradeEnv) InitNewMarketDataChannels() {
-	e.marketData = append(e.marketData, &MarketDataChannelStack{
-		TradingStatus: make(chan *investapi.TradingStatus, 1000),
-		Candle:        make(chan *investapi.Candle, 1000),
-		OrderBook:     make(chan *investapi.OrderBook, 1000),
-	})
-}
+func (e *TradeEnv) InitNewMarketDataChannels(botId int) {
+	size := 1000
+	if e.replayOnly {
+		// Replayed events are handed over one by one to keep their order across the channels
+		size = 0
+	}
+	stack := &MarketDataChannelStack{
+		TradingStatus: make(chan *investapi.TradingStatus, size),
+		Candle:        make(chan *investapi.Candle, size),
+		OrderBook:     make(chan *investapi.OrderBook, size),
+		Trade:         make(chan *investapi.Trade, size),
+		StreamState:   make(chan client.StreamState, 10),
+	}
+	mu.Lock()
+	if len(e.marketData) < botId+1 {
+		e.marketData = append(e.marketData,
+			make([]*MarketDataChannelStack, 1+botId-len(e.marketData))...)
+	}
+	e.marketData[botId] = stack
+	mu.Unlock()
 
-func (e *TradeEnv) GetMarketDataChannels(botId int) *MarketDataChannelStack {
-	return e.marketData[botId]
+	id := fmt.Sprint(botId)
+	metrics.RegisterChannelBacklog(id, "trading_status", func() int { return len(stack.TradingStatus) })
+	metrics.RegisterChannelBacklog(id, "candle", func() int { return len(stack.Candle) })
+	metrics.RegisterChannelBacklog(id, "order_book", func() int { return len(stack.OrderBook) })
+	metrics.RegisterChannelBacklog(id, "trade", func() int { return len(stack.Trade) })
 }
 
-func (e *TradeEnv) InitTradesChannels(accountIds []string) {
-	e.Client.InitTradesStream(accountIds)
-	e.mu.Lock()
-	for _, id := range accountIds {
-		e.trades[id] = make(chan *investapi.OrderTrades)
+func countMarketDataEvent(event *investapi.MarketDataResponse) {
+	if event == nil {
+		return
+	}
+	var eventType string
+	switch event.Payload.(type) {
+	case *investapi.MarketDataResponse_Candle:
+		eventType = "candle"
+	case *investapi.MarketDataResponse_Orderbook:
+		eventType = "order_book"
+	case *investapi.MarketDataResponse_Trade:
+		eventType = "trade"
+	case *investapi.MarketDataResponse_TradingStatus:
+		eventType = "trading_status"
+	case *investapi.MarketDataResponse_LastPrice:
+		eventType = "last_price"
+	case *investapi.MarketDataResponse_Ping:
+		eventType = "ping"
+	default:
+		eventType = "subscription_response"
 	}
-	e.mu.Unlock()
+	metrics.MarketDataEvents.WithLabelValues(eventType).Inc()
 }
 
-func (e *TradeEnv) handleTradesStream(resp *investapi.TradesStreamResponse) {
-	orderTrades := resp.GetOrderTrades()
-	if orderTrades == nil {
-		return
-	}
-	e.trades[orderTrades.AccountId] <- orderTrades
+// SetRecorder makes the environment record the market data it receives, nil stops recording
+func (e *TradeEnv) SetRecorder(recorder *recording.Recorder) {
+	mu.Lock()
+	e.recorder = recorder
+	mu.Unlock()
 }
 
-func (e *TradeEnv) GetTradesChannel(accountId string) chan *investapi.OrderTrades {
-	e.mu.RLock()
-	defer e.mu.RUnlock()
-	return e.trades[accountId]
+func (e *TradeEnv) GetMarketDataChannels(botId int) *MarketDataChannelStack {
+	mu.Lock()
+	defer mu.Unlock()
+	return e.marketData[botId]
 }
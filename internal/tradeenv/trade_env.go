@@ -1,11 +1,13 @@
 package tradeenv
 
 import (
+	"context"
 	"log"
 	"sync"
-	"tinkoff-invest-contest/internal/appstate"
 	"tinkoff-invest-contest/internal/client"
 	"tinkoff-invest-contest/internal/client/investapi"
+	"tinkoff-invest-contest/internal/paper"
+	"tinkoff-invest-contest/internal/recording"
 	"tinkoff-invest-contest/internal/utils"
 )
 
@@ -18,54 +20,110 @@ type TradeEnv struct {
 	accounts      map[string]map[string]*moneyPosition
 	subscriptions *subscriptions
 	marketData    []*MarketDataChannelStack
-	trades        map[string]chan *investapi.OrderTrades
+
+	ordersMu        sync.Mutex
+	pendingOrders   map[string]*pendingOrder
+	unclaimedTrades map[string]*unclaimedTrades
+	// Guarded by the package's mu, like subscriptions
+	recorder *recording.Recorder
+	// Executes orders locally instead of sandbox, see NewPaper
+	paper *paper.Venue
+	// Market data comes from replayed recordings only, see NewReplay
+	replayOnly bool
+	history    *replayHistory // History of the replayed market data, replay environment only
 
 	Client *client.Client
 }
 
-func New(token string, isSandbox bool) *TradeEnv {
+// New connects to Invest API and runs the streams until ctx is done
+func New(ctx context.Context, token string, isSandbox bool) (*TradeEnv, error) {
+	return newTradeEnv(ctx, token, isSandbox, nil, false)
+}
+
+// NewPaper makes a paper trading environment: market data comes from the sandbox stream,
+// orders and accounts are the venue's
+func NewPaper(ctx context.Context, token string, venue *paper.Venue) (*TradeEnv, error) {
+	return newTradeEnv(ctx, token, true, venue, false)
+}
+
+// NewReplay makes a paper trading environment without a market data stream, so that its bots only get
+// the market data replayed with ReplayMarketData. History is served from the replayed market data too
+func NewReplay(ctx context.Context, token string, venue *paper.Venue) (*TradeEnv, error) {
+	return newTradeEnv(ctx, token, true, venue, true)
+}
+
+func newTradeEnv(ctx context.Context, token string, isSandbox bool, venue *paper.Venue,
+	replayOnly bool) (*TradeEnv, error) {
 	tradeEnv := &TradeEnv{
-		token:     token,
-		isSandbox: isSandbox,
-		accounts:  make(map[string]map[string]*moneyPosition),
+		token:      token,
+		isSandbox:  isSandbox,
+		paper:      venue,
+		replayOnly: replayOnly,
+		accounts:   make(map[string]map[string]*moneyPosition),
 		subscriptions: &subscriptions{
 			candles:   make([]*investapi.CandleInstrument, 0),
 			info:      make([]*investapi.InfoInstrument, 0),
 			orderBook: make([]*investapi.OrderBookInstrument, 0),
+			trades:    make([]*investapi.TradeInstrument, 0),
 		},
-		marketData: make([]*MarketDataChannelStack, 0),
-		trades:     make(map[string]chan *investapi.OrderTrades),
-		Client:     client.NewClient(token),
+		marketData:      make([]*MarketDataChannelStack, 0),
+		pendingOrders:   make(map[string]*pendingOrder),
+		unclaimedTrades: make(map[string]*unclaimedTrades),
+		Client:          client.NewClient(token),
+	}
+	_ = tradeEnv.Client.WaitUntilAvailable(ctx)
+	if replayOnly {
+		tradeEnv.history = newReplayHistory()
+		tradeEnv.Fee = venue.Fee()
+		return tradeEnv, nil
+	}
+	err := tradeEnv.Client.InitMarketDataStream()
+	if err != nil {
+		log.Println("error: can't open market data stream, will retry:", err)
 	}
-	tradeEnv.Client.InitMarketDataStream()
 
 	if !isSandbox {
-		tradeEnv.loadCombatAccounts()
-		go tradeEnv.Client.RunTradesStreamLoop(tradeEnv.handleTradesStream)
+		err = tradeEnv.loadCombatAccounts()
+		if err != nil {
+			return nil, err
+		}
+		go tradeEnv.Client.RunTradesStreamLoop(ctx, tradeEnv.handleTradesStream, tradeEnv.handleTradesStreamState)
 
-		info, err := tradeEnv.Client.GetInfo()
-		utils.MaybeCrash(err)
+		var info *investapi.GetInfoResponse
+		info, err = tradeEnv.Client.GetInfo()
+		if err != nil {
+			return nil, err
+		}
 		tradeEnv.Fee = utils.Fees[utils.Tariff(info.Tariff)]
+	} else if venue != nil {
+		tradeEnv.Fee = venue.Fee()
 	} else {
 		tradeEnv.Fee = 0
 	}
 
-	go tradeEnv.Client.RunMarketDataStreamLoop(tradeEnv.handleMarketDataStream, tradeEnv.handleResubscribe)
+	tradeEnv.registerHealthChecks()
 
-	go func() {
-		appstate.ExitActionsWG.Wait()
-		tradeEnv.exitActions()
-	}()
+	go tradeEnv.Client.RunMarketDataStreamLoop(
+		ctx,
+		tradeEnv.handleMarketDataStream,
+		tradeEnv.handleResubscribe,
+		tradeEnv.handleStreamState,
+	)
 
-	appstate.PostExitActionsWG.Add(1)
+	return tradeEnv, nil
+}
 
-	return tradeEnv
+func (e *TradeEnv) IsSandbox() bool {
+	return e.isSandbox
 }
 
-func (e *TradeEnv) exitActions() {
-	defer appstate.PostExitActionsWG.Done()
+func (e *TradeEnv) IsReplay() bool {
+	return e.replayOnly
+}
 
-	if e.isSandbox {
+// Close releases environment's resources on exit, sandbox accounts are closed
+func (e *TradeEnv) Close() {
+	if e.isSandbox && e.paper == nil {
 		for accountId := range e.accounts {
 			_, err := e.Client.CloseSandboxAccount(accountId)
 			if err != nil {
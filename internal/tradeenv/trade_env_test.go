@@ -0,0 +1,42 @@
+package tradeenv
+
+import (
+	"context"
+	"os"
+	"testing"
+	"tinkoff-invest-contest/internal/client/investapi"
+	"tinkoff-invest-contest/internal/paper"
+	"tinkoff-invest-contest/internal/utils"
+)
+
+const paperFigi = "BBG000000001"
+
+// newSandboxEnv connects to the sandbox, the tests that need it are skipped without a sandbox token
+func newSandboxEnv(t *testing.T) *TradeEnv {
+	token := os.Getenv("SANDBOX_TOKEN")
+	if token == "" {
+		t.Skip("'SANDBOX_TOKEN' environment variable is not set")
+	}
+	e, _ := New(context.Background(), token, true)
+	return e
+}
+
+// newPaperEnv makes a paper trading environment without streams, with an account that has 10000 rub
+// and an order book of 10 lots both at 99 and 100
+func newPaperEnv() (e *TradeEnv, accountId string) {
+	venue := paper.NewVenue(0)
+	venue.AddInstrument(&investapi.Share{Figi: paperFigi, Lot: 1, Currency: "rub"})
+	venue.UpdateOrderBook(&investapi.OrderBook{
+		Figi:         paperFigi,
+		IsConsistent: true,
+		Asks:         []*investapi.Order{{Price: utils.FloatToQuotation(100), Quantity: 10}},
+		Bids:         []*investapi.Order{{Price: utils.FloatToQuotation(99), Quantity: 10}},
+	})
+	e = &TradeEnv{
+		isSandbox:       true,
+		paper:           venue,
+		pendingOrders:   make(map[string]*pendingOrder),
+		unclaimedTrades: make(map[string]*unclaimedTrades),
+	}
+	return e, venue.OpenAccount(map[string]float64{"rub": 10000})
+}
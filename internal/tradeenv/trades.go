@@ -0,0 +1,25 @@
+package tradeenv
+
+import (
+	"sort"
+	"time"
+	"tinkoff-invest-contest/internal/client/investapi"
+)
+
+// GetLastTrades returns the instrument's trades of the last period, oldest first.
+// The API keeps the trades of the last hour only, the replay environment returns the trades replayed before
+// the time of the replayed event
+func (e *TradeEnv) GetLastTrades(figi string, period time.Duration) ([]*investapi.Trade, error) {
+	if e.replayOnly {
+		return e.history.lastTrades(figi, period), nil
+	}
+	now := time.Now()
+	trades, err := e.Client.GetLastTrades(figi, now.Add(-period), now)
+	if err != nil {
+		return nil, err
+	}
+	sort.SliceStable(trades, func(i, j int) bool {
+		return trades[i].Time.AsTime().Before(trades[j].Time.AsTime())
+	})
+	return trades, nil
+}
@@ -16,14 +16,19 @@ func BotControls(c *gin.Context) {
 		return
 	}
 
+	status := bot.GetStatus()
 	templateArgs := struct {
 		Id        string
 		IsStarted bool
 		IsPaused  bool
+		State     string
+		LastError string
 	}{
 		Id:        id,
 		IsStarted: bot.IsStarted(),
 		IsPaused:  bot.IsPaused(),
+		State:     status.State,
+		LastError: status.LastError,
 	}
 
 	c.HTML(http.StatusOK, "bot_controls.html", templateArgs)
@@ -49,7 +54,18 @@ func BotLogConsole(c *gin.Context) {
 
 func BotDescription(c *gin.Context) {
 	id := c.Query("id")
-	desc := app.Bots.Table[id].GetYAML()
+	app.Bots.Lock.RLock()
+	bot, ok := app.Bots.Table[id]
+	app.Bots.Lock.RUnlock()
+	if !ok {
+		_, _ = c.Writer.WriteString("bot #" + id + " does not exist")
+		return
+	}
+	desc, err := bot.GetYAML()
+	if err != nil {
+		_, _ = c.Writer.WriteString("can't describe bot #" + id + ": " + err.Error())
+		return
+	}
 	templateArgs := struct {
 		DescriptionYAML string
 	}{desc}
@@ -63,3 +79,7 @@ func CombatAccounts(c *gin.Context) {
 func SandboxAccounts(c *gin.Context) {
 	c.HTML(http.StatusOK, "sandbox_accounts.html", nil)
 }
+
+func BotGroups(c *gin.Context) {
+	c.HTML(http.StatusOK, "bot_groups.html", nil)
+}
@@ -0,0 +1,36 @@
+package utils
+
+import (
+	"log"
+	"os"
+	"strconv"
+	"time"
+)
+
+// EnvInt sets n to the non-negative integer in the environment variable, n is left as is if it's not set or invalid
+func EnvInt(name string, n *int) {
+	s := os.Getenv(name)
+	if s == "" {
+		return
+	}
+	parsed, err := strconv.Atoi(s)
+	if err != nil || parsed < 0 {
+		log.Printf("invalid %v %q, using %v", name, s, *n)
+		return
+	}
+	*n = parsed
+}
+
+// EnvDuration sets d to the duration in the environment variable, d is left as is if it's not set or invalid
+func EnvDuration(name string, d *time.Duration) {
+	s := os.Getenv(name)
+	if s == "" {
+		return
+	}
+	parsed, err := time.ParseDuration(s)
+	if err != nil {
+		log.Printf("invalid duration in %v: %v", name, err)
+		return
+	}
+	*d = parsed
+}
@@ -0,0 +1,75 @@
+package utils
+
+import (
+	"os"
+	"testing"
+	"time"
+)
+
+func TestEnvDuration(t *testing.T) {
+	tests := []struct {
+		name  string
+		value string
+		want  time.Duration
+	}{
+		{
+			name:  "test1",
+			value: "",
+			want:  time.Minute,
+		},
+		{
+			name:  "test2",
+			value: "5s",
+			want:  5 * time.Second,
+		},
+		{
+			name:  "test3",
+			value: "five seconds",
+			want:  time.Minute,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_ = os.Setenv("TEST_DURATION", tt.value)
+			d := time.Minute
+			EnvDuration("TEST_DURATION", &d)
+			if d != tt.want {
+				t.Errorf("EnvDuration() = %v, want %v", d, tt.want)
+			}
+		})
+	}
+}
+
+func TestEnvInt(t *testing.T) {
+	tests := []struct {
+		name  string
+		value string
+		want  int
+	}{
+		{
+			name:  "test1",
+			value: "",
+			want:  3,
+		},
+		{
+			name:  "test2",
+			value: "10",
+			want:  10,
+		},
+		{
+			name:  "test3",
+			value: "-1",
+			want:  3,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_ = os.Setenv("TEST_INT", tt.value)
+			n := 3
+			EnvInt("TEST_INT", &n)
+			if n != tt.want {
+				t.Errorf("EnvInt() = %v, want %v", n, tt.want)
+			}
+		})
+	}
+}
@@ -42,3 +42,20 @@ func StringToInstrumentType(s string) (InstrumentType, error) {
 		return -1, fmt.Errorf("unknown instrument type: %q", s)
 	}
 }
+
+func InstrumentTypeToString(instrumentType InstrumentType) string {
+	switch instrumentType {
+	case InstrumentType_INSTRUMENT_TYPE_BOND:
+		return "bond"
+	case InstrumentType_INSTRUMENT_TYPE_CURRENCY:
+		return "currency"
+	case InstrumentType_INSTRUMENT_TYPE_ETF:
+		return "etf"
+	case InstrumentType_INSTRUMENT_TYPE_FUTURE:
+		return "future"
+	case InstrumentType_INSTRUMENT_TYPE_SHARE:
+		return "share"
+	default:
+		return ""
+	}
+}
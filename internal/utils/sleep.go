@@ -0,0 +1,18 @@
+package utils
+
+import (
+	"context"
+	"time"
+)
+
+// Sleep pauses for the given duration, returning early with an error if ctx is done
+func Sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,88 @@
+package validation
+
+import (
+	"fmt"
+	"strings"
+)
+
+// FieldError tells why a field of a request is invalid. Fields are named as API arguments
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// Errors collects field errors, so that all of them are reported at once
+type Errors []FieldError
+
+func (e *Errors) Add(field string, format string, args ...any) {
+	*e = append(*e, FieldError{Field: field, Message: fmt.Sprintf(format, args...)})
+}
+
+// Merge adds other errors with the fields prefixed, e.g. "strategyConfig." for strategy parameters
+func (e *Errors) Merge(prefix string, other Errors) {
+	for _, fieldError := range other {
+		*e = append(*e, FieldError{Field: prefix + fieldError.Field, Message: fieldError.Message})
+	}
+}
+
+// Range adds an error unless min <= value <= max
+func (e *Errors) Range(field string, value float64, min float64, max float64) {
+	if value < min || value > max {
+		e.Add(field, "must be from %v to %v, got %v", min, max, value)
+	}
+}
+
+// Bounds adds an error unless value is within the bounds, nil bounds don't limit the value
+func (e *Errors) Bounds(field string, value float64, min *float64, max *float64, minExcluded bool, maxExcluded bool) {
+	if min != nil {
+		if minExcluded && value <= *min {
+			e.Add(field, "must be greater than %v, got %v", *min, value)
+		} else if value < *min {
+			e.Add(field, "must be at least %v, got %v", *min, value)
+		}
+	}
+	if max != nil {
+		if maxExcluded && value >= *max {
+			e.Add(field, "must be less than %v, got %v", *max, value)
+		} else if value > *max {
+			e.Add(field, "must be at most %v, got %v", *max, value)
+		}
+	}
+}
+
+// Ratio adds an error unless 0 <= value < 1
+func (e *Errors) Ratio(field string, value float64) {
+	if value < 0 || value >= 1 {
+		e.Add(field, "must be a ratio from 0 to 1 (exclusive), got %v", value)
+	}
+}
+
+// Positive adds an error unless value > 0
+func (e *Errors) Positive(field string, value float64) {
+	if value <= 0 {
+		e.Add(field, "must be positive, got %v", value)
+	}
+}
+
+// NonNegative adds an error unless value >= 0
+func (e *Errors) NonNegative(field string, value float64) {
+	if value < 0 {
+		e.Add(field, "must not be negative, got %v", value)
+	}
+}
+
+func (e Errors) Error() string {
+	messages := make([]string, 0, len(e))
+	for _, fieldError := range e {
+		messages = append(messages, fieldError.Field+": "+fieldError.Message)
+	}
+	return strings.Join(messages, "; ")
+}
+
+// Err returns nil if there are no errors, so that the result can be checked as a usual error
+func (e Errors) Err() error {
+	if len(e) == 0 {
+		return nil
+	}
+	return e
+}
@@ -0,0 +1,68 @@
+package validation
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestErrors(t *testing.T) {
+	tests := []struct {
+		name      string
+		fill      func(e *Errors)
+		wantError string
+	}{
+		{
+			name: "test1",
+			fill: func(e *Errors) {
+				e.Range("window", 5, 2, 1000)
+				e.Positive("coef", 1)
+				e.NonNegative("ratio", 0)
+			},
+		},
+		{
+			name: "test2",
+			fill: func(e *Errors) {
+				e.Range("window", 1, 2, 1000)
+				e.Positive("coef", 0)
+				e.NonNegative("ratio", -0.5)
+			},
+			wantError: "window: must be from 2 to 1000, got 1; coef: must be positive, got 0; ratio: must not be negative, got -0.5",
+		},
+		{
+			name: "test3",
+			fill: func(e *Errors) {
+				var strategyErrors Errors
+				strategyErrors.Add("coef", "is required")
+				e.Merge("strategyConfig.", strategyErrors)
+			},
+			wantError: "strategyConfig.coef: is required",
+		},
+		{
+			name: "test4",
+			fill: func(e *Errors) {
+				min, max := 0.0, 1.0
+				e.Bounds("ratio", 0, &min, &max, true, false)
+				e.Bounds("timesRepeated", 2, &max, nil, false, false)
+				e.Bounds("priceDelta", 1, &min, &max, false, true)
+			},
+			wantError: "ratio: must be greater than 0, got 0; priceDelta: must be less than 1, got 1",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var e Errors
+			tt.fill(&e)
+			err := e.Err()
+			if tt.wantError == "" {
+				if err != nil {
+					t.Errorf("Err() = %v, want nil", err)
+				}
+				return
+			}
+			var fieldErrors Errors
+			if !errors.As(err, &fieldErrors) || err.Error() != tt.wantError {
+				t.Errorf("Err() = %v, want %v", err, tt.wantError)
+			}
+		})
+	}
+}